@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+	"github.com/sammyshear/adon-olam/internal/lyrics"
+)
+
+// runInspect implements the inspect subcommand, which lists the tracks of a
+// MIDI file, MusicXML score or ABC tune so the right -track can be picked
+func runInspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	midiPath := fs.String("midi", "", "Path to MIDI file, MusicXML score or ABC tune (required)")
+	scorePath := fs.String("score", "", "Path to MusicXML score or ABC tune, same as -midi")
+	ipaPath := fs.String("lyrics", "", "Path to lyrics text file, to check how well each track fits them (optional)")
+	textID := fs.String("text", "", "ID of a text from the catalog, instead of -lyrics (optional)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: fonspeak_midi_driver inspect -midi melody.mid [-lyrics lyrics.txt | -text id]\n")
+		fmt.Fprintf(os.Stderr, "\nLists each track's name, instrument, channels, notes, pitch range, polyphony, duration and embedded lyric events.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	// Allow the file to be given without -midi
+	if *midiPath == "" {
+		*midiPath = *scorePath
+	}
+	if *midiPath == "" && fs.NArg() == 1 {
+		*midiPath = fs.Arg(0)
+	}
+	if *midiPath == "" {
+		fs.Usage()
+		return fmt.Errorf("-midi is required")
+	}
+
+	midiFile, err := os.Open(*midiPath)
+	if err != nil {
+		return fmt.Errorf("failed to open MIDI file: %w", err)
+	}
+	defer midiFile.Close()
+
+	score, err := fonspeak_midi.ReadScore(context.Background(), midiFile)
+	if err != nil {
+		return err
+	}
+
+	syllableCount := 0
+	if *ipaPath != "" {
+		lyricsContent, err := os.ReadFile(*ipaPath)
+		if err != nil {
+			return fmt.Errorf("failed to read lyrics file: %w", err)
+		}
+		syllables, err := lyrics.Parse(string(lyricsContent), lyrics.PronunciationModern)
+		if err != nil {
+			return fmt.Errorf("failed to parse lyrics: %w", err)
+		}
+		syllableCount = len(syllables)
+	} else if *textID != "" {
+		text, err := lyrics.Lookup(*textID)
+		if err != nil {
+			return err
+		}
+		syllables, err := text.Syllables("")
+		if err != nil {
+			return fmt.Errorf("failed to parse lyrics: %w", err)
+		}
+		syllableCount = len(syllables)
+	}
+
+	if err := printSummaries(os.Stdout, score.Summaries()); err != nil {
+		return err
+	}
+
+	// Format 0 files keep every part on one track, so list the channels too
+	if score.SplitByChannel() {
+		channels, err := score.ChannelSummaries(0)
+		if err != nil {
+			return err
+		}
+		fmt.Println("\nThis is a format 0 file with every part on track 0. Pick a part with -channel:")
+		if err := printSummaries(os.Stdout, channels); err != nil {
+			return err
+		}
+	}
+
+	// Show what -track auto would pick
+	if choice, err := score.DetectMelodyTrack(syllableCount, fonspeak_midi.AllChannels); err == nil {
+		fmt.Printf("\n-track auto would use %s:\n", choice.Source())
+		for _, reason := range choice.Reasons {
+			fmt.Printf("  - %s\n", reason)
+		}
+	}
+	return nil
+}
+
+// printSummaries writes one row per track
+func printSummaries(w io.Writer, summaries []fonspeak_midi.TrackSummary) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TRACK\tNAME\tINSTRUMENT\tCHANNELS\tNOTES\tRANGE\tPOLYPHONY\tDURATION\tLYRICS")
+	for _, t := range summaries {
+		channels := make([]string, len(t.Channels))
+		for i, ch := range t.Channels {
+			// Channels are shown 1-based as in most sequencers
+			channels[i] = fmt.Sprint(ch + 1)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%s\t%d\t%.1fs\t%d\n",
+			t.Number, orDash(t.Name), orDash(t.InstrumentName()), orDash(strings.Join(channels, ",")),
+			t.NoteCount, orDash(t.PitchRange()), t.MaxPolyphony, t.Duration, t.Lyrics)
+	}
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
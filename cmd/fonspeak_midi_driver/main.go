@@ -1,185 +1,285 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"math"
 	"os"
+	"time"
 
 	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+	"github.com/sammyshear/adon-olam/internal/lyrics"
+	"github.com/sammyshear/adon-olam/internal/pipeline"
+	"github.com/sammyshear/adon-olam/internal/synth"
 	"github.com/sammyshear/adon-olam/internal/timing"
-	"github.com/sammyshear/fonspeak"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "inspect" {
+		if err := runInspect(os.Args[2:]); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "texts" {
+		if err := runTexts(os.Args[2:]); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
 	// Define command-line flags
-	midiPath := flag.String("midi", "", "Path to MIDI file (required)")
-	ipaPath := flag.String("lyrics", "", "Path to lyrics text file with X-SAMPA syllables (required)")
+	midiPath := flag.String("midi", "", "Path to MIDI file (this or -score is required)")
+	scorePath := flag.String("score", "", "Path to MusicXML score (.musicxml, .xml or compressed .mxl) or ABC tune (.abc), instead of -midi")
+	ipaPath := flag.String("lyrics", "", "Path to lyrics text file with IPA or X-SAMPA syllables, or pointed Hebrew (this or -text is required)")
+	textID := flag.String("text", "", "ID of a text from the catalog to sing instead of -lyrics, see the texts subcommand")
 	outPath := flag.String("out", "output.wav", "Output WAV file path")
-	voice := flag.String("voice", "he", "Voice to use for synthesis (default: he)")
+	voice := flag.String("voice", "", "Voice to use for synthesis (default: the pronunciation's voice, he for modern)")
 	maxHz := flag.Float64("maxhz", 500.0, "Maximum frequency cap in Hz (default: 500)")
-	trackNo := flag.Int("track", 0, "MIDI track number to use (default: 0)")
+	trackFlag := flag.String("track", "0", "MIDI track number to use, auto to detect the melody or all for every track (default: 0)")
+	channel := flag.Int("channel", 0, "MIDI channel (1-16) to take the melody from, 0 for all (default: 0)")
+	tempoScale := flag.Float64("tempo", 1.0, "Tempo scale: 2 sings twice as fast, 0.5 half as fast (default: 1)")
 	timingStrategy := flag.String("timing-strategy", "per-syllable", "Timing strategy: per-syllable (default) or last-phoneme (legacy)")
+	chordPolicy := flag.String("chord-policy", "lowest", "Note kept from chords: lowest (default), highest, loudest, closest or voice")
+	chordVoice := flag.Int("chord-voice", 1, "Voice kept by -chord-policy voice, counting down from the highest note (default: 1)")
+	chordThreshold := flag.Duration("chord-threshold", 10*time.Millisecond, "Notes starting within this long of each other form a chord (default: 10ms)")
+	alignment := flag.String("align", "even", "Syllable alignment: even (default) spreads syllables over the notes, lyrics follows the MIDI file's lyric events")
+	dynamics := flag.String("dynamics", "off", "Dynamics curve mapping velocity, volume (CC7) and expression (CC11) to loudness: off (default), linear or gm")
+	pronunciationFlag := flag.String("pronunciation", "", "Pronunciation of Hebrew lyrics: modern, ashkenazi, ashkenazi-lithuanian, ashkenazi-polish, ashkenazi-german, sephardi or yemenite (default: the text's own, modern for -lyrics)")
+	synthBackend := flag.String("synth", "fonspeak", "Synthesis backend: fonspeak (default) or sine (offline test tones)")
 
 	flag.Parse()
 
 	// Validate required flags
-	if *midiPath == "" || *ipaPath == "" {
+	if (*midiPath == "") == (*scorePath == "") || (*ipaPath == "") == (*textID == "") {
 		flag.Usage()
-		log.Fatal("Error: Both -midi and -lyrics flags are required")
+		log.Fatal("Error: one of -lyrics or -text and one of -midi or -score are required")
+	}
+	input := inputFile{path: *midiPath, kind: "MIDI file", part: "MIDI track"}
+	if *scorePath != "" {
+		input = inputFile{path: *scorePath, kind: "score", part: "score part"}
 	}
 
-	// Run the synthesis pipeline
-	if err := runSynthesis(*midiPath, *ipaPath, *outPath, *voice, *maxHz, *trackNo, *timingStrategy); err != nil {
-		log.Fatalf("Synthesis failed: %v", err)
+	strategy, err := timing.ParseTimingStrategy(*timingStrategy)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
 	}
 
-	fmt.Printf("Successfully generated speech to %s\n", *outPath)
-}
+	trackNo, err := fonspeak_midi.ParseTrack(*trackFlag)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *channel < 0 || *channel > 16 {
+		log.Fatal("Error: -channel must be between 1 and 16, or 0 for all")
+	}
 
-func runSynthesis(midiPath, ipaPath, outPath, voice string, maxHz float64, trackNo int, timingStrategyStr string) error {
-	// 1. Read MIDI file and extract monophonic melody
-	fmt.Println("Reading MIDI file...")
-	midiFile, err := os.Open(midiPath)
+	policy, err := fonspeak_midi.ParseChordPolicy(*chordPolicy)
 	if err != nil {
-		return fmt.Errorf("failed to open MIDI file: %w", err)
+		log.Fatalf("Error: %v", err)
+	}
+	if *chordThreshold <= 0 {
+		log.Fatal("Error: -chord-threshold must be positive")
+	}
+	reduction := fonspeak_midi.ChordReduction{Policy: policy, Voice: *chordVoice, Threshold: chordThreshold.Seconds()}
+	if err := reduction.Validate(); err != nil {
+		log.Fatalf("Error: %v", err)
 	}
-	defer midiFile.Close()
 
-	notes, err := fonspeak_midi.ExtractMonophonicMelody(midiFile, trackNo)
+	alignMode, err := fonspeak_midi.ParseAlignmentMode(*alignment)
 	if err != nil {
-		return fmt.Errorf("failed to extract melody: %w", err)
+		log.Fatalf("Error: %v", err)
 	}
 
-	fmt.Printf("Extracted %d notes from MIDI track %d\n", len(notes), trackNo)
+	if *tempoScale <= 0 {
+		log.Fatal("Error: -tempo must be positive")
+	}
 
-	// 2. Read X-SAMPA lyrics
-	fmt.Println("Reading X-SAMPA lyrics...")
-	lyricsFile, err := os.Open(ipaPath)
+	curve, err := synth.ParseDynamicsCurve(*dynamics)
 	if err != nil {
-		return fmt.Errorf("failed to open lyrics file: %w", err)
+		log.Fatalf("Error: %v", err)
 	}
-	defer lyricsFile.Close()
 
-	lyricsContent, err := io.ReadAll(lyricsFile)
+	source := lyricsSource{path: *ipaPath}
+	if *textID != "" {
+		source.text, err = lyrics.Lookup(*textID)
+		if err != nil {
+			log.Fatalf("Error: %v (run the texts subcommand to list them)", err)
+		}
+	}
+
+	pronunciation, err := lyrics.ParsePronunciation(*pronunciationFlag)
 	if err != nil {
-		return fmt.Errorf("failed to read lyrics file: %w", err)
+		log.Fatalf("Error: %v", err)
+	}
+	if *pronunciationFlag == "" && source.path == "" {
+		pronunciation = source.text.Pronunciation
+	}
+	if *voice == "" {
+		*voice = pronunciation.Voice()
 	}
 
-	// 3. Parse X-SAMPA syllables (space-separated)
-	syllables := fonspeak_midi.IPAToXSAMPA(string(lyricsContent))
-	fmt.Printf("Loaded %d syllables\n", len(syllables))
+	synthesizer, err := synth.New(*synthBackend)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 
-	if len(syllables) == 0 {
-		return fmt.Errorf("no syllables found in lyrics file")
+	req := pipeline.RenderRequest{
+		TrackNo:        trackNo,
+		Channel:        *channel,
+		Voice:          *voice,
+		MaxHz:          *maxHz,
+		TempoScale:     *tempoScale,
+		TimingStrategy: strategy,
+		ChordReduction: reduction,
+		Alignment:      alignMode,
+		Dynamics:       curve,
+		Synthesizer:    synthesizer,
 	}
 
-	// 4. Compute global octave drop to cap maximum frequency
-	maxFreq := fonspeak_midi.FindMaxFrequency(notes)
-	octaveDrop := fonspeak_midi.ComputeGlobalOctaveDropFromHz(maxFreq, maxHz)
+	// Run the synthesis pipeline
+	if err := runSynthesis(input, source, *outPath, pronunciation, req); err != nil {
+		log.Fatalf("Synthesis failed: %v", err)
+	}
 
-	if octaveDrop > 0 {
-		fmt.Printf("Original max frequency: %.2f Hz\n", maxFreq)
-		fmt.Printf("Applying global octave drop: %d octaves\n", octaveDrop)
-		newMaxFreq := maxFreq / math.Pow(2, float64(octaveDrop))
-		fmt.Printf("New max frequency: %.2f Hz\n", newMaxFreq)
-	} else {
-		fmt.Printf("Max frequency: %.2f Hz (no octave drop needed)\n", maxFreq)
+	fmt.Printf("Successfully generated speech to %s\n", *outPath)
+}
+
+// stageMessages are printed as each pipeline stage starts
+var stageMessages = map[pipeline.Stage]string{
+	pipeline.StageParse:      "Extracting melody...",
+	pipeline.StageAlign:      "Aligning syllables to melody...",
+	pipeline.StageTiming:     "Applying timing strategy...",
+	pipeline.StageSynthesize: "Synthesizing speech...",
+}
+
+// inputFile is the melody to sing: a MIDI file, a MusicXML score or an ABC
+// tune
+type inputFile struct {
+	path string
+	kind string // e.g. "MIDI file", for messages
+	part string // What a track of the file is called, e.g. "MIDI track"
+}
+
+// lyricsSource is the lyrics to sing: a file, or a text from the catalog
+type lyricsSource struct {
+	path string
+	text lyrics.Text // Used when path is empty
+}
+
+// read returns the lyrics
+func (s lyricsSource) read() (string, error) {
+	if s.path == "" {
+		return s.text.Lyrics(), nil
+	}
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read lyrics file: %w", err)
 	}
+	return string(content), nil
+}
 
-	// 5. Align syllables to melody with vowel extension
-	// If more syllables than notes, repeat melody
-	// If more notes than syllables, distribute syllables evenly and extend vowels only
-	var alignedNotes []fonspeak_midi.Note
-	var alignedSyllables []string
+// runSynthesis reads the melody and words into req, reading Hebrew lyrics
+// in pronunciation, renders it and writes the result to outPath
+func runSynthesis(input inputFile, words lyricsSource, outPath string, pronunciation lyrics.Pronunciation, req pipeline.RenderRequest) error {
+	// 1. Read MIDI file or score
+	fmt.Printf("Reading %s...\n", input.kind)
+	midiFile, err := os.Open(input.path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", input.kind, err)
+	}
+	defer midiFile.Close()
 
-	if len(syllables) > len(notes) {
-		// Repeat melody to cover all syllables
-		alignedNotes = fonspeak_midi.RepeatMelodyToCoverSyllables(notes, len(syllables))
-		alignedSyllables = syllables
-		fmt.Printf("Repeated melody to match %d syllables\n", len(syllables))
+	// 2. Read IPA, X-SAMPA or Hebrew lyrics
+	if words.path == "" {
+		fmt.Printf("Reading %s...\n", words.text.Title)
 	} else {
-		// Use all notes and distribute syllables evenly with vowel-only extension
-		alignedNotes = notes
-		alignedSyllables = fonspeak_midi.AlignSyllablesToMelody(syllables, len(notes))
-		if len(alignedSyllables) > len(syllables) {
-			fmt.Printf("Distributed %d syllables across %d notes with vowel extension for melisma\n",
-				len(syllables), len(notes))
+		fmt.Println("Reading lyrics...")
+	}
+	lyricsContent, err := words.read()
+	if err != nil {
+		return err
+	}
+
+	// 3. Parse syllables (space-separated), converting IPA or Hebrew to X-SAMPA
+	syllables, err := lyrics.Parse(lyricsContent, pronunciation)
+	if err != nil {
+		return fmt.Errorf("failed to parse lyrics: %w", err)
+	}
+	fmt.Printf("Loaded %d syllables (%s)\n", len(syllables), lyrics.Format(lyricsContent))
+
+	if len(syllables) == 0 {
+		return fmt.Errorf("no syllables found in lyrics")
+	}
+
+	// 4. Render through the shared pipeline
+	req.MIDI = midiFile
+	req.Syllables = syllables
+	req.Hooks.OnStageStart = func(stage pipeline.Stage) {
+		if msg, ok := stageMessages[stage]; ok {
+			fmt.Println(msg)
 		}
 	}
 
-	fmt.Printf("Aligned to %d note-syllable pairs\n", len(alignedNotes))
-
-	// 6. Apply timing strategy to compute phoneme durations
-	fmt.Printf("Applying timing strategy: %s\n", timingStrategyStr)
-	
-	// Parse timing strategy
-	var timingStrat timing.TimingStrategy
-	switch timingStrategyStr {
-	case "last-phoneme":
-		timingStrat = timing.LastPhoneme
-	case "per-syllable":
-		timingStrat = timing.PerSyllable
-	default:
-		return fmt.Errorf("invalid timing strategy: %s (must be 'per-syllable' or 'last-phoneme')", timingStrategyStr)
-	}
-	
-	// Set up timing options
-	timingOpts := timing.DefaultTimingOptions()
-	timingOpts.Strategy = timingStrat
-	
-	// Prepare notes with syllables for timing allocation
-	notesWithSyllables := timing.PrepareNotesWithSyllables(alignedNotes, alignedSyllables)
-	
-	// Allocate durations using the timing module
-	notesWithSyllables = timing.AllocateDurations(notesWithSyllables, timingOpts)
-
-	// 7. Build syllable parameters for fonspeak
-	syllableList := make([]fonspeak.Params, 0, len(alignedNotes))
-
-	for i, nws := range notesWithSyllables {
-		// Convert MIDI note to Hz with global octave drop
-		pitchHz := fonspeak_midi.MIDINoteToHz(nws.Note.MIDINote, -octaveDrop)
-
-		// Calculate WPM from phoneme durations (computed by timing module)
-		// This gives us a more intelligent WPM based on vowel lengthening
-		wpm := timing.ComputeWPMFromPhonemes(nws.Syllables)
-		
-		// If no syllables, fall back to default
-		if len(nws.Syllables) == 0 {
-			wpm = fonspeak_midi.WPMFromDuration(nws.Note.Duration)
+	result, err := pipeline.Render(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	if result.TrackChoice != nil {
+		fmt.Printf("Detected melody on %s:\n", result.TrackChoice.Source())
+		for _, reason := range result.TrackChoice.Reasons {
+			fmt.Printf("  - %s\n", reason)
 		}
+	}
 
-		syllableList = append(syllableList, fonspeak.Params{
-			Syllable:   alignedSyllables[i],
-			PitchShift: pitchHz,
-			Voice:      voice,
-			Wpm:        wpm,
-		})
+	for _, w := range result.Warnings {
+		fmt.Printf("Warning: %s\n", w)
 	}
 
-	// 8. Synthesize speech with fonspeak
-	fmt.Println("Synthesizing speech...")
+	pitchedCount := fonspeak_midi.CountPitchedNotes(result.Notes)
+	source := fmt.Sprintf("%s %d", input.part, result.TrackNo)
+	if result.TrackNo == fonspeak_midi.AllTracks {
+		source = "all " + input.part + "s"
+	}
+	if result.Channel != fonspeak_midi.AllChannels {
+		source += fmt.Sprintf(", channel %d", result.Channel)
+	}
+	fmt.Printf("Extracted %d notes and %d rests from %s\n", pitchedCount, len(result.Notes)-pitchedCount, source)
 
-	var buf bytes.Buffer
-	bufWriter := bufio.NewWriter(&buf)
+	if result.OctaveDrop > 0 {
+		fmt.Printf("Original max frequency: %.2f Hz\n", result.MaxFrequency)
+		fmt.Printf("Applied global octave drop: %d octaves\n", result.OctaveDrop)
+		newMaxFreq := result.MaxFrequency / math.Pow(2, float64(result.OctaveDrop))
+		fmt.Printf("New max frequency: %.2f Hz\n", newMaxFreq)
+	} else {
+		fmt.Printf("Max frequency: %.2f Hz (no octave drop needed)\n", result.MaxFrequency)
+	}
 
-	err = fonspeak.FonspeakPhrase(fonspeak.PhraseParams{
-		Syllables: syllableList,
-		WavFile:   &bufWriteCloser{Writer: bufWriter},
-	}, 15)
+	if req.Alignment == fonspeak_midi.AlignLyrics {
+		if result.Alignment == fonspeak_midi.AlignLyrics {
+			fmt.Printf("Aligned syllables to the %d lyric syllables of the MIDI file\n", fonspeak_midi.CountLyricSyllables(result.Notes))
+		} else {
+			fmt.Println("Warning: the melody has no lyric events, distributing syllables evenly instead")
+		}
+	}
 
-	if err != nil {
-		return fmt.Errorf("fonspeak synthesis failed: %w", err)
+	if result.Repeated {
+		fmt.Printf("Repeated melody to match %d syllables\n", len(syllables))
+	} else if result.Alignment == fonspeak_midi.AlignEven && pitchedCount > len(syllables) {
+		fmt.Printf("Distributed %d syllables across %d notes with vowel extension for melisma\n",
+			len(syllables), pitchedCount)
 	}
 
-	// 9. Write output file
+	// 5. Write output file
 	fmt.Println("Writing output file...")
-	err = os.WriteFile(outPath, buf.Bytes(), 0644)
+	out, err := result.Audio.Bytes()
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+
+	err = os.WriteFile(outPath, out, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write output file: %w", err)
 	}
@@ -187,28 +287,59 @@ func runSynthesis(midiPath, ipaPath, outPath, voice string, maxHz float64, track
 	return nil
 }
 
-// bufWriteCloser wraps bufio.Writer to implement WriteCloser
-type bufWriteCloser struct {
-	*bufio.Writer
-}
-
-func (bwc *bufWriteCloser) Close() error {
-	return bwc.Flush()
-}
-
 func init() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of fonspeak_midi_driver:\n")
-		fmt.Fprintf(os.Stderr, "\nGenerates speech synthesis of Adon Olam lyrics to a MIDI melody.\n\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver [flags]\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver inspect -midi melody.mid\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver texts\n")
+		fmt.Fprintf(os.Stderr, "\nGenerates speech synthesis of Adon Olam or other liturgical lyrics to a MIDI, MusicXML or ABC melody.\n\n")
 		fmt.Fprintf(os.Stderr, "Flags:\n")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "\nTiming Strategies:\n")
 		fmt.Fprintf(os.Stderr, "  per-syllable:  Intelligently distributes duration across syllables,\n")
 		fmt.Fprintf(os.Stderr, "                 prioritizing vowel lengthening (default, recommended)\n")
 		fmt.Fprintf(os.Stderr, "  last-phoneme:  Legacy behavior that puts extra duration in the last phoneme\n")
+		fmt.Fprintf(os.Stderr, "\nChord Policies:\n")
+		fmt.Fprintf(os.Stderr, "  lowest:        The lowest note of each chord (default)\n")
+		fmt.Fprintf(os.Stderr, "  highest:       The highest note, usually the tune in piano arrangements\n")
+		fmt.Fprintf(os.Stderr, "  loudest:       The note with the highest velocity\n")
+		fmt.Fprintf(os.Stderr, "  closest:       The note nearest the previous melody note, starting from the top\n")
+		fmt.Fprintf(os.Stderr, "  voice:         The -chord-voice'th note counting down from the highest\n")
+		fmt.Fprintf(os.Stderr, "\nDynamics Curves:\n")
+		fmt.Fprintf(os.Stderr, "  off:           Every note at full loudness (default)\n")
+		fmt.Fprintf(os.Stderr, "  linear:        Loudness in proportion to velocity, volume and expression\n")
+		fmt.Fprintf(os.Stderr, "  gm:            The General MIDI curve, softer notes drop off faster\n")
+		fmt.Fprintf(os.Stderr, "\nPronunciations (of Hebrew lyrics):\n")
+		fmt.Fprintf(os.Stderr, "  modern:                Modern Israeli Hebrew (default for -lyrics)\n")
+		fmt.Fprintf(os.Stderr, "  ashkenazi:             Qamats as o, holam as oi, tav without dagesh as s\n")
+		fmt.Fprintf(os.Stderr, "  ashkenazi-lithuanian:  Ashkenazi with holam as ei\n")
+		fmt.Fprintf(os.Stderr, "  ashkenazi-polish:      Ashkenazi with qamats as u in open syllables\n")
+		fmt.Fprintf(os.Stderr, "  ashkenazi-german:      Ashkenazi with holam as au\n")
+		fmt.Fprintf(os.Stderr, "  sephardi:              Pharyngeal het and ayin, vocal shva as e\n")
+		fmt.Fprintf(os.Stderr, "  yemenite:              Every begadkefat letter distinct, qof as g, holam as ø\n")
+		fmt.Fprintf(os.Stderr, "  Each sings with its own espeak-ng voice unless -voice is given\n")
+		fmt.Fprintf(os.Stderr, "  A -text is read in its usual pronunciation unless -pronunciation is given\n")
+		fmt.Fprintf(os.Stderr, "\nSynthesis Backends:\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak:      Sings with espeak-ng, Praat and sox (default, must be installed)\n")
+		fmt.Fprintf(os.Stderr, "  sine:          Pure-Go formant tones, no external binaries (for testing)\n")
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi melody.mid -lyrics adon_olam_xsampa.txt -out output.wav\n")
 		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi melody.mid -lyrics adon_olam_xsampa.txt -track 1 -voice he -maxhz 500 -out result.wav\n")
 		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi melody.mid -lyrics adon_olam_xsampa.txt -timing-strategy last-phoneme -out legacy.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi melody.mid -lyrics adon_olam_xsampa.txt -synth sine -out preview.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi melody.mid -lyrics adon_olam_xsampa.txt -tempo 0.8 -out slower.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi hymn.mid -lyrics adon_olam_xsampa.txt -track auto -out hymn.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi piano.mid -lyrics adon_olam_xsampa.txt -chord-policy highest -chord-threshold 30ms -out skyline.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi format0.mid -lyrics adon_olam_xsampa.txt -channel 2 -out flute.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi karaoke.kar -lyrics adon_olam_xsampa.txt -align lyrics -out karaoke.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi expressive.mid -lyrics adon_olam_xsampa.txt -dynamics gm -out dynamics.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -score hymn.mxl -lyrics adon_olam_xsampa.txt -align lyrics -out hymn.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -score tune.abc -lyrics adon_olam_xsampa.txt -align lyrics -out tune.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi melody.mid -lyrics adon_olam_hebrew.txt -out hebrew.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi melody.mid -lyrics adon_olam_hebrew.txt -pronunciation ashkenazi -out ashkenazi.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver -midi melody.mid -text lecha-dodi -out lecha_dodi.wav\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver inspect -midi melody.mid\n")
+		fmt.Fprintf(os.Stderr, "  fonspeak_midi_driver texts\n")
 	}
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/sammyshear/adon-olam/internal/lyrics"
+)
+
+// runTexts implements the texts subcommand, which lists the catalog of
+// liturgical texts that can be sung with -text
+func runTexts(args []string) error {
+	fs := flag.NewFlagSet("texts", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: fonspeak_midi_driver texts\n")
+		fmt.Fprintf(os.Stderr, "\nLists each text's ID, title, language, pronunciation, verses and syllables.\n")
+	}
+	fs.Parse(args)
+
+	return printTexts(os.Stdout, lyrics.Catalog())
+}
+
+// printTexts writes one row per text
+func printTexts(w io.Writer, texts []lyrics.Text) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tLANGUAGE\tPRONUNCIATION\tVERSES\tSYLLABLES")
+	for _, t := range texts {
+		syllables, err := t.Syllables("")
+		if err != nil {
+			return fmt.Errorf("text %s: %w", t.ID, err)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\n",
+			t.ID, t.Title, orDash(t.Language), t.Pronunciation, len(t.Verses), len(syllables))
+	}
+	return tw.Flush()
+}
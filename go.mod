@@ -9,6 +9,7 @@ require (
 	github.com/minio/minio-go/v7 v7.0.92
 	github.com/sammyshear/fonspeak v1.2.4
 	gitlab.com/gomidi/midi/v2 v2.2.1
+	golang.org/x/text v0.25.0
 )
 
 require (
@@ -51,7 +52,6 @@ require (
 	golang.org/x/net v0.40.0 // indirect
 	golang.org/x/sync v0.14.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
-	golang.org/x/text v0.25.0 // indirect
 	golang.org/x/tools v0.32.0 // indirect
 	google.golang.org/protobuf v1.34.2 // indirect
 )
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+)
+
+// inspection lists the tracks of an uploaded MIDI file
+type inspection struct {
+	FileName string                       `json:"fileName"`
+	Format   int                          `json:"format"`
+	Tracks   []fonspeak_midi.TrackSummary `json:"tracks"`
+	// Channels summarises each channel of the file, one channel per entry
+	Channels []fonspeak_midi.TrackSummary `json:"channels"`
+	// SplitByChannel is set for format 0 files whose parts share one track
+	// and must be picked by channel instead
+	SplitByChannel bool `json:"splitByChannel"`
+	// DetectedTrack is the track auto-detection would sing, if any has notes
+	DetectedTrack *fonspeak_midi.TrackCandidate `json:"detectedTrack,omitempty"`
+}
+
+// InspectHandler summarises the tracks and channels of an uploaded MIDI
+// file so the melody can be picked before rendering, along with the track
+// auto-detection would choose. htmx requests get the track and channel
+// <select>s for the upload form, JSON clients the summaries.
+func InspectHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asJSON := wantsJSON(r)
+
+		fileName, midiBytes, err := readMidiUpload(r)
+		if err != nil {
+			writeError(w, asJSON, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		score, err := fonspeak_midi.ReadScore(r.Context(), bytes.NewReader(midiBytes))
+		if err != nil {
+			writeError(w, asJSON, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// A format 0 file's channels all belong to track 0
+		channelTrack := fonspeak_midi.AllTracks
+		if score.Format == 0 {
+			channelTrack = 0
+		}
+		channels, err := score.ChannelSummaries(channelTrack)
+		if err != nil {
+			writeError(w, asJSON, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result := inspection{
+			FileName:       fileName,
+			Format:         score.Format,
+			Tracks:         score.Summaries(),
+			Channels:       channels,
+			SplitByChannel: score.SplitByChannel(),
+		}
+		// Fit the track to the lyrics of the form, if they can be read; the
+		// lyrics preview and the upload report any problem with them
+		syllableCount := 0
+		if song, err := parseLyrics(r); err == nil {
+			syllableCount = len(song.syllables)
+		}
+		if choice, err := score.DetectMelodyTrack(syllableCount, fonspeak_midi.AllChannels); err == nil {
+			result.DetectedTrack = &choice
+		}
+
+		if asJSON {
+			writeJSON(w, http.StatusOK, result)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, trackSelectHTML(result.Tracks, result.DetectedTrack))
+		fmt.Fprint(w, channelSelectHTML(result.Channels, result.SplitByChannel))
+	}
+}
+
+// trackSelectHTML renders the track dropdown of the upload form. Auto
+// detection is selected and names the track it would pick; tracks without
+// notes are disabled.
+func trackSelectHTML(summaries []fonspeak_midi.TrackSummary, detected *fonspeak_midi.TrackCandidate) string {
+	var b strings.Builder
+	b.WriteString(`<select name="trackNo" id="trackNo">`)
+	auto := "Auto-detect"
+	if detected != nil {
+		auto = fmt.Sprintf("Auto-detect (%s)", detected.Source())
+	}
+	fmt.Fprintf(&b, `<option value="auto" selected>%s</option>`, auto)
+	for _, t := range summaries {
+		attrs := ""
+		if !t.HasNotes() {
+			attrs = " disabled"
+		}
+		fmt.Fprintf(&b, `<option value="%d"%s>%s</option>`, t.Number, attrs, html.EscapeString(trackLabel(t)))
+	}
+	b.WriteString(`</select>`)
+	return b.String()
+}
+
+// channelSelectHTML renders the channel dropdown of the upload form,
+// swapped in out of band next to the track dropdown. Format 0 files get a
+// hint that their parts are told apart by channel.
+func channelSelectHTML(channels []fonspeak_midi.TrackSummary, split bool) string {
+	var b strings.Builder
+	b.WriteString(`<select name="channel" id="channel" hx-swap-oob="true">`)
+	all := "All channels"
+	if split {
+		all = "All channels (format 0 file: pick the melody's channel)"
+	}
+	fmt.Fprintf(&b, `<option value="all" selected>%s</option>`, all)
+	for _, t := range channels {
+		channel := t.Channels[0] + 1
+		fmt.Fprintf(&b, `<option value="%d">%s</option>`, channel,
+			html.EscapeString(fmt.Sprintf("Channel %d (%s)", channel, summaryDetails(t))))
+	}
+	b.WriteString(`</select>`)
+	return b.String()
+}
+
+// trackLabel describes a track in a line, e.g.
+// "Track 1: Melody (Piano, 42 notes, C4-G5, 3.5s)"
+func trackLabel(t fonspeak_midi.TrackSummary) string {
+	label := fmt.Sprintf("Track %d", t.Number)
+	if t.Name != "" {
+		label += ": " + t.Name
+	}
+	if !t.HasNotes() {
+		return label + " (no notes)"
+	}
+	return label + " (" + summaryDetails(t) + ")"
+}
+
+// summaryDetails lists a summary's instrument, notes, range, polyphony and
+// duration, e.g. "Piano, 42 notes, C4-G5, 3.5s"
+func summaryDetails(t fonspeak_midi.TrackSummary) string {
+	details := []string{}
+	if instrument := t.InstrumentName(); instrument != "" {
+		details = append(details, instrument)
+	}
+	details = append(details, fmt.Sprintf("%d notes", t.NoteCount), t.PitchRange())
+	if t.MaxPolyphony > 1 {
+		details = append(details, fmt.Sprintf("up to %d at once", t.MaxPolyphony))
+	}
+	details = append(details, fmt.Sprintf("%.1fs", t.Duration))
+	return strings.Join(details, ", ")
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+)
+
+func TestInspectHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(InspectHandler()))
+	defer srv.Close()
+
+	t.Run("json", func(t *testing.T) {
+		req := uploadRequest(t, srv.URL, "tune.mid", nil)
+		req.Header.Set("Accept", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+		got := decodeJSON[inspection](t, resp)
+		if got.FileName != "tune.mid" || len(got.Tracks) != 1 || got.DetectedTrack == nil || got.DetectedTrack.Track != 0 {
+			t.Fatalf("inspection = %+v, want tune.mid with 1 track", got)
+		}
+		if got.Format != 0 || got.SplitByChannel || len(got.Channels) != 1 {
+			t.Errorf("format = %d, split = %v, channels = %+v; want format 0 with one channel, not split", got.Format, got.SplitByChannel, got.Channels)
+		}
+		track := got.Tracks[0]
+		if track.NoteCount != 1 || track.LowestKey != 60 || track.HighestKey != 60 || track.MaxPolyphony != 1 {
+			t.Errorf("track = %+v, want a single middle C", track)
+		}
+	})
+
+	t.Run("htmx", func(t *testing.T) {
+		req := uploadRequest(t, srv.URL, "tune.mid", nil)
+		req.Header.Set("HX-Request", "true")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		want := `<select name="trackNo" id="trackNo"><option value="auto" selected>Auto-detect (track 0)</option><option value="0">Track 0 (1 notes, C4-C4, 0.5s)</option></select>` +
+			`<select name="channel" id="channel" hx-swap-oob="true"><option value="all" selected>All channels</option><option value="1">Channel 1 (1 notes, C4-C4, 0.5s)</option></select>`
+		if string(body) != want {
+			t.Errorf("body = %s, want %s", body, want)
+		}
+	})
+
+	t.Run("not midi", func(t *testing.T) {
+		req := uploadRequest(t, srv.URL, "tune.txt", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", resp.StatusCode)
+		}
+	})
+}
+
+func TestTrackSelectHTML(t *testing.T) {
+	summaries := []fonspeak_midi.TrackSummary{
+		{Number: 0, Name: "Tempo", Program: -1},
+		{Number: 1, Name: "Chords", Program: 0, NoteCount: 12, LowestKey: 48, HighestKey: 67, MaxPolyphony: 3, Duration: 8},
+		{Number: 2, Name: "<Melody>", Instrument: "Voice", Program: -1, NoteCount: 20, LowestKey: 60, HighestKey: 72, MaxPolyphony: 1, Duration: 8},
+	}
+	want := `<select name="trackNo" id="trackNo">` +
+		`<option value="auto" selected>Auto-detect (track 2)</option>` +
+		`<option value="0" disabled>Track 0: Tempo (no notes)</option>` +
+		`<option value="1">Track 1: Chords (Piano (program 0), 12 notes, C3-G4, up to 3 at once, 8.0s)</option>` +
+		`<option value="2">Track 2: &lt;Melody&gt; (Voice, 20 notes, C4-C5, 8.0s)</option>` +
+		`</select>`
+	if got := trackSelectHTML(summaries, &fonspeak_midi.TrackCandidate{Track: 2}); got != want {
+		t.Errorf("trackSelectHTML() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestChannelSelectHTML(t *testing.T) {
+	channels := []fonspeak_midi.TrackSummary{
+		{Program: 0, Channels: []int{0}, NoteCount: 16, LowestKey: 48, HighestKey: 58, MaxPolyphony: 2, Duration: 4},
+		{Program: 73, Channels: []int{1}, NoteCount: 8, LowestKey: 67, HighestKey: 70, MaxPolyphony: 1, Duration: 4},
+	}
+	want := `<select name="channel" id="channel" hx-swap-oob="true">` +
+		`<option value="all" selected>All channels (format 0 file: pick the melody's channel)</option>` +
+		`<option value="1">Channel 1 (Piano (program 0), 16 notes, C3-A#3, up to 2 at once, 4.0s)</option>` +
+		`<option value="2">Channel 2 (Pipe (program 73), 8 notes, G4-A#4, 4.0s)</option>` +
+		`</select>`
+	if got := channelSelectHTML(channels, true); got != want {
+		t.Errorf("channelSelectHTML() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -1,66 +1,226 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+	"github.com/sammyshear/adon-olam/internal/pipeline"
+	"github.com/sammyshear/adon-olam/internal/storage"
 )
 
-type JobStatus struct {
-	State   string `json:"state"`
-	Message string `json:"message,omitempty"`
-	JobURL  string `json:"jobUrl,omitempty"`
+// Job states
+const (
+	StateQueued    = "QUEUED"
+	StateRunning   = "RUNNING"
+	StateCompleted = "COMPLETED"
+	StateErrored   = "ERRORED"
+	StateCancelled = "CANCELLED"
+	StateTimedOut  = "TIMED_OUT"
+)
+
+// JobParams records the render options a job was submitted with
+type JobParams struct {
+	FileName string `json:"fileName"`
+	TrackNo  int    `json:"trackNo"`
+	// DetectedTrack explains the choice of TrackNo when it was auto-detected
+	DetectedTrack *fonspeak_midi.TrackCandidate `json:"detectedTrack,omitempty"`
+	// Channel is the 1-based MIDI channel of the melody, 0 for all channels
+	Channel        int     `json:"channel,omitempty"`
+	Voice          string  `json:"voice"`
+	MaxHz          float64 `json:"maxHz"`
+	TempoScale     float64 `json:"tempoScale"`
+	TimingStrategy string  `json:"timingStrategy"`
+	ChordPolicy    string  `json:"chordPolicy"`
+	ChordVoice     int     `json:"chordVoice,omitempty"`
+	// ChordThresholdMs is how close, in milliseconds, onsets must be to form a chord
+	ChordThresholdMs float64 `json:"chordThresholdMs"`
+	// Alignment is "even", or "lyrics" to follow the file's lyric events
+	Alignment string `json:"alignment"`
+	// Dynamics is the curve mapping note velocity, volume and expression to loudness
+	Dynamics string `json:"dynamics"`
+	// Text is the ID of the catalog text sung, empty if Lyrics were sent
+	Text string `json:"text,omitempty"`
+	// Lyrics are the lyrics sent with the upload, sung instead of a text
+	Lyrics string `json:"lyrics,omitempty"`
+	// Pronunciation is the tradition the Hebrew lyrics are read in
+	Pronunciation string `json:"pronunciation"`
 }
 
-var RequestStatus map[string]JobStatus
+type JobStatus struct {
+	ID        string `json:"id"`
+	State     string `json:"state"`
+	Message   string `json:"message,omitempty"`
+	JobURL    string `json:"jobUrl,omitempty"`
+	ResultURL string `json:"resultUrl,omitempty"`
+	// ResultKey is the object the result is stored under, named by a hash
+	// of the render inputs
+	ResultKey string `json:"resultKey,omitempty"`
+	// ResultExpiresAt is when ResultURL stops working, unset if it never does
+	ResultExpiresAt *time.Time `json:"resultExpiresAt,omitempty"`
+	// Warnings lists note events in the selected track that could not be used
+	Warnings []string `json:"warnings,omitempty"`
+	// QueuePosition is the 1-based place of a queued job, filled in on read
+	QueuePosition int       `json:"queuePosition,omitempty"`
+	Params        JobParams `json:"params"`
+	// Timings holds the seconds spent in each stage of a completed job
+	Timings   map[pipeline.Stage]float64 `json:"timings,omitempty"`
+	CreatedAt time.Time                  `json:"createdAt"`
+	UpdatedAt time.Time                  `json:"updatedAt"`
+}
 
-func storeStatus(requestID string, status JobStatus) {
-	if RequestStatus == nil {
-		RequestStatus = make(map[string]JobStatus)
+// IsTerminal reports whether the job has finished, successfully or not
+func (s JobStatus) IsTerminal() bool {
+	switch s.State {
+	case StateCompleted, StateErrored, StateCancelled, StateTimedOut:
+		return true
 	}
-	RequestStatus[requestID] = status
+	return false
 }
 
-func getStatus(requestID string) (JobStatus, error) {
-	if v, ok := RequestStatus[requestID]; ok {
-		return v, nil
+// setResultLink points the job at a download link for its result
+func (s *JobStatus) setResultLink(link storage.Link) {
+	s.ResultURL = link.URL
+	s.Message = fmt.Sprintf("<audio controls><source src='%s' type='audio/wave' /></audio>", link.URL)
+	s.ResultExpiresAt = nil
+	if !link.Expires.IsZero() {
+		expires := link.Expires.UTC()
+		s.ResultExpiresAt = &expires
 	}
+}
 
-	return JobStatus{}, fmt.Errorf("request id not found")
+// setState records a new state and message for a job, logging store failures
+// since the worker has nowhere else to report them
+func setState(store JobStore, id, state, message string) {
+	_, err := store.Update(id, func(s *JobStatus) {
+		s.State = state
+		s.Message = message
+	})
+	if err != nil {
+		log.Printf("Failed to update job %s: %s", id, err)
+	}
 }
 
 func generateRequestID() string {
 	return strings.ReplaceAll(uuid.NewString(), "-", "")
 }
 
-func JobStatusHandler(w http.ResponseWriter, r *http.Request) {
-	requestID := r.PathValue("requestID")
+// wantsJSON reports whether a client asked for JSON rather than the htmx
+// HTML fragments. htmx requests always get HTML.
+func wantsJSON(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "" && strings.Contains(r.Header.Get("Accept"), "application/json")
+}
 
-	status, err := getStatus(requestID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write JSON response: %s", err)
+	}
+}
+
+// writeError writes an error as {"error": msg} for JSON clients or plain text otherwise
+func writeError(w http.ResponseWriter, asJSON bool, msg string, code int) {
+	if asJSON {
+		writeJSON(w, code, map[string]string{"error": msg})
 		return
 	}
+	http.Error(w, msg, code)
+}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(status.Message))
+// statusError writes the HTTP error for a failed store lookup
+func statusError(w http.ResponseWriter, asJSON bool, err error) {
+	if errors.Is(err, ErrJobNotFound) {
+		writeError(w, asJSON, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeError(w, asJSON, err.Error(), http.StatusInternalServerError)
 }
 
-func JobStatusTicker(w http.ResponseWriter, r *http.Request) {
+// JobStatusHandler writes the job's message for htmx, or the full status
+// for clients that accept JSON
+func JobStatusHandler(queue *JobQueue) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.PathValue("requestID")
+		asJSON := wantsJSON(r)
+
+		status, err := queue.Status(requestID)
+		if err != nil {
+			statusError(w, asJSON, err)
+			return
+		}
+
+		if asJSON {
+			writeJSON(w, http.StatusOK, status)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(status.Message))
+	}
+}
+
+func JobStatusTicker(queue *JobQueue) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.PathValue("requestID")
+
+		status, err := queue.Status(requestID)
+		if err != nil {
+			statusError(w, false, err)
+			return
+		}
+
+		if status.IsTerminal() {
+			w.Header().Add("HX-Trigger", "done")
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if status.State == StateQueued && status.QueuePosition > 0 {
+			fmt.Fprintf(w, "Queued, position %d", status.QueuePosition)
+		} else {
+			fmt.Fprint(w, "Rendering")
+		}
+	}
+}
+
+// JobCancelHandler cancels a queued or running job. JSON clients get the
+// job's status back.
+func JobCancelHandler(queue *JobQueue) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cancelJob(w, r, wantsJSON(r), queue)
+	}
+}
+
+func cancelJob(w http.ResponseWriter, r *http.Request, asJSON bool, queue *JobQueue) {
 	requestID := r.PathValue("requestID")
 
-	status, err := getStatus(requestID)
+	err := queue.Cancel(requestID)
+	if errors.Is(err, ErrJobFinished) {
+		writeError(w, asJSON, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		statusError(w, asJSON, err)
 		return
 	}
 
-	if status.State == "COMPLETED" || status.State == "ERRORED" {
-		w.Header().Add("HX-Trigger", "done")
+	if !asJSON {
+		w.WriteHeader(http.StatusAccepted)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	status, err := queue.Status(requestID)
+	if err != nil {
+		statusError(w, asJSON, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, status)
 }
@@ -1,277 +1,411 @@
 package api
 
 import (
-	"bufio"
 	"bytes"
-	"context"
+	"errors"
 	"fmt"
+	"html"
+	"io"
 	"log"
-	"mime/multipart"
 	"net/http"
-	"net/url"
-	"os"
 	"regexp"
 	"strconv"
-	"sync"
-	"time"
+	"strings"
+	"unicode/utf8"
 
-	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+	"github.com/sammyshear/adon-olam/internal/lyrics"
+	"github.com/sammyshear/adon-olam/internal/pipeline"
+	"github.com/sammyshear/adon-olam/internal/synth"
 	"github.com/sammyshear/adon-olam/internal/timing"
-	"github.com/sammyshear/fonspeak"
 )
 
-type BufWriteCloser struct {
-	*bufio.Writer
-}
+// midiFileName matches the file extensions accepted for upload: MIDI files,
+// MusicXML scores, plain or compressed, and ABC tunes
+var midiFileName = regexp.MustCompile(`(?i)^.*\.(mid|midi|musicxml|xml|mxl|abc)$`)
 
-func (bwc *BufWriteCloser) Close() error {
-	return bwc.Flush()
-}
+// retryAfterSeconds is suggested to clients when the render queue is full
+const retryAfterSeconds = 30
+
+// maxLyricsBytes limits the lyrics sent with an upload
+const maxLyricsBytes = 64 << 10
+
+// readMidiUpload reads the uploaded MIDI file, MusicXML score or ABC tune of
+// a multipart form. The multipart form is cleaned up when the handler
+// returns, so the file is copied into memory.
+func readMidiUpload(r *http.Request) (string, []byte, error) {
+	r.ParseMultipartForm(10 << 20) // 10 MB
+	file, header, err := r.FormFile("uploadFile")
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	if !midiFileName.MatchString(header.Filename) {
+		return "", nil, errors.New("not a MIDI, MusicXML or ABC file")
+	}
 
-// syllables contains the Adon Olam lyrics in X-SAMPA format
-var syllables = []string{"a", "don", "o", "l@m", "aS", "er", "ma", "laX", "b@", "ter", "em", "kol", "je", "tsir", "niv", "ra", "l@", "et", "na:", "sa", "veX", "ef", "tso", "kol", "az", "ai", "mel", "eX", "Se", "mo", "nik", "ra", "ve", "aX", "a", "rei", "kix", "lot", "ha", "kol", "l@", "va", "do", "jim", "loX", "no", "ra", "v@", "hu", "ha", "ja", "v@", "hu", "ho", "ve", "v@", "hu", "ji", "je", "bet", "if", "ar", "a", "v@", "hu", "eX", "ad", "v@", "ein", "Se", "ni", "l@", "ham", "Sil", "lo", "l@", "haX", "bi", "ra", "bli", "re", "Sit", "bli", "taX", "lit", "v@", "lo", "ha", "oz", "v@", "ham", "mis", "rah", "v@", "hu", "el", "i", "v@", "Xai", "go", "al", "i", "v@", "tsur", "Xev", "li", "b@", "et", "tsa", "ra", "v@", "hu", "nis", "si", "u", "ma", "nos", "li", "m@", "nat", "ko", "si", "b@", "jom", "ek", "ra", "b@", "ja", "do", "af", "kid", "ru", "Xi", "b@", "et", "iS", "an", "v@", "a", "ir", "a", "v@", "im", "ru", "Xi", "g@", "vi", "ja", "ti", "ad", "on", "ai", "li", "v@", "lo", "ir", "a"}
-
-// channel holds information about a MIDI upload request being processed
-type channel struct {
-	requestID       string            // Unique identifier for this request
-	file            multipart.File    // The uploaded MIDI file
-	header          *multipart.FileHeader // File metadata
-	statusURL       string            // URL to check request status
-	trackNo         int               // MIDI track number to process
-	timingStrategy  string            // Timing strategy: "per-syllable" or "last-phoneme"
+	midiBytes, err := io.ReadAll(file)
+	if err != nil {
+		return "", nil, err
+	}
+	return header.Filename, midiBytes, nil
 }
 
-func UploadMidiHandler(ch chan channel) func(http.ResponseWriter, *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		statusURL := "/api/status/"
-		requestID := generateRequestID()
-		storeStatus(requestID, JobStatus{State: "NEW"})
+// songLyrics is what an upload sings: a text from the catalog, or lyrics
+// typed into or uploaded with the form
+type songLyrics struct {
+	text          lyrics.Text // Catalog text, unused when custom is set
+	custom        string      // Lyrics sent with the upload
+	pronunciation lyrics.Pronunciation
+	syllables     []string
+}
 
-		statusURL += requestID
+// readLyricsUpload returns the lyrics typed into the form's "lyrics" field
+// or uploaded as its "lyricsFile", or "" if neither was sent
+func readLyricsUpload(r *http.Request) (string, error) {
+	r.ParseMultipartForm(10 << 20) // 10 MB
+	typed := strings.TrimSpace(r.FormValue("lyrics"))
 
-		r.ParseMultipartForm(10 << 20) // 10 MB
-		file, header, err := r.FormFile("uploadFile")
+	uploaded := ""
+	file, _, err := r.FormFile("lyricsFile")
+	if err != nil && !errors.Is(err, http.ErrMissingFile) {
+		return "", err
+	}
+	if err == nil {
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, maxLyricsBytes+1))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+			return "", err
 		}
-		trackNo, err := strconv.Atoi(r.FormValue("trackNo"))
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		uploaded = strings.TrimSpace(string(data))
+	}
+
+	if typed != "" && uploaded != "" {
+		return "", errors.New("send lyrics as text or as a file, not both")
+	}
+	text := typed + uploaded
+	if len(text) > maxLyricsBytes {
+		return "", fmt.Errorf("lyrics are longer than %d KB", maxLyricsBytes>>10)
+	}
+	if !utf8.ValidString(text) {
+		return "", errors.New("lyrics are not UTF-8 text")
+	}
+	return text, nil
+}
+
+// parseLyrics reads the lyrics of an upload, or the catalog text chosen by
+// its "text" field if it has none, in the chosen pronunciation. Without one,
+// a catalog text is read in its own and other lyrics in the modern one.
+func parseLyrics(r *http.Request) (songLyrics, error) {
+	custom, err := readLyricsUpload(r)
+	if err != nil {
+		return songLyrics{}, err
+	}
+
+	song := songLyrics{custom: custom, pronunciation: lyrics.PronunciationModern}
+	if custom == "" {
+		if song.text, err = lyrics.Lookup(r.FormValue("text")); err != nil {
+			return songLyrics{}, err
 		}
-		
-		// Get timing strategy from form, default to per-syllable
-		timingStrategyVal := r.FormValue("timingStrategy")
-		if timingStrategyVal == "" {
-			timingStrategyVal = "per-syllable"
+		song.pronunciation = song.text.Pronunciation
+	}
+	if v := r.FormValue("pronunciation"); v != "" {
+		if song.pronunciation, err = lyrics.ParsePronunciation(v); err != nil {
+			return songLyrics{}, err
 		}
-		
-		ch <- channel{requestID, file, header, statusURL, trackNo, timingStrategyVal}
-
-		w.Header().Add("X-Status-URL", statusURL)
+	}
 
-		w.WriteHeader(http.StatusAccepted)
+	if custom == "" {
+		song.syllables, err = song.text.Syllables(song.pronunciation)
+		return song, err
+	}
+	if song.syllables, err = lyrics.Parse(custom, song.pronunciation); err != nil {
+		return songLyrics{}, fmt.Errorf("invalid lyrics: %w", err)
+	}
+	if err := checkSyllables(song.syllables); err != nil {
+		return songLyrics{}, fmt.Errorf("invalid lyrics: %w", err)
+	}
+	return song, nil
+}
 
-		fmt.Fprintf(w, `
-    <div hx-trigger="done" hx-get="%s" hx-swap="outerHTML" hx-target="this">
-      <h3 role="status" id="pblabel" tabindex="-1" autofocus>Accepted, Running Operation</h3>
-      <div hx-trigger="every 1s" hx-swap="none" hx-get="%s/tick"></div>
-    </div>`, statusURL, statusURL)
+// checkSyllables rejects lyrics without syllables, and syllables without a
+// vowel or syllabic consonant to sing, usually a missing space or a typo
+func checkSyllables(syllables []string) error {
+	if len(syllables) == 0 {
+		return errors.New("no syllables found")
 	}
+	for _, syllable := range syllables {
+		if !strings.ContainsFunc(syllable, fonspeak_midi.IsVowelSymbol) && !strings.Contains(syllable, "=") {
+			return fmt.Errorf("syllable %q has no vowel", syllable)
+		}
+	}
+	return nil
 }
 
-func uploadMidiProcessor(ch chan channel, wg *sync.WaitGroup) {
-	_ = wg
-	for c := range ch {
-		id := c.requestID
-		file := c.file
-		header := c.header
-		trackNo := c.trackNo
-		timingStrategyStr := c.timingStrategy
-		statusURL := c.statusURL
-		defer file.Close()
+// parseChordReduction reads how chords are reduced to a melody from the
+// chordPolicy, chordVoice and chordThreshold fields of an upload. The
+// threshold is in milliseconds; empty fields fall back to the defaults.
+func parseChordReduction(r *http.Request) (fonspeak_midi.ChordReduction, error) {
+	policy, err := fonspeak_midi.ParseChordPolicy(r.FormValue("chordPolicy"))
+	if err != nil {
+		return fonspeak_midi.ChordReduction{}, err
+	}
+	reduction := fonspeak_midi.ChordReduction{Policy: policy, Threshold: fonspeak_midi.DefaultChordThreshold}
+	if v := r.FormValue("chordVoice"); v != "" && policy == fonspeak_midi.ChordVoice {
+		if reduction.Voice, err = strconv.Atoi(v); err != nil {
+			return fonspeak_midi.ChordReduction{}, fmt.Errorf("invalid chord voice: %q", v)
+		}
+	}
+	if v := r.FormValue("chordThreshold"); v != "" {
+		ms, err := strconv.ParseFloat(v, 64)
+		if err != nil || ms <= 0 {
+			return fonspeak_midi.ChordReduction{}, fmt.Errorf("invalid chord threshold: %q", v)
+		}
+		reduction.Threshold = ms / 1000
+	}
+	if err := reduction.Validate(); err != nil {
+		return fonspeak_midi.ChordReduction{}, err
+	}
+	return reduction, nil
+}
 
-		re := regexp.MustCompile(`(?i)^.*\.(mid|midi)$`)
-		fileName := header.Filename
+// parseUpload reads the MIDI file and render options from a multipart upload.
+// Any error is the client's fault.
+func parseUpload(r *http.Request) (renderJob, error) {
+	badUpload := func(format string, a ...any) (renderJob, error) {
+		return renderJob{}, fmt.Errorf(format, a...)
+	}
 
-		if !re.MatchString(header.Filename) {
-			storeStatus(id, JobStatus{
-				State:   "ERRORED",
-				Message: "Not a midi file.",
-				JobURL:  statusURL,
-			})
-			return
-		}
+	fileName, midiBytes, err := readMidiUpload(r)
+	if err != nil {
+		return renderJob{}, err
+	}
 
-		// Extract monophonic melody using the new fonspeak_midi package
-		notes, err := fonspeak_midi.ExtractMonophonicMelody(file, trackNo)
+	// An empty track number asks for the melody to be detected
+	trackNo := fonspeak_midi.AutoTrack
+	if v := r.FormValue("trackNo"); v != "" {
+		trackNo, err = fonspeak_midi.ParseTrack(v)
 		if err != nil {
-			storeStatus(id, JobStatus{
-				State:   "ERRORED",
-				Message: fmt.Sprintf("Failed to extract melody: %v", err),
-				JobURL:  statusURL,
-			})
-			return
+			return badUpload("%v", err)
 		}
+	}
 
-		if len(notes) == 0 {
-			storeStatus(id, JobStatus{
-				State:   "ERRORED",
-				Message: "No notes found in the specified track",
-				JobURL:  statusURL,
-			})
-			return
-		}
+	channel, err := fonspeak_midi.ParseChannel(r.FormValue("channel"))
+	if err != nil {
+		return badUpload("%v", err)
+	}
 
-		// Apply global octave cap (max 500 Hz)
-		const maxHz = 500.0
-		maxFreq := fonspeak_midi.FindMaxFrequency(notes)
-		octaveDrop := fonspeak_midi.ComputeGlobalOctaveDropFromHz(maxFreq, maxHz)
-
-		// Align notes to syllables (157 syllables for Adon Olam)
-		syllableCount := len(syllables)
-		var alignedNotes []fonspeak_midi.Note
-		var alignedSyllables []string
-
-		if syllableCount > len(notes) {
-			// Repeat melody to cover all syllables
-			alignedNotes = fonspeak_midi.RepeatMelodyToCoverSyllables(notes, syllableCount)
-			alignedSyllables = syllables
-		} else {
-			// Use all notes and distribute syllables evenly with vowel-only extension
-			alignedNotes = notes
-			alignedSyllables = fonspeak_midi.AlignSyllablesToMelody(syllables, len(notes))
-		}
+	// Read the lyrics to fit the melody to them
+	song, err := parseLyrics(r)
+	if err != nil {
+		return renderJob{}, err
+	}
+	syllables := song.syllables
 
-		// Apply timing strategy to compute phoneme durations
-		var timingStrat timing.TimingStrategy
-		switch timingStrategyStr {
-		case "last-phoneme":
-			timingStrat = timing.LastPhoneme
-		case "per-syllable":
-			timingStrat = timing.PerSyllable
-		default:
-			// Default to per-syllable if invalid
-			timingStrat = timing.PerSyllable
-		}
-		
-		// Set up timing options
-		timingOpts := timing.DefaultTimingOptions()
-		timingOpts.Strategy = timingStrat
-		
-		// Prepare notes with syllables for timing allocation
-		notesWithSyllables := timing.PrepareNotesWithSyllables(alignedNotes, alignedSyllables)
-		
-		// Allocate durations using the timing module
-		notesWithSyllables = timing.AllocateDurations(notesWithSyllables, timingOpts)
-
-		// Build syllable parameters for fonspeak
-		syllableList := make([]fonspeak.Params, 0, len(alignedNotes))
-
-		for i, nws := range notesWithSyllables {
-			// Convert MIDI note to Hz with global octave drop
-			pitchHz := fonspeak_midi.MIDINoteToHz(nws.Note.MIDINote, -octaveDrop)
-
-			// Calculate WPM from phoneme durations (computed by timing module)
-			wpm := timing.ComputeWPMFromPhonemes(nws.Syllables)
-			
-			// If no syllables, fall back to default
-			if len(nws.Syllables) == 0 {
-				wpm = fonspeak_midi.WPMFromDuration(nws.Note.Duration)
-			}
-
-			syllableList = append(syllableList, fonspeak.Params{
-				Syllable:   alignedSyllables[i],
-				PitchShift: pitchHz,
-				Voice:      "he",
-				Wpm:        wpm,
-			})
-		}
+	// Read the file now so broken uploads are rejected before queueing and
+	// the job can report its warnings
+	score, err := fonspeak_midi.ReadScore(r.Context(), bytes.NewReader(midiBytes))
+	if err != nil {
+		return badUpload("%v", err)
+	}
 
-		// Synthesize speech
-		var buf bytes.Buffer
-		w := &BufWriteCloser{
-			Writer: bufio.NewWriter(&buf),
+	// Detect the track now so the job reports, and its result is stored
+	// under, the track and channel actually used
+	var trackChoice *fonspeak_midi.TrackCandidate
+	if trackNo == fonspeak_midi.AutoTrack {
+		choice, err := score.DetectMelodyTrack(len(syllables), channel)
+		if err != nil {
+			return badUpload("%v", err)
 		}
-		defer w.Close()
+		trackNo, channel, trackChoice = choice.Track, choice.Channel, &choice
+	}
 
-		err = fonspeak.FonspeakPhrase(fonspeak.PhraseParams{
-			Syllables: syllableList,
-			WavFile:   w,
-		}, 15)
+	// Empty optional fields fall back to the pipeline defaults
+	timingStrategy, err := timing.ParseTimingStrategy(r.FormValue("timingStrategy"))
+	if err != nil {
+		return badUpload("%v", err)
+	}
 
-		if err != nil {
-			storeStatus(id, JobStatus{
-				State:   "ERRORED",
-				Message: err.Error(),
-				JobURL:  statusURL,
-			})
-			return
+	alignment, err := fonspeak_midi.ParseAlignmentMode(r.FormValue("alignment"))
+	if err != nil {
+		return badUpload("%v", err)
+	}
+
+	dynamics, err := synth.ParseDynamicsCurve(r.FormValue("dynamics"))
+	if err != nil {
+		return badUpload("%v", err)
+	}
+
+	maxHz := pipeline.DefaultMaxHz
+	if v := r.FormValue("maxHz"); v != "" {
+		maxHz, err = strconv.ParseFloat(v, 64)
+		if err != nil || maxHz <= 0 {
+			return badUpload("invalid maximum frequency: %q", v)
 		}
+	}
 
-		uri, err := uploadWav(buf.Bytes(), fileName)
-		if err != nil {
-			storeStatus(id, JobStatus{
-				State:   "ERRORED",
-				Message: err.Error(),
-				JobURL:  statusURL,
-			})
-			return
+	tempoScale := 1.0
+	if v := r.FormValue("tempoScale"); v != "" {
+		tempoScale, err = strconv.ParseFloat(v, 64)
+		if err != nil || tempoScale <= 0 {
+			return badUpload("invalid tempo scale: %q", v)
 		}
+	}
 
-		storeStatus(id, JobStatus{
-			State:   "COMPLETED",
-			Message: fmt.Sprintf("<audio controls><source src='%s' type='audio/wave' /></audio>", uri),
-			JobURL:  statusURL,
-		})
+	reduction, err := parseChordReduction(r)
+	if err != nil {
+		return badUpload("%v", err)
+	}
+
+	voice := r.FormValue("voice")
+	if voice == "" {
+		voice = song.pronunciation.Voice()
 	}
+
+	return renderJob{
+		requestID:      generateRequestID(),
+		fileName:       fileName,
+		midi:           midiBytes,
+		trackNo:        trackNo,
+		trackChoice:    trackChoice,
+		warnings:       warningStrings(score.SelectWarnings(trackNo, channel)),
+		channel:        channel,
+		voice:          voice,
+		maxHz:          maxHz,
+		tempoScale:     tempoScale,
+		timingStrategy: timingStrategy,
+		chordReduction: reduction,
+		alignment:      alignment,
+		dynamics:       dynamics,
+		textID:         song.text.ID,
+		customLyrics:   song.custom,
+		pronunciation:  song.pronunciation,
+	}, nil
 }
 
-func uploadWav(b []byte, fileName string) (string, error) {
-	bucket := os.Getenv("MINIO_DEFAULT_BUCKETS")
-	endpoint := os.Getenv("MINIO_ENDPOINT")
-	secure := os.Getenv("MINIO_SECURE") == "true"
-	log.Println(bucket)
-	log.Println(endpoint)
-	object := fileName + ".wav"
-	log.Println(object)
-	file := bytes.NewReader(b)
-	ctx := context.Background()
-	client, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewEnvMinio(),
-		Secure: secure,
+// submitUpload records a queued job for a parsed upload and hands it to the
+// workers, marking it errored if the queue is full
+func submitUpload(queue *JobQueue, store JobStore, job renderJob, jobURL string) (JobStatus, error) {
+	_, err := store.Create(JobStatus{
+		ID:       job.requestID,
+		State:    StateQueued,
+		JobURL:   jobURL,
+		Warnings: job.warnings,
+		Params: JobParams{
+			FileName:         job.fileName,
+			TrackNo:          job.trackNo,
+			DetectedTrack:    job.trackChoice,
+			Channel:          job.channel,
+			Voice:            job.voice,
+			MaxHz:            job.maxHz,
+			TempoScale:       job.tempoScale,
+			TimingStrategy:   string(job.timingStrategy),
+			ChordPolicy:      string(job.chordReduction.Policy),
+			ChordVoice:       job.chordReduction.Voice,
+			ChordThresholdMs: job.chordReduction.Threshold * 1000,
+			Alignment:        string(job.alignment),
+			Dynamics:         string(job.dynamics),
+			Text:             job.textID,
+			Lyrics:           job.customLyrics,
+			Pronunciation:    string(job.pronunciation),
+		},
 	})
 	if err != nil {
-		return "", fmt.Errorf("minio.New: %w", err)
+		return JobStatus{}, err
 	}
 
-	found, err := client.BucketExists(ctx, bucket)
-	if err != nil {
-		return "", fmt.Errorf("bucket doesn't exist: %w", err)
+	// A job turned away by a full queue never existed as far as the
+	// client is concerned, so it leaves no record behind
+	if err := queue.Submit(job); err != nil {
+		if err := store.Delete(job.requestID); err != nil {
+			log.Printf("Failed to delete job %s: %s", job.requestID, err)
+		}
+		return JobStatus{}, err
 	}
 
-	if !found {
-		err = client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{})
+	return queue.Status(job.requestID)
+}
+
+// uploadHandler accepts a MIDI upload and queues it, linking the job under
+// urlPrefix. Responses are JSON if asJSON is set or the client asks for it.
+func uploadHandler(queue *JobQueue, store JobStore, urlPrefix string, asJSON bool) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asJSON := asJSON || wantsJSON(r)
+
+		job, err := parseUpload(r)
 		if err != nil {
-			return "", err
+			writeError(w, asJSON, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		jobURL := urlPrefix + job.requestID
+		status, err := submitUpload(queue, store, job, jobURL)
+		if errors.Is(err, ErrQueueFull) {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds))
+			writeError(w, asJSON, "Server busy, try again later.", http.StatusServiceUnavailable)
+			return
 		}
+		if err != nil {
+			writeError(w, asJSON, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if asJSON {
+			w.Header().Set("Location", jobURL)
+			writeJSON(w, http.StatusAccepted, status)
+			return
+		}
+
+		w.Header().Add("X-Status-URL", jobURL)
+
+		w.WriteHeader(http.StatusAccepted)
+
+		// Progress is streamed over SSE; the final "done" event swaps in the result
+		fmt.Fprintf(w, `
+    <div class="job" hx-ext="sse" sse-connect="%s/events" sse-close="done">
+      <h3 role="status" id="pblabel" tabindex="-1" autofocus>Accepted, Running Operation</h3>%s
+      <div sse-swap="progress" aria-labelledby="pblabel"><progress max="100" value="0"></progress> <span>Queued</span></div>
+      <div hx-trigger="sse:done" hx-get="%s" hx-target="closest .job" hx-swap="outerHTML"></div>
+      <button hx-delete="%s" hx-swap="none">Cancel</button>
+    </div>`, jobURL, detectedTrackHTML(job.trackChoice)+warningsHTML(job.warnings), jobURL, jobURL)
 	}
+}
 
-	ctx, cancel := context.WithTimeout(ctx, time.Second*50)
-	defer cancel()
+// detectedTrackHTML explains an auto-detected melody track, if any
+func detectedTrackHTML(choice *fonspeak_midi.TrackCandidate) string {
+	if choice == nil {
+		return ""
+	}
+	return fmt.Sprintf(`
+      <p>Singing %s: %s</p>`, choice.Source(), html.EscapeString(strings.Join(choice.Reasons, "; ")))
+}
 
-	_, err = client.PutObject(ctx, bucket, object, file, file.Size(), minio.PutObjectOptions{ContentType: "audio/wav"})
-	if err != nil {
-		return "", err
+// warningsHTML lists problems found in the uploaded file, if any
+func warningsHTML(warnings []string) string {
+	if len(warnings) == 0 {
+		return ""
 	}
+	var b strings.Builder
+	b.WriteString(`
+      <ul class="warnings">`)
+	for _, w := range warnings {
+		fmt.Fprintf(&b, "<li>%s</li>", html.EscapeString(w))
+	}
+	b.WriteString("</ul>")
+	return b.String()
+}
 
-	uri, err := client.PresignedGetObject(ctx, bucket, object, time.Hour, url.Values{"ContentType": {"audio/wav"}})
-	if err != nil {
-		return "", err
+func warningStrings(warnings []fonspeak_midi.Warning) []string {
+	var s []string
+	for _, w := range warnings {
+		s = append(s, w.String())
 	}
+	return s
+}
 
-	fmt.Printf("File %s uploaded successfully", fileName)
-	return uri.String(), err
+// UploadMidiHandler accepts uploads from the web form, answering with an
+// htmx fragment that follows the job's progress
+func UploadMidiHandler(queue *JobQueue, store JobStore) func(http.ResponseWriter, *http.Request) {
+	return uploadHandler(queue, store, "/api/status/", false)
 }
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+	"github.com/sammyshear/adon-olam/internal/lyrics"
+)
+
+// lyricsPreview compares the syllables of the lyrics of an upload with the
+// notes of its melody, before the job is submitted
+type lyricsPreview struct {
+	Syllables     int    `json:"syllables"`
+	Format        string `json:"format"` // "hebrew", "ipa" or "x-sampa"
+	Pronunciation string `json:"pronunciation"`
+	// Notes is the number of notes of the melody, if a file was sent
+	Notes int `json:"notes,omitempty"`
+	// Source names the track and channel the notes were counted on
+	Source string `json:"source,omitempty"`
+}
+
+// PreviewLyricsHandler reads the lyrics of the upload form, or its catalog
+// text, and counts their syllables against the notes of the chosen track,
+// if a file was chosen. htmx requests get a line of text for the form,
+// telling of any problem with the lyrics instead; JSON clients get the
+// counts, or a 400 for invalid lyrics.
+func PreviewLyricsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asJSON := wantsJSON(r)
+
+		preview, err := previewLyrics(r)
+		if asJSON {
+			if err != nil {
+				writeError(w, asJSON, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, http.StatusOK, preview)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, previewHTML(preview, err))
+	}
+}
+
+// previewLyrics counts the syllables of the form's lyrics and the notes of
+// its melody
+func previewLyrics(r *http.Request) (lyricsPreview, error) {
+	song, err := parseLyrics(r)
+	if err != nil {
+		return lyricsPreview{}, err
+	}
+	preview := lyricsPreview{
+		Syllables:     len(song.syllables),
+		Format:        lyrics.FormatHebrew,
+		Pronunciation: string(song.pronunciation),
+	}
+	if song.custom != "" {
+		preview.Format = lyrics.Format(song.custom)
+	}
+
+	_, midiBytes, err := readMidiUpload(r)
+	if errors.Is(err, http.ErrMissingFile) {
+		return preview, nil
+	}
+	if err != nil {
+		return lyricsPreview{}, err
+	}
+	score, err := fonspeak_midi.ReadScore(r.Context(), bytes.NewReader(midiBytes))
+	if err != nil {
+		return lyricsPreview{}, err
+	}
+
+	// The track and chords are picked as the upload would pick them
+	choice := fonspeak_midi.TrackCandidate{Track: fonspeak_midi.AutoTrack}
+	if v := r.FormValue("trackNo"); v != "" {
+		if choice.Track, err = fonspeak_midi.ParseTrack(v); err != nil {
+			return lyricsPreview{}, err
+		}
+	}
+	if choice.Channel, err = fonspeak_midi.ParseChannel(r.FormValue("channel")); err != nil {
+		return lyricsPreview{}, err
+	}
+	if choice.Track == fonspeak_midi.AutoTrack {
+		if choice, err = score.DetectMelodyTrack(preview.Syllables, choice.Channel); err != nil {
+			return lyricsPreview{}, err
+		}
+	}
+
+	reduction, err := parseChordReduction(r)
+	if err != nil {
+		return lyricsPreview{}, err
+	}
+	notes, err := score.ChannelMelody(choice.Track, choice.Channel, reduction)
+	if err != nil {
+		return lyricsPreview{}, err
+	}
+	preview.Notes = fonspeak_midi.CountPitchedNotes(notes)
+	preview.Source = choice.Source()
+	if choice.Track == fonspeak_midi.AllTracks {
+		preview.Source = "all tracks"
+	}
+	return preview, nil
+}
+
+// previewHTML describes how the lyrics fit the melody, e.g. "160 syllables
+// (hebrew) for 96 notes of track 1: the melody repeats to fit them", for
+// the preview under the lyrics field of the upload form
+func previewHTML(preview lyricsPreview, err error) string {
+	if err != nil {
+		return fmt.Sprintf(`<span class="error">%s</span>`, html.EscapeString(err.Error()))
+	}
+	text := fmt.Sprintf("%d syllables (%s)", preview.Syllables, preview.Format)
+	switch {
+	case preview.Source == "":
+	case preview.Notes == 0:
+		text += fmt.Sprintf(", but %s has no notes", preview.Source)
+	case preview.Syllables > preview.Notes:
+		text += fmt.Sprintf(" for %d notes of %s: the melody repeats to fit them", preview.Notes, preview.Source)
+	case preview.Syllables < preview.Notes:
+		text += fmt.Sprintf(" for %d notes of %s: vowels are held over the extra notes", preview.Notes, preview.Source)
+	default:
+		text += fmt.Sprintf(" for %d notes of %s: one syllable per note", preview.Notes, preview.Source)
+	}
+	return html.EscapeString(text)
+}
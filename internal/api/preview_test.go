@@ -0,0 +1,214 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// lyricsRequest builds an upload form with the given fields, the test MIDI
+// file if withMIDI is set and lyricsFile as an uploaded lyrics file if it
+// is not empty
+func lyricsRequest(t *testing.T, url string, withMIDI bool, lyricsFile string, fields map[string]string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if withMIDI {
+		fw, err := mw.CreateFormFile("uploadFile", "tune.mid")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(testMIDI)
+	}
+	if lyricsFile != "" {
+		fw, err := mw.CreateFormFile("lyricsFile", "lyrics.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(lyricsFile))
+	}
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	mw.Close()
+
+	req, err := http.NewRequest(http.MethodPost, url, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestPreviewLyricsHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(PreviewLyricsHandler()))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		withMIDI   bool
+		lyricsFile string
+		fields     map[string]string
+		want       lyricsPreview
+	}{
+		{
+			name: "default text",
+			want: lyricsPreview{Syllables: 160, Format: "hebrew", Pronunciation: "modern"},
+		},
+		{
+			name:   "catalog text in its pronunciation",
+			fields: map[string]string{"text": "anim-zemirot"},
+			want:   lyricsPreview{Syllables: 643, Format: "hebrew", Pronunciation: "ashkenazi"},
+		},
+		{
+			name:   "typed lyrics",
+			fields: map[string]string{"lyrics": "a don o ləm"},
+			want:   lyricsPreview{Syllables: 4, Format: "ipa", Pronunciation: "modern"},
+		},
+		{
+			name:       "lyrics file with melody",
+			withMIDI:   true,
+			lyricsFile: "a don\n",
+			fields:     map[string]string{"trackNo": "0"},
+			want:       lyricsPreview{Syllables: 2, Format: "x-sampa", Pronunciation: "modern", Notes: 1, Source: "track 0"},
+		},
+		{
+			name:     "detected track",
+			withMIDI: true,
+			fields:   map[string]string{"lyrics": "la", "trackNo": "auto", "channel": "1"},
+			want:     lyricsPreview{Syllables: 1, Format: "x-sampa", Pronunciation: "modern", Notes: 1, Source: "track 0, channel 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := lyricsRequest(t, srv.URL, tt.withMIDI, tt.lyricsFile, tt.fields)
+			req.Header.Set("Accept", "application/json")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+			if got := decodeJSON[lyricsPreview](t, resp); got != tt.want {
+				t.Errorf("preview = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("htmx", func(t *testing.T) {
+		req := lyricsRequest(t, srv.URL, true, "", map[string]string{"lyrics": "la"})
+		req.Header.Set("HX-Request", "true")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if want := "1 syllables (x-sampa) for 1 notes of track 0: one syllable per note"; string(body) != want {
+			t.Errorf("body = %s, want %s", body, want)
+		}
+	})
+
+	t.Run("invalid lyrics", func(t *testing.T) {
+		req := lyricsRequest(t, srv.URL, false, "", map[string]string{"lyrics": "a d0n"})
+		req.Header.Set("HX-Request", "true")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || !strings.HasPrefix(string(body), `<span class="error">invalid lyrics: `) {
+			t.Errorf("status = %d, body = %s, want the error in the preview", resp.StatusCode, body)
+		}
+
+		req = lyricsRequest(t, srv.URL, false, "", map[string]string{"lyrics": "a d0n"})
+		req.Header.Set("Accept", "application/json")
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("JSON status = %d, want 400", resp.StatusCode)
+		}
+	})
+}
+
+func TestPreviewLyricsHandler_ChordReduction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(PreviewLyricsHandler()))
+	defer srv.Close()
+
+	// A C major third rolled over 20ms, then a D
+	rolled := []byte{
+		'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0x03, 0xC0,
+		'M', 'T', 'r', 'k', 0, 0, 0, 30,
+		0x00, 0x90, 60, 100,
+		0x26, 0x90, 64, 100,
+		0x87, 0x1A, 0x80, 60, 0,
+		0x00, 0x80, 64, 0,
+		0x00, 0x90, 62, 100,
+		0x87, 0x40, 0x80, 62, 0,
+		0x00, 0xFF, 0x2F, 0x00,
+	}
+
+	tests := []struct {
+		name       string
+		fields     map[string]string
+		wantStatus int
+		wantNotes  int
+	}{
+		{name: "default", wantStatus: http.StatusOK, wantNotes: 3},
+		{name: "wider threshold", fields: map[string]string{"chordPolicy": "highest", "chordThreshold": "50"}, wantStatus: http.StatusOK, wantNotes: 2},
+		{name: "bad policy", fields: map[string]string{"chordPolicy": "median"}, wantStatus: http.StatusBadRequest},
+		{name: "bad voice", fields: map[string]string{"chordPolicy": "voice", "chordVoice": "0"}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := map[string]string{"lyrics": "la", "trackNo": "0"}
+			for k, v := range tt.fields {
+				fields[k] = v
+			}
+			req := uploadFileRequest(t, srv.URL, "rolled.mid", rolled, fields)
+			req.Header.Set("Accept", "application/json")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				resp.Body.Close()
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				resp.Body.Close()
+				return
+			}
+			if got := decodeJSON[lyricsPreview](t, resp); got.Notes != tt.wantNotes {
+				t.Errorf("notes = %d, want %d", got.Notes, tt.wantNotes)
+			}
+		})
+	}
+}
+
+func TestPreviewHTML(t *testing.T) {
+	tests := []struct {
+		preview lyricsPreview
+		want    string
+	}{
+		{lyricsPreview{Syllables: 160, Format: "hebrew"}, "160 syllables (hebrew)"},
+		{lyricsPreview{Syllables: 160, Format: "hebrew", Notes: 96, Source: "track 1"}, "160 syllables (hebrew) for 96 notes of track 1: the melody repeats to fit them"},
+		{lyricsPreview{Syllables: 4, Format: "ipa", Notes: 6, Source: "track 1, channel 2"}, "4 syllables (ipa) for 6 notes of track 1, channel 2: vowels are held over the extra notes"},
+		{lyricsPreview{Syllables: 4, Format: "x-sampa", Source: "track 0"}, "4 syllables (x-sampa), but track 0 has no notes"},
+	}
+	for _, tt := range tests {
+		if got := previewHTML(tt.preview, nil); got != tt.want {
+			t.Errorf("previewHTML(%+v) = %q, want %q", tt.preview, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sammyshear/adon-olam/internal/pipeline"
+)
+
+// stageUpload is reported while the rendered audio is being stored, after
+// the pipeline stages have finished
+const stageUpload pipeline.Stage = "uploading"
+
+// stagePercent is the overall progress reached when each stage starts.
+// Synthesis takes most of the time, so it spans 20-90% by syllables sung.
+var stagePercent = map[pipeline.Stage]int{
+	pipeline.StageValidate:   0,
+	pipeline.StageParse:      5,
+	pipeline.StageAlign:      10,
+	pipeline.StageTiming:     15,
+	pipeline.StageSynthesize: 20,
+	stageUpload:              90,
+}
+
+// Progress is a snapshot of how far a job has got
+type Progress struct {
+	State    string         `json:"state"`
+	Position int            `json:"position,omitempty"` // 1-based queue position while queued
+	Stage    pipeline.Stage `json:"stage,omitempty"`
+	Done     int            `json:"done,omitempty"`  // Items finished in the current stage
+	Total    int            `json:"total,omitempty"` // Items in the current stage, 0 if not counted
+	Percent  int            `json:"percent"`
+}
+
+// stageProgress returns the progress of a running job that is in stage,
+// having finished done of total items
+func stageProgress(stage pipeline.Stage, done, total int) Progress {
+	p := Progress{State: StateRunning, Stage: stage, Done: done, Total: total, Percent: stagePercent[stage]}
+	if stage == pipeline.StageSynthesize && total > 0 {
+		p.Percent += (stagePercent[stageUpload] - stagePercent[pipeline.StageSynthesize]) * done / total
+	}
+	return p
+}
+
+// Label describes the progress for people
+func (p Progress) Label() string {
+	switch {
+	case p.State == StateQueued && p.Position > 0:
+		return fmt.Sprintf("Queued, position %d", p.Position)
+	case p.State == StateQueued:
+		return "Queued"
+	case p.State != StateRunning:
+		return strings.ToLower(p.State)
+	case p.Stage == "":
+		return "Starting"
+	case p.Total > 0:
+		return fmt.Sprintf("%s %d/%d syllables", capitalize(string(p.Stage)), p.Done, p.Total)
+	default:
+		return capitalize(string(p.Stage))
+	}
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// progressHub fans out job progress to subscribers, keeping the latest
+// update of each running job for anyone who subscribes late
+type progressHub struct {
+	mu     sync.Mutex
+	latest map[string]Progress
+	subs   map[string]map[chan Progress]struct{}
+}
+
+func newProgressHub() *progressHub {
+	return &progressHub{
+		latest: map[string]Progress{},
+		subs:   map[string]map[chan Progress]struct{}{},
+	}
+}
+
+// publish sends p to every subscriber of the job. Slow subscribers only
+// ever see the newest update.
+func (h *progressHub) publish(id string, p Progress) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if p.State == StateRunning {
+		h.latest[id] = p
+	} else {
+		delete(h.latest, id)
+	}
+
+	for ch := range h.subs[id] {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- p
+	}
+}
+
+// subscribe returns a channel of updates for a job along with the latest one
+// published, if any. The returned func must be called to unsubscribe.
+func (h *progressHub) subscribe(id string) (<-chan Progress, Progress, bool, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan Progress, 1)
+	if h.subs[id] == nil {
+		h.subs[id] = map[chan Progress]struct{}{}
+	}
+	h.subs[id][ch] = struct{}{}
+
+	latest, ok := h.latest[id]
+	return ch, latest, ok, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs[id], ch)
+		if len(h.subs[id]) == 0 {
+			delete(h.subs, id)
+		}
+	}
+}
+
+// writeEvent writes a single Server-Sent Event and flushes it to the client
+func writeEvent(w http.ResponseWriter, event, data string) {
+	fmt.Fprintf(w, "event: %s\n", event)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// progressHTML renders progress as the fragment swapped into the page
+func progressHTML(p Progress) string {
+	return fmt.Sprintf(`<progress max="100" value="%d"></progress> <span>%s (%d%%)</span>`,
+		p.Percent, html.EscapeString(p.Label()), p.Percent)
+}
+
+// queuedProgress reports a job waiting for a worker
+func queuedProgress(status JobStatus) Progress {
+	return Progress{State: StateQueued, Position: status.QueuePosition}
+}
+
+// JobEventsHandler streams a job's progress as Server-Sent Events. Each
+// "progress" event carries an HTML fragment with a progress bar, and a
+// final "done" event is sent once the job has finished.
+func JobEventsHandler(queue *JobQueue) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.PathValue("requestID")
+
+		updates, latest, running, unsubscribe := queue.progress.subscribe(requestID)
+		defer unsubscribe()
+
+		status, err := queue.Status(requestID)
+		if err != nil {
+			statusError(w, false, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		if status.IsTerminal() {
+			writeEvent(w, "done", status.State)
+			return
+		}
+
+		send := func(p Progress) {
+			writeEvent(w, "progress", progressHTML(p))
+		}
+		if running {
+			send(latest)
+		} else if status.State == StateQueued {
+			send(queuedProgress(status))
+		} else {
+			send(Progress{State: StateRunning})
+		}
+
+		// Queue positions change without an event, so poll them while waiting
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case p := <-updates:
+				if p.State != StateRunning {
+					if p.State == StateCompleted {
+						send(p)
+					}
+					writeEvent(w, "done", p.State)
+					return
+				}
+				send(p)
+			case <-ticker.C:
+				status, err := queue.Status(requestID)
+				if err != nil {
+					return
+				}
+				if status.IsTerminal() {
+					writeEvent(w, "done", status.State)
+					return
+				}
+				if status.State == StateQueued {
+					send(queuedProgress(status))
+				}
+			}
+		}
+	}
+}
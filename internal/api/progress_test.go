@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sammyshear/adon-olam/internal/pipeline"
+	"github.com/sammyshear/adon-olam/internal/synth"
+	"github.com/sammyshear/adon-olam/internal/wav"
+)
+
+func TestStageProgress(t *testing.T) {
+	tests := []struct {
+		stage       pipeline.Stage
+		done, total int
+		wantPercent int
+		wantLabel   string
+	}{
+		{pipeline.StageParse, 0, 0, 5, "Parsing"},
+		{pipeline.StageSynthesize, 0, 10, 20, "Synthesizing 0/10 syllables"},
+		{pipeline.StageSynthesize, 5, 10, 55, "Synthesizing 5/10 syllables"},
+		{pipeline.StageSynthesize, 10, 10, 90, "Synthesizing 10/10 syllables"},
+		{stageUpload, 0, 0, 90, "Uploading"},
+	}
+
+	for _, tt := range tests {
+		p := stageProgress(tt.stage, tt.done, tt.total)
+		if p.Percent != tt.wantPercent {
+			t.Errorf("stageProgress(%s, %d, %d).Percent = %d, want %d", tt.stage, tt.done, tt.total, p.Percent, tt.wantPercent)
+		}
+		if p.Label() != tt.wantLabel {
+			t.Errorf("stageProgress(%s, %d, %d).Label() = %q, want %q", tt.stage, tt.done, tt.total, p.Label(), tt.wantLabel)
+		}
+	}
+}
+
+func TestJobEventsHandler(t *testing.T) {
+	store := NewMemoryJobStore()
+	release := make(chan struct{})
+	reporting := funcSynthesizer(func(ctx context.Context, plan synth.Plan) (*wav.Audio, error) {
+		plan.OnProgress(1, plan.SyllableCount())
+		<-release
+		return nil, errors.New("stopped")
+	})
+
+	q := NewJobQueue(1, 1, 0, store, reporting, newTestObjects(t))
+	defer q.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status/{requestID}/events", JobEventsHandler(q))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	if err := submitTestJob(t, q, store, "job"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.Get(srv.URL + "/api/status/job/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// Read events until synthesis progress shows up, then let the job finish
+	var events []string
+	sawSynthesis := false
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if event, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, event)
+			if event == "done" {
+				break
+			}
+		}
+		if strings.Contains(line, "Synthesizing 1/") && !sawSynthesis {
+			sawSynthesis = true
+			close(release)
+		}
+	}
+
+	if !sawSynthesis {
+		t.Errorf("no synthesis progress in events %v", events)
+	}
+	if len(events) == 0 || events[len(events)-1] != "done" {
+		t.Errorf("events = %v, want a final done event", events)
+	}
+
+	if resp, err := http.Get(srv.URL + "/api/status/missing/events"); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown job events = %v, %v, want 404", resp, err)
+	}
+}
@@ -0,0 +1,461 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+	"github.com/sammyshear/adon-olam/internal/lyrics"
+	"github.com/sammyshear/adon-olam/internal/pipeline"
+	"github.com/sammyshear/adon-olam/internal/storage"
+	"github.com/sammyshear/adon-olam/internal/synth"
+	"github.com/sammyshear/adon-olam/internal/timing"
+)
+
+// Queue defaults used when RENDER_WORKERS / RENDER_QUEUE_SIZE / RENDER_TIMEOUT are unset
+const (
+	defaultWorkers   = 2
+	defaultQueueSize = 16
+	defaultTimeout   = 5 * time.Minute
+)
+
+var (
+	// ErrQueueFull is returned by Submit when no more jobs can be accepted
+	ErrQueueFull = errors.New("render queue is full")
+	// ErrJobFinished is returned by Cancel for jobs that have already finished
+	ErrJobFinished = errors.New("job has already finished")
+	// ErrNoResult is returned by RefreshResultURL for jobs without stored audio
+	ErrNoResult = errors.New("job has no stored result")
+)
+
+// renderJob holds a MIDI upload waiting to be rendered
+type renderJob struct {
+	requestID      string                        // Unique identifier for this request
+	fileName       string                        // Original name of the uploaded file
+	midi           []byte                        // The uploaded MIDI file
+	trackNo        int                           // MIDI track number to process
+	trackChoice    *fonspeak_midi.TrackCandidate // Why trackNo was chosen, if it was detected
+	channel        int                           // 1-based MIDI channel of the melody, 0 for all
+	voice          string                        // Synthesis voice
+	maxHz          float64                       // Pitch cap for the global octave drop
+	tempoScale     float64                       // Playback speed relative to the MIDI tempo map
+	timingStrategy timing.TimingStrategy         // Timing strategy: "per-syllable" or "last-phoneme"
+	chordReduction fonspeak_midi.ChordReduction  // How chords collapse to one note
+	alignment      fonspeak_midi.AlignmentMode   // How syllables are assigned to notes
+	dynamics       synth.DynamicsCurve           // How note dynamics set the loudness
+	textID         string                        // Catalog text to sing, empty for the default
+	customLyrics   string                        // Lyrics sent with the upload, sung instead of the text
+	pronunciation  lyrics.Pronunciation          // Tradition the Hebrew lyrics are read in
+	warnings       []string                      // Problems found reading the selected notes
+}
+
+// syllables reads the job's lyrics, or its catalog text, into syllables
+func (job renderJob) syllables() ([]string, error) {
+	if job.customLyrics != "" {
+		return lyrics.Parse(job.customLyrics, job.pronunciation)
+	}
+	text, err := lyrics.Lookup(job.textID)
+	if err != nil {
+		return nil, err
+	}
+	return text.Syllables(job.pronunciation)
+}
+
+// JobQueue runs render jobs on a fixed pool of workers fed by a bounded queue
+type JobQueue struct {
+	jobs        chan renderJob
+	store       JobStore
+	synthesizer synth.Synthesizer
+	objects     storage.ObjectStore
+	timeout     time.Duration
+	progress    *progressHub
+	wg          sync.WaitGroup
+
+	mu      sync.Mutex
+	pending []string                      // IDs of queued jobs in submission order
+	running map[string]context.CancelFunc // Cancels the context of each job being rendered
+}
+
+// NewJobQueue starts workers goroutines that render jobs from a queue
+// holding at most size jobs and store the results in objects. Each job is
+// cancelled after timeout, or never if timeout is zero.
+func NewJobQueue(workers, size int, timeout time.Duration, store JobStore, synthesizer synth.Synthesizer, objects storage.ObjectStore) *JobQueue {
+	q := &JobQueue{
+		jobs:        make(chan renderJob, size),
+		store:       store,
+		synthesizer: synthesizer,
+		objects:     objects,
+		timeout:     timeout,
+		progress:    newProgressHub(),
+		running:     map[string]context.CancelFunc{},
+	}
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+
+	return q
+}
+
+// NewJobQueueFromEnv sizes the pool from RENDER_WORKERS and RENDER_QUEUE_SIZE
+// and limits each job to RENDER_TIMEOUT (a Go duration such as "90s")
+func NewJobQueueFromEnv(store JobStore, synthesizer synth.Synthesizer, objects storage.ObjectStore) (*JobQueue, error) {
+	workers, err := intFromEnv("RENDER_WORKERS", defaultWorkers)
+	if err != nil {
+		return nil, err
+	}
+	size, err := intFromEnv("RENDER_QUEUE_SIZE", defaultQueueSize)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := durationFromEnv("RENDER_TIMEOUT", defaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewJobQueue(workers, size, timeout, store, synthesizer, objects), nil
+}
+
+// intFromEnv reads a positive integer from the environment
+func intFromEnv(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", key, v)
+	}
+	return n, nil
+}
+
+// durationFromEnv reads a non-negative duration from the environment
+func durationFromEnv(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative duration, got %q", key, v)
+	}
+	return d, nil
+}
+
+// Submit queues a job without blocking, returning ErrQueueFull if there is no room
+func (q *JobQueue) Submit(job renderJob) error {
+	// Hold the lock across the send so pending always matches channel order
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	select {
+	case q.jobs <- job:
+		q.pending = append(q.pending, job.requestID)
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Position returns the 1-based position of a job in the queue, or 0 if the
+// job is not waiting
+func (q *JobQueue) Position(id string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, pendingID := range q.pending {
+		if pendingID == id {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Status returns the stored status of a job with its queue position filled in
+func (q *JobQueue) Status(id string) (JobStatus, error) {
+	status, err := q.store.Get(id)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	if status.State == StateQueued {
+		status.QueuePosition = q.Position(id)
+	}
+	return status, nil
+}
+
+// setState records a new state for a job and tells progress subscribers
+func (q *JobQueue) setState(id, state, message string) {
+	setState(q.store, id, state, message)
+
+	p := Progress{State: state}
+	if state == StateCompleted {
+		p.Percent = 100
+	}
+	q.progress.publish(id, p)
+}
+
+// Cancel stops a job. A queued job is marked cancelled straight away and
+// skipped by the workers; a running job has its context cancelled and is
+// marked cancelled by its worker once the render stops.
+func (q *JobQueue) Cancel(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status, err := q.store.Get(id)
+	if err != nil {
+		return err
+	}
+	if status.IsTerminal() {
+		return ErrJobFinished
+	}
+
+	if cancel, ok := q.running[id]; ok {
+		cancel()
+		return nil
+	}
+
+	q.removePending(id)
+	q.setState(id, StateCancelled, "Render cancelled.")
+	return nil
+}
+
+// Close stops accepting jobs and waits for the workers to drain the queue
+func (q *JobQueue) Close() {
+	close(q.jobs)
+	q.wg.Wait()
+}
+
+func (q *JobQueue) worker() {
+	defer q.wg.Done()
+	for job := range q.jobs {
+		ctx, ok := q.start(job.requestID)
+		if !ok {
+			continue
+		}
+		q.process(ctx, job)
+		q.finish(job.requestID)
+	}
+}
+
+// start takes a job a worker has picked up off the pending list and
+// registers its context, reporting false if the job was cancelled while queued
+func (q *JobQueue) start(id string) (context.Context, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.removePending(id)
+
+	status, err := q.store.Get(id)
+	if err != nil {
+		log.Printf("Failed to load job %s: %s", id, err)
+		return nil, false
+	}
+	if status.State != StateQueued {
+		return nil, false
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if q.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), q.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	q.running[id] = cancel
+	return ctx, true
+}
+
+// finish releases the context of a job once its worker is done with it
+func (q *JobQueue) finish(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if cancel, ok := q.running[id]; ok {
+		cancel()
+		delete(q.running, id)
+	}
+}
+
+// removePending drops a job from the pending list, q.mu must be held
+func (q *JobQueue) removePending(id string) {
+	for i, pendingID := range q.pending {
+		if pendingID == id {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// failJob records why a render stopped, telling cancellation and timeouts
+// apart from other failures
+func (q *JobQueue) failJob(id string, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		q.setState(id, StateCancelled, "Render cancelled.")
+	case errors.Is(err, context.DeadlineExceeded):
+		q.setState(id, StateTimedOut, fmt.Sprintf("Render timed out after %s.", q.timeout))
+	default:
+		q.setState(id, StateErrored, err.Error())
+	}
+}
+
+// process renders a single job, recovering from panics so one bad file
+// cannot take down the worker
+func (q *JobQueue) process(ctx context.Context, job renderJob) {
+	id := job.requestID
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Render job %s panicked: %v", id, r)
+			q.setState(id, StateErrored, fmt.Sprintf("Internal error while rendering: %v", r))
+		}
+	}()
+
+	q.setState(id, StateRunning, "")
+
+	syllables, err := job.syllables()
+	if err != nil {
+		q.failJob(id, err)
+		return
+	}
+
+	// Identical renders share one object, so skip straight to it if it exists
+	key := q.resultKey(job, syllables)
+	exists, err := q.objects.Exists(ctx, key)
+	if err != nil {
+		q.failJob(id, err)
+		return
+	}
+	if exists {
+		log.Printf("Reusing %s for job %s", key, id)
+		q.complete(ctx, id, key, nil)
+		return
+	}
+
+	// Render the job's syllables through the shared pipeline
+	result, err := pipeline.Render(ctx, pipeline.RenderRequest{
+		MIDI:           bytes.NewReader(job.midi),
+		TrackNo:        job.trackNo,
+		Channel:        job.channel,
+		Syllables:      syllables,
+		Voice:          job.voice,
+		MaxHz:          job.maxHz,
+		TempoScale:     job.tempoScale,
+		TimingStrategy: job.timingStrategy,
+		ChordReduction: job.chordReduction,
+		Alignment:      job.alignment,
+		Dynamics:       job.dynamics,
+		Synthesizer:    q.synthesizer,
+		Hooks: pipeline.Hooks{
+			OnStageStart: func(stage pipeline.Stage) {
+				q.progress.publish(id, stageProgress(stage, 0, 0))
+			},
+			OnProgress: func(stage pipeline.Stage, done, total int) {
+				q.progress.publish(id, stageProgress(stage, done, total))
+			},
+		},
+	})
+	if err != nil {
+		q.failJob(id, fmt.Errorf("failed to render: %w", err))
+		return
+	}
+
+	wavBytes, err := result.Audio.Bytes()
+	if err != nil {
+		q.setState(id, StateErrored, err.Error())
+		return
+	}
+
+	q.progress.publish(id, stageProgress(stageUpload, 0, 0))
+	uploadStart := time.Now()
+	err = q.objects.Put(ctx, key, bytes.NewReader(wavBytes), int64(len(wavBytes)), "audio/wav")
+	if err != nil {
+		q.failJob(id, err)
+		return
+	}
+	log.Printf("File %s uploaded successfully", key)
+
+	timings := map[pipeline.Stage]float64{stageUpload: time.Since(uploadStart).Seconds()}
+	for stage, elapsed := range result.Timings {
+		timings[stage] = elapsed.Seconds()
+	}
+
+	q.complete(ctx, id, key, timings)
+}
+
+// resultKey names the object a render is stored under by hashing everything
+// that affects the output, so identical requests share one object and
+// different ones never collide
+func (q *JobQueue) resultKey(job renderJob, syllables []string) string {
+	h := sha256.New()
+	// The backend type keeps renders from different synthesizers apart
+	fmt.Fprintf(h, "%T\n%d\n%d\n%q\n%g\n%g\n%q\n%+v\n%q\n%q\n%q\n", q.synthesizer, job.trackNo, job.channel, job.voice, job.maxHz, job.tempoScale, job.timingStrategy, job.chordReduction, job.alignment, job.dynamics, syllables)
+	h.Write(job.midi)
+	return "renders/" + hex.EncodeToString(h.Sum(nil)) + ".wav"
+}
+
+// complete links a job to its stored result and marks it completed
+func (q *JobQueue) complete(ctx context.Context, id, key string, timings map[pipeline.Stage]float64) {
+	link, err := q.objects.URL(ctx, key)
+	if err != nil {
+		q.failJob(id, err)
+		return
+	}
+
+	// Cancel checks and cancels a job under q.mu, so holding it here means
+	// a job cancelled once its render is done ends cancelled, not completed
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		q.failJob(id, err)
+		return
+	}
+
+	completed := false
+	_, err = q.store.Update(id, func(s *JobStatus) {
+		if s.IsTerminal() {
+			return
+		}
+		s.State = StateCompleted
+		s.ResultKey = key
+		s.setResultLink(link)
+		s.Timings = timings
+		completed = true
+	})
+	if err != nil {
+		log.Printf("Failed to update job %s: %s", id, err)
+	}
+	if completed {
+		q.progress.publish(id, Progress{State: StateCompleted, Percent: 100})
+	}
+}
+
+// RefreshResultURL issues a new download link for a completed job, for
+// when the previous one has expired
+func (q *JobQueue) RefreshResultURL(ctx context.Context, id string) (JobStatus, error) {
+	status, err := q.store.Get(id)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	if status.State != StateCompleted || status.ResultKey == "" {
+		return JobStatus{}, ErrNoResult
+	}
+
+	link, err := q.objects.URL(ctx, status.ResultKey)
+	if err != nil {
+		return JobStatus{}, err
+	}
+
+	return q.store.Update(id, func(s *JobStatus) {
+		s.setResultLink(link)
+	})
+}
@@ -0,0 +1,350 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sammyshear/adon-olam/internal/storage"
+	"github.com/sammyshear/adon-olam/internal/synth"
+	"github.com/sammyshear/adon-olam/internal/wav"
+)
+
+// funcSynthesizer adapts a function to synth.Synthesizer
+type funcSynthesizer func(ctx context.Context, plan synth.Plan) (*wav.Audio, error)
+
+func (f funcSynthesizer) Synthesize(ctx context.Context, plan synth.Plan) (*wav.Audio, error) {
+	return f(ctx, plan)
+}
+
+// newTestObjects returns an object store in a temporary directory
+func newTestObjects(t *testing.T) *storage.LocalStore {
+	t.Helper()
+	objects, err := storage.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return objects
+}
+
+// testMIDI is a format-0 SMF with a single quarter-note middle C
+var testMIDI = []byte{
+	'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0x03, 0xC0,
+	'M', 'T', 'r', 'k', 0, 0, 0, 13,
+	0x00, 0x90, 60, 100,
+	0x87, 0x40, 0x80, 60, 0,
+	0x00, 0xFF, 0x2F, 0x00,
+}
+
+// waitForState polls the store until the job reaches a terminal state
+func waitForState(t *testing.T, store JobStore, id string) JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, err := store.Get(id)
+		if err != nil {
+			t.Fatalf("Get(%s) error: %v", id, err)
+		}
+		if status.IsTerminal() {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return JobStatus{}
+}
+
+func submitTestJob(t *testing.T, q *JobQueue, store JobStore, id string) error {
+	t.Helper()
+	if _, err := store.Create(JobStatus{ID: id, State: StateQueued}); err != nil {
+		t.Fatal(err)
+	}
+	return q.Submit(renderJob{requestID: id, fileName: id + ".mid", midi: testMIDI})
+}
+
+func TestJobQueue_FullAndPositions(t *testing.T) {
+	store := NewMemoryJobStore()
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	blocking := funcSynthesizer(func(context.Context, synth.Plan) (*wav.Audio, error) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		return nil, errors.New("stopped")
+	})
+
+	q := NewJobQueue(1, 2, 0, store, blocking, newTestObjects(t))
+	defer q.Close()
+	defer close(release)
+
+	// The first job occupies the only worker
+	if err := submitTestJob(t, q, store, "running"); err != nil {
+		t.Fatalf("Submit(running) error: %v", err)
+	}
+	<-started
+
+	// Two more fill the queue, the next is rejected
+	for _, id := range []string{"first", "second"} {
+		if err := submitTestJob(t, q, store, id); err != nil {
+			t.Fatalf("Submit(%s) error: %v", id, err)
+		}
+	}
+	if err := submitTestJob(t, q, store, "overflow"); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Submit(overflow) error = %v, want ErrQueueFull", err)
+	}
+
+	status, err := q.Status("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.QueuePosition != 2 {
+		t.Errorf("second job queue position = %d, want 2", status.QueuePosition)
+	}
+	if q.Position("running") != 0 {
+		t.Error("running job should not have a queue position")
+	}
+}
+
+func TestJobQueue_RecoversFromPanic(t *testing.T) {
+	store := NewMemoryJobStore()
+	calls := 0
+	flaky := funcSynthesizer(func(context.Context, synth.Plan) (*wav.Audio, error) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		return nil, errors.New("synth failed")
+	})
+
+	q := NewJobQueue(1, 4, 0, store, flaky, newTestObjects(t))
+	defer q.Close()
+
+	if err := submitTestJob(t, q, store, "panics"); err != nil {
+		t.Fatal(err)
+	}
+	if err := submitTestJob(t, q, store, "after"); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := waitForState(t, store, "panics"); status.State != StateErrored {
+		t.Errorf("panicking job state = %s, want %s", status.State, StateErrored)
+	}
+
+	// The worker must still be alive to pick up the next job
+	status := waitForState(t, store, "after")
+	if status.State != StateErrored || status.Message == "" {
+		t.Errorf("next job = %+v, want an errored job with the synth failure", status)
+	}
+}
+
+func TestJobQueue_Cancel(t *testing.T) {
+	store := NewMemoryJobStore()
+	started := make(chan struct{}, 1)
+	blocking := funcSynthesizer(func(ctx context.Context, _ synth.Plan) (*wav.Audio, error) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	q := NewJobQueue(1, 2, 0, store, blocking, newTestObjects(t))
+	defer q.Close()
+
+	if err := submitTestJob(t, q, store, "running"); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if err := submitTestJob(t, q, store, "queued"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A queued job is cancelled without ever reaching a worker
+	if err := q.Cancel("queued"); err != nil {
+		t.Fatalf("Cancel(queued) error: %v", err)
+	}
+	if q.Position("queued") != 0 {
+		t.Error("cancelled job should leave the queue")
+	}
+
+	// A running job is interrupted through its context
+	if err := q.Cancel("running"); err != nil {
+		t.Fatalf("Cancel(running) error: %v", err)
+	}
+	for _, id := range []string{"running", "queued"} {
+		if status := waitForState(t, store, id); status.State != StateCancelled {
+			t.Errorf("%s job state = %s, want %s", id, status.State, StateCancelled)
+		}
+	}
+
+	if err := q.Cancel("running"); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("Cancel() on a finished job error = %v, want ErrJobFinished", err)
+	}
+	if err := q.Cancel("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Cancel() on an unknown job error = %v, want ErrJobNotFound", err)
+	}
+}
+
+// hookedObjects calls onURL before linking to an object, to act between a
+// job's render and its completion
+type hookedObjects struct {
+	*storage.LocalStore
+	onURL func()
+}
+
+func (o hookedObjects) URL(ctx context.Context, key string) (storage.Link, error) {
+	o.onURL()
+	return o.LocalStore.URL(ctx, key)
+}
+
+func TestJobQueue_CancelBeforeCompletion(t *testing.T) {
+	store := NewMemoryJobStore()
+	silent := funcSynthesizer(func(context.Context, synth.Plan) (*wav.Audio, error) {
+		audio := wav.New(22050, 1)
+		audio.AppendSilence(0.1)
+		return audio, nil
+	})
+
+	var q *JobQueue
+	cancelErr := make(chan error, 1)
+	objects := hookedObjects{LocalStore: newTestObjects(t), onURL: func() {
+		cancelErr <- q.Cancel("late")
+	}}
+	q = NewJobQueue(1, 1, 0, store, silent, objects)
+	defer q.Close()
+
+	if err := submitTestJob(t, q, store, "late"); err != nil {
+		t.Fatal(err)
+	}
+	status := waitForState(t, store, "late")
+	if err := <-cancelErr; err != nil {
+		t.Fatalf("Cancel() error: %v", err)
+	}
+	if status.State != StateCancelled {
+		t.Errorf("job state = %s, want %s once Cancel succeeded", status.State, StateCancelled)
+	}
+}
+
+func TestJobQueue_Timeout(t *testing.T) {
+	store := NewMemoryJobStore()
+	stuck := funcSynthesizer(func(ctx context.Context, _ synth.Plan) (*wav.Audio, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	q := NewJobQueue(1, 1, 50*time.Millisecond, store, stuck, newTestObjects(t))
+	defer q.Close()
+
+	if err := submitTestJob(t, q, store, "slow"); err != nil {
+		t.Fatal(err)
+	}
+	if status := waitForState(t, store, "slow"); status.State != StateTimedOut {
+		t.Errorf("job state = %s, want %s", status.State, StateTimedOut)
+	}
+}
+
+func TestJobQueue_CompletesAndReusesResults(t *testing.T) {
+	store := NewMemoryJobStore()
+	renders := 0
+	silent := funcSynthesizer(func(context.Context, synth.Plan) (*wav.Audio, error) {
+		renders++
+		audio := wav.New(22050, 1)
+		audio.AppendSilence(0.1)
+		return audio, nil
+	})
+	q := NewJobQueue(1, 4, 0, store, silent, newTestObjects(t))
+	defer q.Close()
+
+	// Two uploads of the same file render once, a different voice renders again
+	for _, id := range []string{"first", "same"} {
+		if err := submitTestJob(t, q, store, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Create(JobStatus{ID: "other", State: StateQueued}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Submit(renderJob{requestID: "other", fileName: "first.mid", midi: testMIDI, voice: "en"}); err != nil {
+		t.Fatal(err)
+	}
+
+	first := waitForState(t, store, "first")
+	same := waitForState(t, store, "same")
+	other := waitForState(t, store, "other")
+	for _, status := range []JobStatus{first, same, other} {
+		if status.State != StateCompleted {
+			t.Fatalf("job = %+v, want completed", status)
+		}
+		if !strings.HasPrefix(status.ResultKey, "renders/") || status.ResultURL != storage.MediaPrefix+status.ResultKey {
+			t.Errorf("job %s ResultKey = %q, ResultURL = %q, want a hashed key served under %s", status.ID, status.ResultKey, status.ResultURL, storage.MediaPrefix)
+		}
+	}
+
+	if same.ResultKey != first.ResultKey {
+		t.Errorf("identical renders stored as %q and %q, want one object", first.ResultKey, same.ResultKey)
+	}
+	if other.ResultKey == first.ResultKey {
+		t.Error("renders with different voices share an object")
+	}
+	if renders != 2 {
+		t.Errorf("synthesized %d times, want 2", renders)
+	}
+	if _, ok := first.Timings[stageUpload]; !ok {
+		t.Errorf("timings = %v, want the upload stage timed", first.Timings)
+	}
+}
+
+func TestJobQueue_SingsCustomLyrics(t *testing.T) {
+	store := NewMemoryJobStore()
+	sung := make(chan int, 1)
+	counting := funcSynthesizer(func(_ context.Context, plan synth.Plan) (*wav.Audio, error) {
+		sung <- plan.SyllableCount()
+		audio := wav.New(22050, 1)
+		audio.AppendSilence(0.1)
+		return audio, nil
+	})
+	q := NewJobQueue(1, 4, 0, store, counting, newTestObjects(t))
+	defer q.Close()
+
+	if _, err := store.Create(JobStatus{ID: "custom", State: StateQueued}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Submit(renderJob{requestID: "custom", fileName: "custom.mid", midi: testMIDI, textID: "yigdal", customLyrics: "la li lu"}); err != nil {
+		t.Fatal(err)
+	}
+	if status := waitForState(t, store, "custom"); status.State != StateCompleted {
+		t.Fatalf("job = %+v, want completed", status)
+	}
+	if got := <-sung; got != 3 {
+		t.Errorf("sang %d syllables, want the 3 of the custom lyrics", got)
+	}
+}
+
+func TestJobQueue_RefreshResultURL(t *testing.T) {
+	store := NewMemoryJobStore()
+	q := NewJobQueue(1, 1, 0, store, synth.NewSine(), newTestObjects(t))
+	defer q.Close()
+
+	if _, err := store.Create(JobStatus{ID: "old", State: StateCompleted, ResultKey: "renders/old.wav"}); err != nil {
+		t.Fatal(err)
+	}
+	status, err := q.RefreshResultURL(context.Background(), "old")
+	if err != nil {
+		t.Fatalf("RefreshResultURL() error: %v", err)
+	}
+	if status.ResultURL != storage.MediaPrefix+"renders/old.wav" || !strings.Contains(status.Message, status.ResultURL) {
+		t.Errorf("refreshed status = %+v, want a link to renders/old.wav", status)
+	}
+
+	if _, err := store.Create(JobStatus{ID: "failed", State: StateErrored}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.RefreshResultURL(context.Background(), "failed"); !errors.Is(err, ErrNoResult) {
+		t.Errorf("RefreshResultURL() on a failed job error = %v, want ErrNoResult", err)
+	}
+}
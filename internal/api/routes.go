@@ -1,29 +1,68 @@
 package api
 
 import (
+	"log"
 	"net/http"
-	"sync"
+	"os"
 
 	"github.com/a-h/templ"
+	"github.com/sammyshear/adon-olam/internal/storage"
+	"github.com/sammyshear/adon-olam/internal/synth"
 	"github.com/sammyshear/adon-olam/views"
 )
 
 func MuxWithRoutes() *http.ServeMux {
 	mux := http.NewServeMux()
-	ch := make(chan channel)
-	wg := &sync.WaitGroup{}
 
-	go uploadMidiProcessor(ch, wg)
+	// SYNTH_BACKEND selects the synthesizer; "sine" runs without espeak-ng/Praat/sox
+	synthesizer, err := synth.New(os.Getenv("SYNTH_BACKEND"))
+	if err != nil {
+		log.Fatalf("Failed to create synthesizer: %s", err)
+	}
+
+	// JOB_STORE_DIR persists job statuses to disk; otherwise they live in memory
+	store, err := NewJobStoreFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to open job store: %s", err)
+	}
+
+	// STORAGE_BACKEND selects where rendered audio goes; "local" needs no S3 endpoint
+	objects, err := storage.NewFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to open object store: %s", err)
+	}
+
+	// RENDER_WORKERS and RENDER_QUEUE_SIZE size the render worker pool,
+	// RENDER_TIMEOUT limits how long each job may run
+	queue, err := NewJobQueueFromEnv(store, synthesizer, objects)
+	if err != nil {
+		log.Fatalf("Failed to start render workers: %s", err)
+	}
 
 	// content routes
 	indexPage := views.Index()
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
 	mux.Handle("/", templ.Handler(indexPage))
+	if local, ok := objects.(*storage.LocalStore); ok {
+		mux.Handle("GET "+storage.MediaPrefix, local.Handler())
+	}
 
 	// api routes
-	mux.HandleFunc("POST /api/upload", UploadMidiHandler(ch))
-	mux.HandleFunc("GET /api/status/{requestID}", JobStatusHandler)
-	mux.HandleFunc("GET /api/status/{requestID}/tick", JobStatusTicker)
+	mux.HandleFunc("POST /api/upload", UploadMidiHandler(queue, store))
+	mux.HandleFunc("POST /api/inspect", InspectHandler())
+	mux.HandleFunc("POST /api/lyrics/preview", PreviewLyricsHandler())
+	mux.HandleFunc("GET /api/status/{requestID}", JobStatusHandler(queue))
+	mux.HandleFunc("GET /api/status/{requestID}/tick", JobStatusTicker(queue))
+	mux.HandleFunc("GET /api/status/{requestID}/events", JobEventsHandler(queue))
+	mux.HandleFunc("DELETE /api/status/{requestID}", JobCancelHandler(queue))
+
+	// versioned JSON api
+	mux.HandleFunc("POST /api/v1/jobs", CreateJobHandler(queue, store))
+	mux.HandleFunc("GET /api/v1/jobs", ListJobsHandler(queue, store))
+	mux.HandleFunc("GET /api/v1/jobs/{requestID}", GetJobHandler(queue))
+	mux.HandleFunc("DELETE /api/v1/jobs/{requestID}", CancelJobHandler(queue))
+	mux.HandleFunc("POST /api/v1/jobs/{requestID}/result-url", RefreshResultURLHandler(queue))
+	mux.HandleFunc("GET /api/v1/texts", ListTextsHandler())
 
 	return mux
 }
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrJobNotFound is returned when a job ID is not in the store
+var ErrJobNotFound = errors.New("request id not found")
+
+// JobStore persists job statuses. Implementations must be safe for
+// concurrent use by the HTTP handlers and the render workers.
+type JobStore interface {
+	// Create adds a new job, stamping its creation and update times
+	Create(status JobStatus) (JobStatus, error)
+	// Update applies fn to the stored job and stamps its update time
+	Update(id string, fn func(*JobStatus)) (JobStatus, error)
+	// Get returns the job with the given ID or ErrJobNotFound
+	Get(id string) (JobStatus, error)
+	// List returns all jobs, oldest first
+	List() ([]JobStatus, error)
+	// Delete removes the job with the given ID or returns ErrJobNotFound
+	Delete(id string) error
+}
+
+// MemoryJobStore keeps jobs in a mutex-guarded map; jobs are lost on restart
+type MemoryJobStore struct {
+	mu   sync.RWMutex
+	jobs map[string]JobStatus
+	now  func() time.Time
+}
+
+// NewMemoryJobStore returns an empty in-memory job store
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{
+		jobs: make(map[string]JobStatus),
+		now:  time.Now,
+	}
+}
+
+func (s *MemoryJobStore) Create(status JobStatus) (JobStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[status.ID]; ok {
+		return JobStatus{}, fmt.Errorf("job %s already exists", status.ID)
+	}
+
+	now := s.now()
+	status.CreatedAt = now
+	status.UpdatedAt = now
+	s.jobs[status.ID] = status
+	return status, nil
+}
+
+func (s *MemoryJobStore) Update(id string, fn func(*JobStatus)) (JobStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, ok := s.jobs[id]
+	if !ok {
+		return JobStatus{}, ErrJobNotFound
+	}
+
+	fn(&status)
+	status.ID = id
+	status.UpdatedAt = s.now()
+	s.jobs[id] = status
+	return status, nil
+}
+
+func (s *MemoryJobStore) Get(id string) (JobStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status, ok := s.jobs[id]
+	if !ok {
+		return JobStatus{}, ErrJobNotFound
+	}
+	return status, nil
+}
+
+func (s *MemoryJobStore) List() ([]JobStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]JobStatus, 0, len(s.jobs))
+	for _, status := range s.jobs {
+		jobs = append(jobs, status)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+	return jobs, nil
+}
+
+func (s *MemoryJobStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[id]; !ok {
+		return ErrJobNotFound
+	}
+	delete(s.jobs, id)
+	return nil
+}
+
+// FileJobStore keeps jobs in memory and writes each one through to a JSON
+// file in a directory, so statuses survive restarts
+type FileJobStore struct {
+	mu  sync.Mutex // serializes writes so the file always matches memory
+	dir string
+	mem *MemoryJobStore
+}
+
+// NewFileJobStore loads any jobs already saved in dir, creating it if needed.
+// Jobs that were still in flight when the server stopped are marked errored
+// since nothing will pick them up again.
+func NewFileJobStore(dir string) (*FileJobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create job store directory: %w", err)
+	}
+
+	s := &FileJobStore{dir: dir, mem: NewMemoryJobStore()}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read job file %s: %w", path, err)
+		}
+
+		var status JobStatus
+		if err := json.Unmarshal(b, &status); err != nil {
+			return nil, fmt.Errorf("failed to parse job file %s: %w", path, err)
+		}
+
+		if !status.IsTerminal() {
+			status.State = StateErrored
+			status.Message = "Interrupted by server restart"
+			status.UpdatedAt = time.Now()
+			if err := s.write(status); err != nil {
+				return nil, err
+			}
+		}
+		s.mem.jobs[status.ID] = status
+	}
+
+	return s, nil
+}
+
+func (s *FileJobStore) Create(status JobStatus) (JobStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, err := s.mem.Create(status)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	return status, s.write(status)
+}
+
+func (s *FileJobStore) Update(id string, fn func(*JobStatus)) (JobStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, err := s.mem.Update(id, fn)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	return status, s.write(status)
+}
+
+func (s *FileJobStore) Get(id string) (JobStatus, error) {
+	return s.mem.Get(id)
+}
+
+func (s *FileJobStore) List() ([]JobStatus, error) {
+	return s.mem.List()
+}
+
+func (s *FileJobStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.mem.Delete(id); err != nil {
+		return err
+	}
+	// Only jobs that were written are in memory, so the ID is a safe name
+	if err := os.Remove(filepath.Join(s.dir, id+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete job %s: %w", id, err)
+	}
+	return nil
+}
+
+// write saves a job atomically by renaming a fully written temp file into place
+func (s *FileJobStore) write(status JobStatus) error {
+	// IDs come from generateRequestID but guard against path traversal anyway
+	if status.ID == "" || strings.ContainsAny(status.ID, `/\.`) {
+		return fmt.Errorf("invalid job id %q", status.ID)
+	}
+
+	b, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, status.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save job %s: %w", status.ID, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save job %s: %w", status.ID, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save job %s: %w", status.ID, err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, status.ID+".json"))
+}
+
+// NewJobStoreFromEnv returns a FileJobStore rooted at JOB_STORE_DIR if it is
+// set, or a MemoryJobStore otherwise
+func NewJobStoreFromEnv() (JobStore, error) {
+	if dir := os.Getenv("JOB_STORE_DIR"); dir != "" {
+		return NewFileJobStore(dir)
+	}
+	return NewMemoryJobStore(), nil
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestMemoryJobStore(t *testing.T) {
+	store := NewMemoryJobStore()
+
+	created, err := store.Create(JobStatus{ID: "a", State: StateQueued, Params: JobParams{TrackNo: 2}})
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if created.CreatedAt.IsZero() || created.UpdatedAt.IsZero() {
+		t.Error("Create() did not stamp timestamps")
+	}
+
+	if _, err := store.Create(JobStatus{ID: "a"}); err == nil {
+		t.Error("Create() with a duplicate id should fail")
+	}
+
+	updated, err := store.Update("a", func(s *JobStatus) { s.State = StateCompleted })
+	if err != nil {
+		t.Fatalf("Update() error: %v", err)
+	}
+	if updated.State != StateCompleted || updated.Params.TrackNo != 2 {
+		t.Errorf("Update() = %+v, want completed job keeping its params", updated)
+	}
+	if updated.CreatedAt != created.CreatedAt {
+		t.Error("Update() changed the creation time")
+	}
+
+	if _, err := store.Get("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrJobNotFound", err)
+	}
+	if _, err := store.Update("missing", func(*JobStatus) {}); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Update(missing) error = %v, want ErrJobNotFound", err)
+	}
+
+	if err := store.Delete("a"); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+	if _, err := store.Get("a"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrJobNotFound", err)
+	}
+	if err := store.Delete("a"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Delete(missing) error = %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestMemoryJobStore_Concurrent(t *testing.T) {
+	store := NewMemoryJobStore()
+	if _, err := store.Create(JobStatus{ID: "job"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			store.Update("job", func(s *JobStatus) { s.Message = fmt.Sprint(i) })
+		}(i)
+		go func() {
+			defer wg.Done()
+			store.Get("job")
+			store.List()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFileJobStore_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileJobStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileJobStore() error: %v", err)
+	}
+
+	if _, err := store.Create(JobStatus{ID: "done", State: StateQueued, Params: JobParams{FileName: "tune.mid"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Update("done", func(s *JobStatus) {
+		s.State = StateCompleted
+		s.ResultURL = "http://example.com/tune.wav"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create(JobStatus{ID: "inflight", State: StateRunning}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileJobStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileJobStore() reopen error: %v", err)
+	}
+
+	done, err := reopened.Get("done")
+	if err != nil {
+		t.Fatalf("Get(done) error: %v", err)
+	}
+	if done.State != StateCompleted || done.ResultURL != "http://example.com/tune.wav" || done.Params.FileName != "tune.mid" {
+		t.Errorf("reloaded job = %+v, want completed job with result URL and params", done)
+	}
+
+	inflight, err := reopened.Get("inflight")
+	if err != nil {
+		t.Fatalf("Get(inflight) error: %v", err)
+	}
+	if inflight.State != StateErrored {
+		t.Errorf("in-flight job state after restart = %s, want %s", inflight.State, StateErrored)
+	}
+
+	jobs, err := reopened.List()
+	if err != nil || len(jobs) != 2 {
+		t.Errorf("List() = %d jobs, %v; want 2 jobs", len(jobs), err)
+	}
+}
+
+func TestFileJobStore_Delete(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileJobStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileJobStore() error: %v", err)
+	}
+	for _, id := range []string{"kept", "deleted"} {
+		if _, err := store.Create(JobStatus{ID: id, State: StateErrored}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Delete("deleted"); err != nil {
+		t.Fatalf("Delete() error: %v", err)
+	}
+
+	reopened, err := NewFileJobStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileJobStore() reopen error: %v", err)
+	}
+	if _, err := reopened.Get("deleted"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Get(deleted) after restart error = %v, want ErrJobNotFound", err)
+	}
+	if _, err := reopened.Get("kept"); err != nil {
+		t.Errorf("Get(kept) after restart error: %v", err)
+	}
+}
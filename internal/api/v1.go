@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sammyshear/adon-olam/internal/lyrics"
+)
+
+// jobsURL is the prefix of the versioned JSON job resources
+const jobsURL = "/api/v1/jobs/"
+
+// jobList is the response body of the job list endpoint
+type jobList struct {
+	Jobs []JobStatus `json:"jobs"`
+}
+
+// textList is the response body of the text list endpoint
+type textList struct {
+	Texts []lyrics.Text `json:"texts"`
+}
+
+// CreateJobHandler accepts the same multipart upload as the web form and
+// answers 202 with the queued job's status and its URL in Location
+func CreateJobHandler(queue *JobQueue, store JobStore) func(http.ResponseWriter, *http.Request) {
+	return uploadHandler(queue, store, jobsURL, true)
+}
+
+// ListJobsHandler lists all jobs oldest first, optionally only those in
+// the state given by the "state" query parameter
+func ListJobsHandler(queue *JobQueue, store JobStore) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobs, err := store.List()
+		if err != nil {
+			writeError(w, true, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		state := r.URL.Query().Get("state")
+		list := jobList{Jobs: make([]JobStatus, 0, len(jobs))}
+		for _, status := range jobs {
+			if state != "" && status.State != state {
+				continue
+			}
+			if status.State == StateQueued {
+				status.QueuePosition = queue.Position(status.ID)
+			}
+			list.Jobs = append(list.Jobs, status)
+		}
+
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// GetJobHandler returns a job's full status
+func GetJobHandler(queue *JobQueue) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := queue.Status(r.PathValue("requestID"))
+		if err != nil {
+			statusError(w, true, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	}
+}
+
+// CancelJobHandler cancels a job and returns its status
+func CancelJobHandler(queue *JobQueue) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cancelJob(w, r, true, queue)
+	}
+}
+
+// RefreshResultURLHandler re-signs the download link of a completed job
+func RefreshResultURLHandler(queue *JobQueue) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := queue.RefreshResultURL(r.Context(), r.PathValue("requestID"))
+		if errors.Is(err, ErrNoResult) {
+			writeError(w, true, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			statusError(w, true, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	}
+}
+
+// ListTextsHandler lists the catalog of texts a job can sing, by the ID
+// given in its "text" field
+func ListTextsHandler() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, textList{Texts: lyrics.Catalog()})
+	}
+}
@@ -0,0 +1,572 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sammyshear/adon-olam/internal/synth"
+	"github.com/sammyshear/adon-olam/internal/wav"
+)
+
+// newTestAPI serves the job routes over a queue whose synthesizer blocks
+// until the test ends
+func newTestAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	store := NewMemoryJobStore()
+	release := make(chan struct{})
+	blocking := funcSynthesizer(func(ctx context.Context, _ synth.Plan) (*wav.Audio, error) {
+		select {
+		case <-release:
+			return nil, errors.New("stopped")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	q := NewJobQueue(1, 4, 0, store, blocking, newTestObjects(t))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/upload", UploadMidiHandler(q, store))
+	mux.HandleFunc("GET /api/status/{requestID}", JobStatusHandler(q))
+	mux.HandleFunc("POST /api/v1/jobs", CreateJobHandler(q, store))
+	mux.HandleFunc("GET /api/v1/jobs", ListJobsHandler(q, store))
+	mux.HandleFunc("GET /api/v1/jobs/{requestID}", GetJobHandler(q))
+	mux.HandleFunc("DELETE /api/v1/jobs/{requestID}", CancelJobHandler(q))
+	mux.HandleFunc("GET /api/v1/texts", ListTextsHandler())
+	srv := httptest.NewServer(mux)
+
+	t.Cleanup(func() {
+		srv.Close()
+		close(release)
+		q.Close()
+	})
+	return srv
+}
+
+// uploadRequest builds a multipart upload of testMIDI with the given form fields
+func uploadRequest(t *testing.T, url, fileName string, fields map[string]string) *http.Request {
+	t.Helper()
+	return uploadFileRequest(t, url, fileName, testMIDI, fields)
+}
+
+func uploadFileRequest(t *testing.T, url, fileName string, data []byte, fields map[string]string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("uploadFile", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	mw.Close()
+
+	req, err := http.NewRequest(http.MethodPost, url, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func decodeJSON[T any](t *testing.T, resp *http.Response) T {
+	t.Helper()
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+	var v T
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return v
+}
+
+func TestJobsAPI(t *testing.T) {
+	srv := newTestAPI(t)
+
+	resp, err := http.DefaultClient.Do(uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{
+		"trackNo": "0", "channel": "1", "voice": "en", "chordPolicy": "voice", "chordVoice": "2", "chordThreshold": "25",
+		"alignment": "lyrics", "dynamics": "gm", "text": "yigdal", "pronunciation": "ashkenazi",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("create status = %d, want 202", resp.StatusCode)
+	}
+	created := decodeJSON[JobStatus](t, resp)
+	if resp.Header.Get("Location") != jobsURL+created.ID || created.JobURL != jobsURL+created.ID {
+		t.Errorf("Location = %q, JobURL = %q, want %q", resp.Header.Get("Location"), created.JobURL, jobsURL+created.ID)
+	}
+	if created.Params.Voice != "en" || created.Params.FileName != "tune.mid" {
+		t.Errorf("params = %+v, want the submitted voice and file name", created.Params)
+	}
+	if p := created.Params; p.Channel != 1 || p.ChordPolicy != "voice" || p.ChordVoice != 2 || p.ChordThresholdMs != 25 {
+		t.Errorf("params = %+v, want channel 1 and chord policy voice 2 within 25ms", p)
+	}
+	if created.Params.Alignment != "lyrics" || created.Params.Dynamics != "gm" {
+		t.Errorf("alignment = %q, dynamics = %q, want lyrics and gm", created.Params.Alignment, created.Params.Dynamics)
+	}
+	if created.Params.Text != "yigdal" || created.Params.Pronunciation != "ashkenazi" {
+		t.Errorf("text = %q, pronunciation = %q, want yigdal and ashkenazi", created.Params.Text, created.Params.Pronunciation)
+	}
+
+	resp, err = http.Get(srv.URL + jobsURL + created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decodeJSON[JobStatus](t, resp); got.ID != created.ID {
+		t.Errorf("get returned job %q, want %q", got.ID, created.ID)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/v1/jobs?state=" + StateCompleted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := decodeJSON[jobList](t, resp); len(list.Jobs) != 0 {
+		t.Errorf("completed jobs = %v, want none", list.Jobs)
+	}
+
+	resp, err = http.Get(srv.URL + "/api/v1/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := decodeJSON[jobList](t, resp); len(list.Jobs) != 1 {
+		t.Errorf("got %d jobs, want 1", len(list.Jobs))
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+jobsURL+created.ID, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("cancel status = %d, want 202", resp.StatusCode)
+	}
+	resp.Body.Close()
+}
+
+func TestJobsAPI_QueueFull(t *testing.T) {
+	srv := newTestAPI(t)
+
+	// Fill the worker and the queue until an upload is turned away
+	accepted := 0
+	for ; accepted < 10; accepted++ {
+		resp, err := http.DefaultClient.Do(uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "0"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			if resp.Header.Get("Retry-After") == "" {
+				t.Error("busy response has no Retry-After")
+			}
+			break
+		}
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("create status = %d, want 202 or 503", resp.StatusCode)
+		}
+	}
+	if accepted == 10 {
+		t.Fatal("queue never filled")
+	}
+
+	// The rejected upload leaves no job behind
+	resp, err := http.Get(srv.URL + "/api/v1/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := decodeJSON[jobList](t, resp); len(list.Jobs) != accepted {
+		t.Errorf("got %d jobs, want the %d accepted", len(list.Jobs), accepted)
+	}
+}
+
+func TestJobsAPI_PronunciationVoice(t *testing.T) {
+	srv := newTestAPI(t)
+
+	resp, err := http.DefaultClient.Do(uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{
+		"trackNo": "0", "pronunciation": "yemenite",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("create status = %d, want 202", resp.StatusCode)
+	}
+	created := decodeJSON[JobStatus](t, resp)
+	if created.Params.Pronunciation != "yemenite" || created.Params.Voice != "ar" {
+		t.Errorf("pronunciation = %q, voice = %q, want yemenite sung by its own voice ar", created.Params.Pronunciation, created.Params.Voice)
+	}
+}
+
+func TestJobsAPI_TextPronunciation(t *testing.T) {
+	srv := newTestAPI(t)
+
+	tests := []struct {
+		text              string
+		wantText          string
+		wantPronunciation string
+		wantVoice         string
+	}{
+		{"", "adon-olam", "modern", "he"},
+		{"anim-zemirot", "anim-zemirot", "ashkenazi", "de"},
+	}
+	for _, tt := range tests {
+		resp, err := http.DefaultClient.Do(uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{
+			"trackNo": "0", "text": tt.text,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("create status = %d, want 202", resp.StatusCode)
+		}
+		p := decodeJSON[JobStatus](t, resp).Params
+		if p.Text != tt.wantText || p.Pronunciation != tt.wantPronunciation || p.Voice != tt.wantVoice {
+			t.Errorf("text %q: got text %q, pronunciation %q, voice %q, want %q, %q, %q",
+				tt.text, p.Text, p.Pronunciation, p.Voice, tt.wantText, tt.wantPronunciation, tt.wantVoice)
+		}
+	}
+}
+
+func TestJobsAPI_Lyrics(t *testing.T) {
+	srv := newTestAPI(t)
+
+	tests := []struct {
+		name       string
+		lyricsFile string
+		fields     map[string]string
+		want       string
+	}{
+		{"typed", "", map[string]string{"trackNo": "0", "lyrics": " a don o lam\n", "text": "yigdal"}, "a don o lam"},
+		{"file", "אֲדוֹן עוֹלָם\n", map[string]string{"trackNo": "0"}, "אֲדוֹן עוֹלָם"},
+	}
+	for _, tt := range tests {
+		resp, err := http.DefaultClient.Do(lyricsRequest(t, srv.URL+"/api/v1/jobs", true, tt.lyricsFile, tt.fields))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("%s: create status = %d, want 202", tt.name, resp.StatusCode)
+		}
+		p := decodeJSON[JobStatus](t, resp).Params
+		if p.Lyrics != tt.want || p.Text != "" || p.Pronunciation != "modern" {
+			t.Errorf("%s: lyrics = %q, text = %q, pronunciation = %q, want %q sung instead of a text in modern", tt.name, p.Lyrics, p.Text, p.Pronunciation, tt.want)
+		}
+	}
+}
+
+func TestTextsAPI(t *testing.T) {
+	srv := newTestAPI(t)
+
+	resp, err := http.Get(srv.URL + "/api/v1/texts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	list := decodeJSON[textList](t, resp)
+	ids := map[string]bool{}
+	for _, text := range list.Texts {
+		ids[text.ID] = true
+		if text.Title == "" || len(text.Verses) == 0 {
+			t.Errorf("text %+v is missing its title or verses", text)
+		}
+	}
+	for _, id := range []string{"adon-olam", "yigdal", "lecha-dodi", "ein-keloheinu", "anim-zemirot"} {
+		if !ids[id] {
+			t.Errorf("texts are missing %s", id)
+		}
+	}
+}
+
+func TestJobsAPI_AutoTrack(t *testing.T) {
+	srv := newTestAPI(t)
+
+	for _, trackNo := range []string{"auto", ""} {
+		resp, err := http.DefaultClient.Do(uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": trackNo}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("trackNo %q: create status = %d, want 202", trackNo, resp.StatusCode)
+		}
+		created := decodeJSON[JobStatus](t, resp)
+		detected := created.Params.DetectedTrack
+		if created.Params.TrackNo != 0 || detected == nil || detected.Track != 0 || len(detected.Reasons) == 0 {
+			t.Errorf("trackNo %q: params = %+v, want track 0 detected with reasons", trackNo, created.Params)
+		}
+	}
+
+	// An explicit channel limits the detection to that channel
+	resp, err := http.DefaultClient.Do(uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "auto", "channel": "1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("channel 1: create status = %d, want 202", resp.StatusCode)
+	}
+	if p := decodeJSON[JobStatus](t, resp).Params; p.Channel != 1 || p.DetectedTrack == nil || p.DetectedTrack.Channel != 1 {
+		t.Errorf("channel 1: params = %+v, want channel 1 kept", p)
+	}
+
+	resp, err = http.DefaultClient.Do(uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "auto", "channel": "3"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("channel 3: create status = %d, want 400 for a channel without notes", resp.StatusCode)
+	}
+}
+
+func TestJobsAPI_MusicXML(t *testing.T) {
+	srv := newTestAPI(t)
+
+	score := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<score-partwise version="4.0">
+  <part-list><score-part id="P1"><part-name>Voice</part-name></score-part></part-list>
+  <part id="P1"><measure number="1">
+    <attributes><divisions>1</divisions></attributes>
+    <note><pitch><step>C</step><octave>4</octave></pitch><duration>1</duration><lyric><text>a</text></lyric></note>
+    <note><pitch><step>D</step><octave>4</octave></pitch><duration>1</duration><lyric><text>don</text></lyric></note>
+  </measure></part>
+</score-partwise>`)
+	resp, err := http.DefaultClient.Do(uploadFileRequest(t, srv.URL+"/api/v1/jobs", "hymn.musicxml", score, map[string]string{"alignment": "lyrics"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("create status = %d, want 202", resp.StatusCode)
+	}
+	created := decodeJSON[JobStatus](t, resp)
+	if p := created.Params; p.FileName != "hymn.musicxml" || p.DetectedTrack == nil || p.DetectedTrack.Track != 0 {
+		t.Errorf("params = %+v, want the voice part detected", p)
+	}
+}
+
+func TestJobsAPI_ABC(t *testing.T) {
+	srv := newTestAPI(t)
+
+	tune := []byte("X:1\nT:Adon Olam\nL:1/4\nK:C\nC D |\nw: a-don\n")
+	resp, err := http.DefaultClient.Do(uploadFileRequest(t, srv.URL+"/api/v1/jobs", "tune.abc", tune, map[string]string{"alignment": "lyrics"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("create status = %d, want 202", resp.StatusCode)
+	}
+	created := decodeJSON[JobStatus](t, resp)
+	if p := created.Params; p.FileName != "tune.abc" || p.DetectedTrack == nil || p.DetectedTrack.Track != 0 {
+		t.Errorf("params = %+v, want the tune detected", p)
+	}
+}
+
+func TestJobsAPI_Warnings(t *testing.T) {
+	srv := newTestAPI(t)
+
+	// testMIDI with a note-off for a D that never started
+	orphan := []byte{
+		'M', 'T', 'h', 'd', 0, 0, 0, 6, 0, 0, 0, 1, 0x03, 0xC0,
+		'M', 'T', 'r', 'k', 0, 0, 0, 17,
+		0x00, 0x80, 62, 0,
+		0x00, 0x90, 60, 100,
+		0x87, 0x40, 0x80, 60, 0,
+		0x00, 0xFF, 0x2F, 0x00,
+	}
+	resp, err := http.DefaultClient.Do(uploadFileRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", orphan, map[string]string{"trackNo": "0"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("create status = %d, want 202", resp.StatusCode)
+	}
+	created := decodeJSON[JobStatus](t, resp)
+	want := "track 0, channel 1, bar 1 beat 1: note-off for D4 has no matching note-on and was ignored"
+	if len(created.Warnings) != 1 || created.Warnings[0] != want {
+		t.Errorf("warnings = %q, want [%q]", created.Warnings, want)
+	}
+}
+
+func TestJobsAPI_Errors(t *testing.T) {
+	srv := newTestAPI(t)
+
+	tests := []struct {
+		name     string
+		req      func() *http.Request
+		wantCode int
+	}{
+		{
+			name: "not a midi file",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.txt", map[string]string{"trackNo": "0"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "bad timing strategy",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "0", "timingStrategy": "fastest"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "bad chord policy",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "0", "chordPolicy": "median"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "bad alignment",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "0", "alignment": "karaoke"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "bad dynamics",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "0", "dynamics": "loud"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "bad pronunciation",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "0", "pronunciation": "klingon"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "bad text",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "0", "text": "kol-nidre"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "bad lyrics",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "0", "lyrics": "a d0n"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "lyrics without a vowel",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "0", "lyrics": "a dn o lam"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "unpointed hebrew lyrics",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "0", "lyrics": "אדון עולם"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "lyrics typed and uploaded",
+			req: func() *http.Request {
+				return lyricsRequest(t, srv.URL+"/api/v1/jobs", true, "a don", map[string]string{"trackNo": "0", "lyrics": "o lam"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "bad chord threshold",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "0", "chordThreshold": "-5"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "bad channel",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "0", "channel": "17"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "not a standard midi file",
+			req: func() *http.Request {
+				return uploadFileRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", []byte("not midi"), map[string]string{"trackNo": "0"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "bad track",
+			req: func() *http.Request {
+				return uploadRequest(t, srv.URL+"/api/v1/jobs", "tune.mid", map[string]string{"trackNo": "melody"})
+			},
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name: "unknown job",
+			req: func() *http.Request {
+				req, _ := http.NewRequest(http.MethodGet, srv.URL+jobsURL+"missing", nil)
+				return req
+			},
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.DefaultClient.Do(tt.req())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if body := decodeJSON[map[string]string](t, resp); body["error"] == "" {
+				t.Error("response has no error message")
+			}
+		})
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+	srv := newTestAPI(t)
+
+	// The htmx form still gets an HTML fragment
+	req := uploadRequest(t, srv.URL+"/api/upload", "tune.mid", map[string]string{"trackNo": "0"})
+	req.Header.Set("HX-Request", "true")
+	req.Header.Set("Accept", "*/*")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statusURL := resp.Header.Get("X-Status-URL")
+	var body bytes.Buffer
+	body.ReadFrom(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(body.String(), "sse-connect") {
+		t.Errorf("htmx upload response = %q, want an HTML fragment", body.String())
+	}
+
+	// The same job is available as JSON from the legacy status URL
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+statusURL, nil)
+	req.Header.Set("Accept", "application/json")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := decodeJSON[JobStatus](t, resp); status.JobURL != statusURL {
+		t.Errorf("JobURL = %q, want %q", status.JobURL, statusURL)
+	}
+}
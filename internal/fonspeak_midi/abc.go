@@ -0,0 +1,921 @@
+package fonspeak_midi
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// abcWholeNote is the length of a whole note in ticks
+const abcWholeNote = 4 * musicXMLTicksPerQuarter
+
+var (
+	// abcField matches an information field line such as "K:Dm" or "w: a-don"
+	abcField = regexp.MustCompile(`^([A-Za-z+]):(.*)$`)
+	// abcVoiceName matches the name of a voice in a V: field
+	abcVoiceName = regexp.MustCompile(`(?:name|nm)="([^"]*)"`)
+	// abcQuoted matches quoted text, such as a tempo marking in a Q: field
+	abcQuoted = regexp.MustCompile(`"[^"]*"`)
+	// abcTuplet matches "(p", "(p:q" or "(p:q:r"
+	abcTuplet = regexp.MustCompile(`^\((\d+)(?::(\d*))?(?::(\d*))?`)
+)
+
+// abcItemKind distinguishes the items of a parsed ABC voice
+type abcItemKind int
+
+const (
+	abcNote abcItemKind = iota
+	abcRest
+	abcBar
+	abcWords
+	abcMeter
+	abcTempo
+)
+
+// abcItem is a note, rest, bar line, lyric line or field change of a voice,
+// in the order written
+type abcItem struct {
+	kind   abcItemKind
+	keys   []int   // MIDI notes sounding together, for abcNote
+	ticks  int64   // Length of a note or rest
+	tie    bool    // Tied to the next note of the same key
+	broken int     // Broken rhythm with the next note: n for ">"*n, -n for "<"*n
+	text   string  // w: line, for abcWords
+	meter  [2]int  // Numerator and denominator, for abcMeter
+	bpm    float64 // Quarter notes per minute, for abcTempo
+}
+
+type abcVoice struct {
+	id    string
+	name  string
+	items []abcItem
+	// implicit is set on the voice created for music before any V: field
+	implicit bool
+}
+
+// abcParser holds the state that carries from line to line of a tune
+type abcParser struct {
+	title       string
+	meter       [2]int       // Last M: field, zero for free meter
+	unit        [2]int64     // L: field as a fraction of a whole note, zero until set
+	key         map[byte]int // Key signature alterations by upper case letter
+	accidentals map[int]int  // Accidentals written in the current bar, by letter and octave
+	tuplet      struct {
+		left     int
+		num, den int64
+	}
+	voices  []*abcVoice
+	current *abcVoice
+}
+
+// isABC reports whether data looks like ABC notation: its first line that
+// is not blank or a comment is an information field
+func isABC(data []byte) bool {
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "%") {
+			continue
+		}
+		return abcField.MatchString(line)
+	}
+	return false
+}
+
+// ParseABC reads the first tune of an ABC notation file into a Score with a
+// track per voice (V: field). Key signatures, accidentals, note lengths,
+// chords, ties, broken rhythms, tuplets, and meter (M:), unit length (L:),
+// tempo (Q:) and key (K:) changes are supported; decorations, chord symbols
+// and grace notes are skipped and repeats are not expanded. Each w: line
+// sets the lyrics of the notes written since the previous one, with "-"
+// between syllables, "_" holding a syllable over another note, "*"
+// skipping a note and "|" moving to the next bar.
+func ParseABC(ctx context.Context, reader io.Reader) (*Score, error) {
+	p := &abcParser{key: map[byte]int{}, accidentals: map[int]int{}}
+	inBody, inTune := false, false
+
+	scanner := bufio.NewScanner(reader)
+	continued := ""
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		line := stripABCComment(scanner.Text())
+		if strings.TrimSpace(line) == "" {
+			// A blank line ends the tune
+			if inBody {
+				break
+			}
+			continue
+		}
+
+		if m := abcField.FindStringSubmatch(line); m != nil && !isABCMusic(line) {
+			value := strings.TrimSpace(m[2])
+			switch m[1] {
+			case "X":
+				if inTune {
+					return p.score()
+				}
+				inTune = true
+			case "K":
+				if err := p.setKey(value); err != nil {
+					return nil, err
+				}
+				inBody = true
+			case "w":
+				if inBody {
+					p.voice().items = append(p.voice().items, abcItem{kind: abcWords, text: value})
+				}
+			default:
+				if err := p.setField(m[1], value); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if !inBody {
+			continue
+		}
+
+		// A trailing backslash continues the music on the next line
+		line = strings.TrimRight(line, " \t")
+		if strings.HasSuffix(line, `\`) {
+			continued += strings.TrimSuffix(line, `\`)
+			continue
+		}
+		if err := p.parseMusic(continued + line); err != nil {
+			return nil, err
+		}
+		continued = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ABC tune: %w", err)
+	}
+	if continued != "" {
+		if err := p.parseMusic(continued); err != nil {
+			return nil, err
+		}
+	}
+	if !inBody {
+		return nil, fmt.Errorf("no tune found in ABC file: the K: field must end the header")
+	}
+	return p.score()
+}
+
+// stripABCComment removes a % comment unless the % is escaped
+func stripABCComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '%' && (i == 0 || line[i-1] != '\\') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// isABCMusic reports whether a line that looks like a field is music
+// starting with a note and a repeat, such as "G:|"
+func isABCMusic(line string) bool {
+	return strings.ContainsRune("ABCDEFGabcdefg", rune(line[0])) && len(line) > 2 && (line[2] == '|' || line[2] == ':')
+}
+
+// voice returns the voice being written, creating the first if needed
+func (p *abcParser) voice() *abcVoice {
+	if p.current == nil {
+		p.current = &abcVoice{id: "1", implicit: true}
+		p.voices = append(p.voices, p.current)
+	}
+	return p.current
+}
+
+// setField applies an information field in the header or body
+func (p *abcParser) setField(field, value string) error {
+	switch field {
+	case "T":
+		if p.title == "" {
+			p.title = value
+		}
+	case "M":
+		meter, err := parseABCMeter(value)
+		if err != nil {
+			return err
+		}
+		p.meter = meter
+		if meter[0] > 0 {
+			p.voice().items = append(p.voice().items, abcItem{kind: abcMeter, meter: meter})
+		}
+	case "L":
+		unit, err := parseABCFraction(value)
+		if err != nil || unit[0] <= 0 {
+			return fmt.Errorf("invalid unit note length L:%s", value)
+		}
+		p.unit = unit
+	case "Q":
+		bpm, err := p.parseTempo(value)
+		if err != nil {
+			return err
+		}
+		p.voice().items = append(p.voice().items, abcItem{kind: abcTempo, bpm: bpm})
+	case "K":
+		return p.setKey(value)
+	case "V":
+		p.setVoice(value)
+	}
+	return nil
+}
+
+// setVoice switches to the voice named by a V: field, e.g. `1 name="Tenor"`
+func (p *abcParser) setVoice(value string) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return
+	}
+	id := fields[0]
+	p.accidentals = map[int]int{}
+
+	var voice *abcVoice
+	for _, v := range p.voices {
+		if v.id == id {
+			voice = v
+		}
+	}
+	switch {
+	case voice != nil:
+	case p.current != nil && p.current.implicit && !p.current.hasNotes():
+		// The default voice, written to before the first V: field without
+		// any notes, is taken over rather than left empty
+		voice = p.current
+		voice.id, voice.implicit = id, false
+	default:
+		voice = &abcVoice{id: id}
+		p.voices = append(p.voices, voice)
+	}
+	if m := abcVoiceName.FindStringSubmatch(value); m != nil {
+		voice.name = m[1]
+	}
+	p.current = voice
+}
+
+func (v *abcVoice) hasNotes() bool {
+	for _, item := range v.items {
+		if item.kind == abcNote || item.kind == abcRest {
+			return true
+		}
+	}
+	return false
+}
+
+// parseABCMeter parses an M: field: "3/4", "C" (4/4), "C|" (2/2), "2+3/8"
+// or "none", which gives a zero meter
+func parseABCMeter(value string) ([2]int, error) {
+	switch value {
+	case "C":
+		return [2]int{4, 4}, nil
+	case "C|":
+		return [2]int{2, 2}, nil
+	case "", "none":
+		return [2]int{}, nil
+	}
+	num, den, ok := strings.Cut(value, "/")
+	if ok {
+		beats := 0
+		for _, part := range strings.Split(strings.Trim(num, "()"), "+") {
+			n, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return [2]int{}, fmt.Errorf("invalid meter M:%s", value)
+			}
+			beats += n
+		}
+		if d, err := strconv.Atoi(strings.TrimSpace(den)); err == nil && beats > 0 && d > 0 {
+			return [2]int{beats, d}, nil
+		}
+	}
+	return [2]int{}, fmt.Errorf("invalid meter M:%s", value)
+}
+
+// parseABCFraction parses a fraction such as "1/8", or a whole number
+func parseABCFraction(value string) ([2]int64, error) {
+	num, den, ok := strings.Cut(strings.TrimSpace(value), "/")
+	n, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+	if err != nil {
+		return [2]int64{}, err
+	}
+	d := int64(1)
+	if ok {
+		d, err = strconv.ParseInt(strings.TrimSpace(den), 10, 64)
+		if err != nil || d <= 0 {
+			return [2]int64{}, fmt.Errorf("invalid fraction %q", value)
+		}
+	}
+	return [2]int64{n, d}, nil
+}
+
+// parseTempo parses a Q: field such as "1/4=100", `"Andante" 3/8=60` or
+// "120" (unit notes per minute) into quarter notes per minute
+func (p *abcParser) parseTempo(value string) (float64, error) {
+	// Drop any quoted tempo text
+	value = abcQuoted.ReplaceAllString(value, "")
+	beats, rate, ok := strings.Cut(value, "=")
+	if !ok {
+		beats, rate = "", beats
+	}
+	bpm, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+	if err != nil || bpm <= 0 {
+		return 0, fmt.Errorf("invalid tempo Q:%s", value)
+	}
+
+	// The beat is the sum of the fractions before the "=", by default the
+	// unit note length
+	beat := 0.0
+	for _, field := range strings.Fields(beats) {
+		f, err := parseABCFraction(field)
+		if err != nil {
+			return 0, fmt.Errorf("invalid tempo Q:%s", value)
+		}
+		beat += float64(f[0]) / float64(f[1])
+	}
+	if beat == 0 {
+		unit := p.unitLength()
+		beat = float64(unit[0]) / float64(unit[1])
+	}
+	return bpm * beat * 4, nil
+}
+
+// unitLength returns the L: field, or its default of an eighth note, or a
+// sixteenth in meters under 3/4
+func (p *abcParser) unitLength() [2]int64 {
+	if p.unit[0] > 0 {
+		return p.unit
+	}
+	if p.meter[0] > 0 && 4*p.meter[0] < 3*p.meter[1] {
+		return [2]int64{1, 16}
+	}
+	return [2]int64{1, 8}
+}
+
+// abcMajorFifths places each major key on the circle of fifths
+var abcMajorFifths = map[string]int{
+	"Cb": -7, "Gb": -6, "Db": -5, "Ab": -4, "Eb": -3, "Bb": -2, "F": -1,
+	"C": 0, "G": 1, "D": 2, "A": 3, "E": 4, "B": 5, "F#": 6, "C#": 7,
+}
+
+// abcModeFifths shifts a major key's signature for each mode, by the first
+// three letters of its name
+var abcModeFifths = map[string]int{
+	"": 0, "maj": 0, "ion": 0, "lyd": 1, "mix": -1, "dor": -2,
+	"m": -3, "min": -3, "aeo": -3, "phr": -4, "loc": -5,
+}
+
+// setKey applies a K: field such as "G", "Dm", "E phr ^G" or "none",
+// including any accidentals written after the key. "exp" in place of the
+// mode uses only the accidentals written, e.g. "D exp ^f ^c".
+func (p *abcParser) setKey(value string) error {
+	p.key = map[byte]int{}
+	fields := strings.Fields(value)
+	if len(fields) == 0 || strings.EqualFold(fields[0], "none") || fields[0] == "HP" || fields[0] == "Hp" {
+		return nil
+	}
+
+	tonic := fields[0]
+	rest := fields[1:]
+	name := tonic[:1]
+	tonic = tonic[1:]
+	if tonic != "" && (tonic[0] == '#' || tonic[0] == 'b') {
+		name += tonic[:1]
+		tonic = tonic[1:]
+	}
+	mode := strings.ToLower(tonic)
+	if mode == "" && len(rest) > 0 && !strings.ContainsAny(rest[0][:1], "^_=") && !strings.Contains(rest[0], "=") {
+		mode, rest = strings.ToLower(rest[0]), rest[1:]
+	}
+	if len(mode) > 3 {
+		mode = mode[:3]
+	}
+
+	fifths, ok := abcMajorFifths[strings.ToUpper(name[:1])+name[1:]]
+	shift, modeOK := abcModeFifths[mode]
+	if mode == "exp" {
+		fifths, shift, modeOK = 0, 0, true
+	}
+	if !ok || !modeOK {
+		return fmt.Errorf("invalid key K:%s", value)
+	}
+	fifths += shift
+	for i := 0; i < fifths && i < 7; i++ {
+		p.key["FCGDAEB"[i]] = 1
+	}
+	for i := 0; i < -fifths && i < 7; i++ {
+		p.key["BEADGCF"[i]] = -1
+	}
+
+	// Explicit accidentals, e.g. "^f" or "=b", change single notes of the key
+	for _, field := range rest {
+		alter, n := parseABCAccidental(field)
+		if n > 0 && n < len(field) && strings.ContainsRune("ABCDEFGabcdefg", rune(field[n])) {
+			p.key[strings.ToUpper(field[n : n+1])[0]] = alter
+		}
+	}
+	return nil
+}
+
+// parseABCAccidental reads an accidental prefix, returning the alteration in
+// semitones and its length in bytes (0 if there is none)
+func parseABCAccidental(s string) (int, int) {
+	switch {
+	case strings.HasPrefix(s, "^^"):
+		return 2, 2
+	case strings.HasPrefix(s, "__"):
+		return -2, 2
+	case strings.HasPrefix(s, "^"):
+		return 1, 1
+	case strings.HasPrefix(s, "_"):
+		return -1, 1
+	case strings.HasPrefix(s, "="):
+		return 0, 1
+	}
+	return 0, 0
+}
+
+// abcSemitones are the pitch classes of the note letters
+var abcSemitones = map[byte]int{'C': 0, 'D': 2, 'E': 4, 'F': 5, 'G': 7, 'A': 9, 'B': 11}
+
+// parseMusic adds the notes, rests and bar lines of a line of music to the
+// current voice
+func (p *abcParser) parseMusic(line string) error {
+	voice := p.voice()
+	add := func(item abcItem) {
+		voice.items = append(voice.items, item)
+	}
+	// lastNote returns the last note or rest, for ties and broken rhythms
+	lastNote := func() *abcItem {
+		for i := len(voice.items) - 1; i >= 0; i-- {
+			if kind := voice.items[i].kind; kind == abcNote || kind == abcRest {
+				return &voice.items[i]
+			}
+		}
+		return nil
+	}
+
+	s := line
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"' || c == '!' || c == '+':
+			// Chord symbols, annotations and decorations
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return fmt.Errorf("unterminated %c in ABC music: %s", c, line)
+			}
+			i += end + 2
+		case c == '{':
+			// Grace notes take no time of their own
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return fmt.Errorf("unterminated grace notes in ABC music: %s", line)
+			}
+			i += end + 1
+		case c == '[' && i+2 < len(s) && s[i+2] == ':' && abcField.MatchString(s[i+1:i+3]):
+			// Inline field, e.g. [K:Dm]
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return fmt.Errorf("unterminated inline field in ABC music: %s", line)
+			}
+			field, value := s[i+1:i+2], strings.TrimSpace(s[i+3:i+end])
+			if err := p.setField(field, value); err != nil {
+				return err
+			}
+			voice = p.voice()
+			i += end + 1
+		case c == '|' || c == ':' || (c == '[' && i+1 < len(s) && (s[i+1] == '|' || isDigit(s[i+1]))):
+			// Bar lines, repeats and first/second endings
+			for i < len(s) && strings.IndexByte("|:[]", s[i]) >= 0 {
+				i++
+			}
+			for i < len(s) && isDigit(s[i]) {
+				i++
+			}
+			add(abcItem{kind: abcBar})
+			p.accidentals = map[int]int{}
+		case c == '[':
+			// Chord: its length is its first note's times any length after it
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return fmt.Errorf("unterminated chord in ABC music: %s", line)
+			}
+			chord := abcItem{kind: abcNote}
+			for j := i + 1; j < i+end; {
+				if s[j] == '-' {
+					chord.tie = true
+					j++
+					continue
+				}
+				key, ticks, n, err := p.parseNote(s[j : i+end])
+				if err != nil {
+					return err
+				}
+				if n == 0 {
+					j++
+					continue
+				}
+				if len(chord.keys) == 0 {
+					chord.ticks = ticks
+				}
+				chord.keys = append(chord.keys, key)
+				j += n
+			}
+			i += end + 1
+			num, den, n := parseABCLength(s[i:])
+			i += n
+			chord.ticks = p.tupletTicks(chord.ticks * num / den)
+			if len(chord.keys) > 0 {
+				add(chord)
+			}
+		case c == '(' && i+1 < len(s) && isDigit(s[i+1]):
+			n, err := p.parseTuplet(s[i:])
+			if err != nil {
+				return err
+			}
+			i += n
+		case c == '-':
+			if last := lastNote(); last != nil {
+				last.tie = true
+			}
+			i++
+		case c == '>' || c == '<':
+			n := 0
+			for i < len(s) && s[i] == c {
+				n++
+				i++
+			}
+			if c == '<' {
+				n = -n
+			}
+			if last := lastNote(); last != nil {
+				last.broken = n
+			}
+		case c == 'z' || c == 'x':
+			num, den, n := parseABCLength(s[i+1:])
+			unit := p.unitLength()
+			add(abcItem{kind: abcRest, ticks: p.tupletTicks(abcWholeNote * unit[0] * num / (unit[1] * den))})
+			i += 1 + n
+		case c == 'Z' || c == 'X':
+			// Multi-bar rest, one bar by default
+			j := i + 1
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			bars := int64(1)
+			if j > i+1 {
+				bars, _ = strconv.ParseInt(s[i+1:j], 10, 64)
+			}
+			meter := p.meter
+			if meter[0] == 0 {
+				meter = [2]int{4, 4}
+			}
+			add(abcItem{kind: abcRest, ticks: bars * abcWholeNote * int64(meter[0]) / int64(meter[1])})
+			i = j
+		case strings.IndexByte("^_=ABCDEFGabcdefg", c) >= 0:
+			key, ticks, n, err := p.parseNote(s[i:])
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return fmt.Errorf("invalid note in ABC music at %q", s[i:])
+			}
+			add(abcItem{kind: abcNote, keys: []int{key}, ticks: p.tupletTicks(ticks)})
+			i += n
+		default:
+			// Spaces, slurs, decorations such as ~ and . and anything else
+			// that does not affect the notes
+			i++
+		}
+	}
+	return nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parseNote parses a note with its accidental, octave marks and length at
+// the start of s, returning its MIDI key, length in ticks and the number of
+// bytes read, 0 if s does not start with a note
+func (p *abcParser) parseNote(s string) (int, int64, int, error) {
+	alter, i := parseABCAccidental(s)
+	explicit := i > 0
+	if i >= len(s) || strings.IndexByte("ABCDEFGabcdefg", s[i]) < 0 {
+		if explicit {
+			return 0, 0, 0, fmt.Errorf("accidental without a note in ABC music at %q", s)
+		}
+		return 0, 0, 0, nil
+	}
+
+	letter := s[i]
+	octave := 4
+	if letter >= 'a' {
+		octave = 5
+		letter -= 'a' - 'A'
+	}
+	i++
+	for i < len(s) && (s[i] == '\'' || s[i] == ',') {
+		if s[i] == '\'' {
+			octave++
+		} else {
+			octave--
+		}
+		i++
+	}
+
+	// Accidentals last until the end of the bar for the same letter and octave
+	step := octave*7 + strings.IndexByte("CDEFGAB", letter)
+	switch {
+	case explicit:
+		p.accidentals[step] = alter
+	default:
+		var ok bool
+		if alter, ok = p.accidentals[step]; !ok {
+			alter = p.key[letter]
+		}
+	}
+	key := (octave+1)*12 + abcSemitones[letter] + alter
+	if key < 0 || key > 127 {
+		return 0, 0, 0, fmt.Errorf("note out of MIDI range in ABC music at %q", s)
+	}
+
+	num, den, n := parseABCLength(s[i:])
+	unit := p.unitLength()
+	return key, abcWholeNote * unit[0] * num / (unit[1] * den), i + n, nil
+}
+
+// parseABCLength parses a note length multiplier such as "2", "3/2", "/"
+// or "//" at the start of s, returning it as a fraction and the number of
+// bytes read
+func parseABCLength(s string) (int64, int64, int) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	num := int64(1)
+	if i > 0 {
+		num, _ = strconv.ParseInt(s[:i], 10, 64)
+	}
+	den := int64(1)
+	for i < len(s) && s[i] == '/' {
+		i++
+		j := i
+		for j < len(s) && isDigit(s[j]) {
+			j++
+		}
+		if j > i {
+			d, _ := strconv.ParseInt(s[i:j], 10, 64)
+			den *= max(d, 1)
+		} else {
+			den *= 2
+		}
+		i = j
+	}
+	return num, den, i
+}
+
+// parseTuplet parses a tuplet "(p", "(p:q" or "(p:q:r" at the start of s:
+// the next r notes (p by default) take the time of q
+func (p *abcParser) parseTuplet(s string) (int, error) {
+	m := abcTuplet.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid tuplet in ABC music at %q", s)
+	}
+	notes, _ := strconv.Atoi(m[1])
+	if notes < 2 || notes > 9 {
+		return 0, fmt.Errorf("invalid tuplet in ABC music at %q", s)
+	}
+
+	// The default time of a tuplet depends on whether the meter is compound
+	time := map[int]int{2: 3, 3: 2, 4: 3, 6: 2, 8: 3}[notes]
+	if time == 0 {
+		time = 2
+		if p.meter[0]%3 == 0 && p.meter[0] > 3 {
+			time = 3
+		}
+	}
+	if m[2] != "" {
+		time, _ = strconv.Atoi(m[2])
+	}
+	count := notes
+	if m[3] != "" {
+		count, _ = strconv.Atoi(m[3])
+	}
+	if time < 1 || count < 1 {
+		return 0, fmt.Errorf("invalid tuplet in ABC music at %q", s)
+	}
+
+	p.tuplet.left, p.tuplet.num, p.tuplet.den = count, int64(time), int64(notes)
+	return len(m[0]), nil
+}
+
+// tupletTicks scales the length of a note in a tuplet
+func (p *abcParser) tupletTicks(ticks int64) int64 {
+	if p.tuplet.left == 0 {
+		return ticks
+	}
+	p.tuplet.left--
+	return ticks * p.tuplet.num / p.tuplet.den
+}
+
+// abcLyric is a token of a w: line
+type abcLyric struct {
+	text string // Syllable, empty for a held or skipped note
+	bar  bool   // "|": move to the next bar
+}
+
+// parseABCWords splits a w: line into a token per note, plus bar moves
+func parseABCWords(line string) []abcLyric {
+	var tokens []abcLyric
+	var syllable strings.Builder
+	hyphen := false
+	flush := func(suffix string) bool {
+		if syllable.Len() == 0 {
+			return false
+		}
+		tokens = append(tokens, abcLyric{text: syllable.String() + suffix})
+		syllable.Reset()
+		return true
+	}
+
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case ' ', '\t':
+			flush("")
+			hyphen = false
+		case '-':
+			// A second hyphen leaves a note without a syllable
+			if !flush("-") && hyphen {
+				tokens = append(tokens, abcLyric{})
+			}
+			hyphen = true
+		case '_', '*':
+			flush("")
+			tokens = append(tokens, abcLyric{})
+			hyphen = false
+		case '|':
+			flush("")
+			tokens = append(tokens, abcLyric{bar: true})
+			hyphen = false
+		case '~':
+			syllable.WriteByte(' ')
+		case '\\':
+			if i+1 < len(line) && line[i+1] == '-' {
+				syllable.WriteByte('-')
+				i++
+			}
+		default:
+			syllable.WriteByte(c)
+			hyphen = false
+		}
+	}
+	flush("")
+	return tokens
+}
+
+// score lays out the parsed voices in time
+func (p *abcParser) score() (*Score, error) {
+	if len(p.voices) == 0 {
+		return nil, fmt.Errorf("no notes found in ABC tune")
+	}
+
+	type tickNote struct {
+		key        int
+		start, end int64
+	}
+	type lyricNote struct {
+		tick int64
+		bar  int
+	}
+	score := &Score{Format: 1, TicksPerQuarter: musicXMLTicksPerQuarter}
+	var events []timelineEvent
+
+	for number, voice := range p.voices {
+		items := voice.items
+		applyBrokenRhythms(items)
+
+		var notes []tickNote
+		var lyrics []Lyric
+		var pending []lyricNote // Notes waiting for a w: line
+		tied := map[int]int{}   // Notes waiting for the rest of their tie, by key
+		var cursor, barStart int64
+		bar := 1
+
+		for _, item := range items {
+			switch item.kind {
+			case abcNote:
+				sung := false
+				continuing := map[int]int{}
+				for _, key := range item.keys {
+					if i, ok := tied[key]; ok {
+						notes[i].end = cursor + item.ticks
+						if item.tie {
+							continuing[key] = i
+						}
+						continue
+					}
+					notes = append(notes, tickNote{key: key, start: cursor, end: cursor + item.ticks})
+					if item.tie {
+						continuing[key] = len(notes) - 1
+					}
+					sung = true
+				}
+				tied = continuing
+				// Tied continuations carry no syllable
+				if sung {
+					pending = append(pending, lyricNote{tick: cursor, bar: bar})
+				}
+				cursor += item.ticks
+			case abcRest:
+				tied = map[int]int{}
+				cursor += item.ticks
+			case abcBar:
+				if cursor > barStart {
+					bar++
+					barStart = cursor
+				}
+			case abcWords:
+				i := 0
+				for _, token := range parseABCWords(item.text) {
+					if token.bar {
+						for i > 0 && i < len(pending) && pending[i].bar == pending[i-1].bar {
+							i++
+						}
+						continue
+					}
+					if i >= len(pending) {
+						break
+					}
+					if token.text != "" {
+						lyrics = append(lyrics, Lyric{Tick: pending[i].tick, Text: token.text})
+					}
+					i++
+				}
+				pending = nil
+			case abcMeter:
+				events = append(events, timelineEvent{tick: cursor, numerator: item.meter[0], denominator: item.meter[1]})
+			case abcTempo:
+				events = append(events, timelineEvent{tick: cursor, bpm: item.bpm})
+			}
+		}
+
+		track := ScoreTrack{Number: number, Name: voice.name, Program: -1, ChannelPrograms: map[int]int{}, Lyrics: lyrics}
+		if track.Name == "" && len(p.voices) == 1 {
+			track.Name = p.title
+		}
+		score.Tracks = append(score.Tracks, track)
+		for _, note := range notes {
+			score.Tracks[number].Notes = append(score.Tracks[number].Notes, ScoreNote{
+				Key: note.key, Velocity: musicXMLForte, Channel: number % 16, StartTick: note.start, EndTick: note.end,
+			})
+		}
+	}
+
+	score.setTimeline(events)
+
+	// Times and positions follow the tempo map, so are set once it is built
+	for t := range score.Tracks {
+		track := &score.Tracks[t]
+		for i, note := range track.Notes {
+			track.Notes[i] = score.newNote(note.Key, note.Velocity, note.Channel, note.StartTick, note.EndTick)
+		}
+		sortNotes(track.Notes)
+		for i := range track.Lyrics {
+			track.Lyrics[i].Position = score.PositionAt(track.Lyrics[i].Tick)
+		}
+	}
+	return score, nil
+}
+
+// applyBrokenRhythms lengthens and shortens the notes either side of ">"
+// and "<": a>b plays a dotted and b halved, a>>b double dotted and quartered
+func applyBrokenRhythms(items []abcItem) {
+	for i := range items {
+		n := items[i].broken
+		if n == 0 {
+			continue
+		}
+		next := -1
+		for j := i + 1; j < len(items); j++ {
+			if items[j].kind == abcNote || items[j].kind == abcRest {
+				next = j
+				break
+			}
+		}
+		if next < 0 {
+			continue
+		}
+		long, short := &items[i], &items[next]
+		if n < 0 {
+			long, short, n = short, long, -n
+		}
+		long.ticks = long.ticks * (1<<(n+1) - 1) >> n
+		short.ticks >>= n
+	}
+}
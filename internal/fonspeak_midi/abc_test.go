@@ -0,0 +1,203 @@
+package fonspeak_midi
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// adonOlamABC is a tune at 60 BPM in 3/4 with a broken rhythm, a tie over
+// the bar, a rest, a triplet and a closing chord
+const adonOlamABC = `X:1
+T:Adon Olam
+M:3/4
+L:1/4
+Q:1/4=60
+K:Dm
+A2 B | c>d e- | e2 z | (3def [FA]2 |]
+w: A-don o-lam a-sher
+`
+
+func parseABC(t *testing.T, tune string) *Score {
+	t.Helper()
+	score, err := ParseABC(context.Background(), strings.NewReader(tune))
+	if err != nil {
+		t.Fatalf("ParseABC() error: %v", err)
+	}
+	return score
+}
+
+func TestParseABC(t *testing.T) {
+	score := parseABC(t, adonOlamABC)
+	if len(score.Tracks) != 1 || score.Tracks[0].Name != "Adon Olam" {
+		t.Fatalf("tracks = %+v, want one named after the title", score.Tracks)
+	}
+	if score.Tempos[0].BPM != 60 || score.Meters[0].Numerator != 3 || score.Meters[0].Denominator != 4 {
+		t.Errorf("tempo %v, meter %v, want 60 BPM in 3/4", score.Tempos[0], score.Meters[0])
+	}
+
+	type note struct {
+		key             int
+		start, duration float64
+	}
+	want := []note{
+		{69, 0, 2}, {70, 2, 1}, // B is flat in D minor
+		{72, 3, 1.5}, {74, 4.5, 0.5}, // c>d
+		{76, 5, 3}, // e tied over the bar
+		{74, 9, 2.0 / 3}, {76, 9 + 2.0/3, 2.0 / 3}, {77, 9 + 4.0/3, 2.0 / 3},
+		{65, 11, 2}, {69, 11, 2},
+	}
+	notes := score.Tracks[0].Notes
+	if len(notes) != len(want) {
+		t.Fatalf("got %d notes, want %d: %+v", len(notes), len(want), notes)
+	}
+	for i, w := range want {
+		n := notes[i]
+		if n.Key != w.key || math.Abs(n.Start-w.start) > 1e-9 || math.Abs(n.Duration-w.duration) > 1e-9 {
+			t.Errorf("note %d = key %d at %gs for %gs, want key %d at %gs for %gs", i, n.Key, n.Start, n.Duration, w.key, w.start, w.duration)
+		}
+	}
+	if pos := notes[4].Position; pos.Bar != 2 || pos.Beat != 3 {
+		t.Errorf("tied e at %+v, want bar 2 beat 3", pos)
+	}
+
+	var lyrics []string
+	for _, lyric := range score.Tracks[0].Lyrics {
+		lyrics = append(lyrics, lyric.Text)
+	}
+	if want := []string{"A-", "don", "o-", "lam", "a-", "sher"}; !reflect.DeepEqual(lyrics, want) {
+		t.Errorf("lyrics = %q, want %q", lyrics, want)
+	}
+	// The tie's continuation takes no syllable, so "sher" is on the triplet
+	if tick := score.Tracks[0].Lyrics[5].Tick; tick != notes[5].StartTick {
+		t.Errorf("sher at tick %d, want %d", tick, notes[5].StartTick)
+	}
+}
+
+func TestParseABC_Pitches(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		music string
+		want  []int
+	}{
+		{"Octaves", "C", "C, C c c'", []int{48, 60, 72, 84}},
+		{"Key signature", "G", "F f", []int{66, 78}},
+		{"Mode", "E phr", "F G", []int{65, 67}},
+		{"Key with accidental", "D phr ^f", "E F B", []int{63, 66, 70}},
+		{"Explicit key", "D exp ^f", "F B", []int{66, 71}},
+		{"Accidentals last the bar", "C", "^F F f | F", []int{66, 66, 77, 65}},
+		{"Natural", "F", "B =B", []int{70, 71}},
+		{"Double accidentals", "C", "^^C __E", []int{62, 62}},
+		{"Decorations and chord symbols", "C", `"Am"!trill!~C .D {E}F`, []int{60, 62, 65}},
+		{"Inline key change", "C", "F [K:G] F", []int{65, 66}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := parseABC(t, "X:1\nK:"+tt.key+"\n"+tt.music+"\n")
+			var keys []int
+			for _, note := range score.Tracks[0].Notes {
+				keys = append(keys, note.Key)
+			}
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("keys = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseABC_Lengths(t *testing.T) {
+	// The default unit is an eighth, 0.25s at 120 BPM
+	score := parseABC(t, "X:1\nM:4/4\nK:C\nC C2 C/ C// C3/2 C>C C<C\n")
+	var got []float64
+	for _, note := range score.Tracks[0].Notes {
+		got = append(got, note.Duration)
+	}
+	want := []float64{0.25, 0.5, 0.125, 0.0625, 0.375, 0.375, 0.125, 0.125, 0.375}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("durations = %v, want %v", got, want)
+	}
+}
+
+func TestParseABC_Voices(t *testing.T) {
+	score := parseABC(t, `X:1
+M:2/4
+L:1/4
+V:S name="Soprano"
+V:A name="Alto"
+K:C
+V:S
+e f |
+w: A-don
+V:A
+c c |
+`)
+	if len(score.Tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(score.Tracks))
+	}
+	if s, a := score.Tracks[0], score.Tracks[1]; s.Name != "Soprano" || a.Name != "Alto" || len(s.Lyrics) != 2 || len(a.Lyrics) != 0 {
+		t.Errorf("tracks = %+v, want a soprano with the lyrics and an alto", score.Tracks)
+	}
+	if n := score.Tracks[1].Notes[0]; n.Start != 0 || n.Channel != 1 {
+		t.Errorf("alto starts with %+v, want it at 0s on channel 2", n)
+	}
+}
+
+func TestParseABCWords(t *testing.T) {
+	tests := []struct {
+		line string
+		want []abcLyric
+	}{
+		{"a-don o-lam", []abcLyric{{text: "a-"}, {text: "don"}, {text: "o-"}, {text: "lam"}}},
+		{"a_ _ don", []abcLyric{{text: "a"}, {}, {}, {text: "don"}}},
+		{"a * don", []abcLyric{{text: "a"}, {}, {text: "don"}}},
+		{"a--don", []abcLyric{{text: "a-"}, {}, {text: "don"}}},
+		{"a|don", []abcLyric{{text: "a"}, {bar: true}, {text: "don"}}},
+		{`a~don b\-c`, []abcLyric{{text: "a don"}, {text: "b-c"}}},
+	}
+	for _, tt := range tests {
+		if got := parseABCWords(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseABCWords(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseABC_WordsSkipToBar(t *testing.T) {
+	score := parseABC(t, "X:1\nL:1/4\nK:C\nC D E F | G A B c |\nw: a | don\n")
+	lyrics := score.Tracks[0].Lyrics
+	if len(lyrics) != 2 || lyrics[1].Tick != score.Tracks[0].Notes[4].StartTick {
+		t.Errorf("lyrics = %+v, want don on the first note of bar 2", lyrics)
+	}
+}
+
+func TestParseABC_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		tune string
+	}{
+		{"No key", "X:1\nT:Header only\n"},
+		{"Invalid key", "X:1\nK:H#\nC\n"},
+		{"Invalid meter", "X:1\nM:waltz\nK:C\nC\n"},
+		{"Unterminated chord", "X:1\nK:C\n[CEG\n"},
+		{"Accidental without note", "X:1\nK:C\nC ^ D\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseABC(context.Background(), strings.NewReader(tt.tune)); err == nil {
+				t.Error("ParseABC() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestReadScore_ABC(t *testing.T) {
+	score, err := ReadScore(context.Background(), strings.NewReader("% nusach\n\n"+adonOlamABC))
+	if err != nil {
+		t.Fatalf("ReadScore() error: %v", err)
+	}
+	if len(score.Tracks[0].Lyrics) != 6 {
+		t.Errorf("got %d lyrics, want the tune read as ABC", len(score.Tracks[0].Lyrics))
+	}
+}
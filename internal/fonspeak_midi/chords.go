@@ -0,0 +1,125 @@
+package fonspeak_midi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChordPolicy chooses which note of a chord a monophonic melody keeps
+type ChordPolicy string
+
+const (
+	// ChordLowest keeps the lowest note (the original behavior)
+	ChordLowest ChordPolicy = "lowest"
+	// ChordHighest keeps the highest note, the "skyline" of the arrangement
+	ChordHighest ChordPolicy = "highest"
+	// ChordLoudest keeps the note with the highest velocity
+	ChordLoudest ChordPolicy = "loudest"
+	// ChordClosest keeps the note nearest the previous melody note
+	ChordClosest ChordPolicy = "closest"
+	// ChordVoice keeps the nth note counting down from the highest
+	ChordVoice ChordPolicy = "voice"
+)
+
+// chordPolicies lists the policies in the order they are documented
+var chordPolicies = []ChordPolicy{ChordLowest, ChordHighest, ChordLoudest, ChordClosest, ChordVoice}
+
+// DefaultChordThreshold groups notes starting within this many seconds as a
+// chord. 10ms accounts for MIDI timing jitter while still detecting true chords.
+const DefaultChordThreshold = 0.010
+
+// ParseChordPolicy converts a policy name into a ChordPolicy
+// An empty name selects the default lowest policy
+func ParseChordPolicy(name string) (ChordPolicy, error) {
+	if name == "" {
+		return ChordLowest, nil
+	}
+	for _, policy := range chordPolicies {
+		if ChordPolicy(name) == policy {
+			return policy, nil
+		}
+	}
+	names := make([]string, len(chordPolicies))
+	for i, policy := range chordPolicies {
+		names[i] = "'" + string(policy) + "'"
+	}
+	return "", fmt.Errorf("invalid chord policy: %s (must be one of %s)", name, strings.Join(names, ", "))
+}
+
+// ChordReduction configures how simultaneous notes are reduced to one
+type ChordReduction struct {
+	Policy    ChordPolicy // Which note of a chord to keep, ChordLowest if empty
+	Voice     int         // 1-based voice counted down from the highest note, for ChordVoice
+	Threshold float64     // Notes starting within this many seconds form a chord, DefaultChordThreshold if zero
+}
+
+// DefaultChordReduction keeps the lowest note of chords within 10ms
+func DefaultChordReduction() ChordReduction {
+	return ChordReduction{Policy: ChordLowest, Threshold: DefaultChordThreshold}
+}
+
+// Validate checks the policy, voice and threshold
+func (c ChordReduction) Validate() error {
+	if _, err := ParseChordPolicy(string(c.Policy)); err != nil {
+		return err
+	}
+	if c.Policy == ChordVoice && c.Voice < 1 {
+		return fmt.Errorf("chord voice must be at least 1, got %d", c.Voice)
+	}
+	if c.Threshold < 0 {
+		return fmt.Errorf("chord threshold must not be negative, got %g", c.Threshold)
+	}
+	return nil
+}
+
+// withDefaults fills in zero fields
+func (c ChordReduction) withDefaults() ChordReduction {
+	if c.Policy == "" {
+		c.Policy = ChordLowest
+	}
+	if c.Threshold == 0 {
+		c.Threshold = DefaultChordThreshold
+	}
+	return c
+}
+
+// pick chooses the note of chord to keep. prevKey is the key of the previous
+// melody note, or -1 at the start of the melody. Ties go to the higher note.
+func (c ChordReduction) pick(chord []ScoreNote, prevKey int) ScoreNote {
+	// Highest first, so the first note found wins ties
+	sorted := append([]ScoreNote(nil), chord...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Key > sorted[j].Key })
+
+	chosen := sorted[0]
+	switch c.Policy {
+	case ChordLowest:
+		chosen = sorted[len(sorted)-1]
+	case ChordLoudest:
+		for _, note := range sorted[1:] {
+			if note.Velocity > chosen.Velocity {
+				chosen = note
+			}
+		}
+	case ChordClosest:
+		if prevKey < 0 {
+			break
+		}
+		for _, note := range sorted[1:] {
+			if abs(note.Key-prevKey) < abs(chosen.Key-prevKey) {
+				chosen = note
+			}
+		}
+	case ChordVoice:
+		// Chords with fewer voices fall back to their lowest note
+		chosen = sorted[min(c.Voice, len(sorted))-1]
+	}
+	return chosen
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
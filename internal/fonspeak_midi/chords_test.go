@@ -0,0 +1,127 @@
+package fonspeak_midi
+
+import (
+	"context"
+	"testing"
+)
+
+// chordScore parses two three-note chords, C major then a G7 voicing, with
+// velocities set per key
+func chordScore(t *testing.T) *Score {
+	t.Helper()
+
+	r := buildTestMIDI(t, []testNote{
+		{start: 0, dur: 960, key: 60},
+		{start: 0, dur: 960, key: 64},
+		{start: 0, dur: 960, key: 67},
+		{start: 960, dur: 960, key: 59},
+		{start: 960, dur: 960, key: 65},
+		{start: 960, dur: 960, key: 74},
+	})
+	score, err := ParseScore(context.Background(), r)
+	if err != nil {
+		t.Fatalf("ParseScore() error: %v", err)
+	}
+
+	velocities := map[int]int{60: 80, 64: 100, 67: 90, 59: 100, 65: 70, 74: 70}
+	for i := range score.Tracks[0].Notes {
+		note := &score.Tracks[0].Notes[i]
+		note.Velocity = velocities[note.Key]
+	}
+	return score
+}
+
+func TestScore_ReduceMelody(t *testing.T) {
+	tests := []struct {
+		name      string
+		reduction ChordReduction
+		want      []int
+	}{
+		{"default", ChordReduction{}, []int{60, 59}},
+		{"lowest", ChordReduction{Policy: ChordLowest}, []int{60, 59}},
+		{"highest", ChordReduction{Policy: ChordHighest}, []int{67, 74}},
+		{"loudest", ChordReduction{Policy: ChordLoudest}, []int{64, 59}},
+		{"closest starts on top", ChordReduction{Policy: ChordClosest}, []int{67, 65}},
+		{"second voice", ChordReduction{Policy: ChordVoice, Voice: 2}, []int{64, 65}},
+		{"missing voice falls back to lowest", ChordReduction{Policy: ChordVoice, Voice: 5}, []int{60, 59}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notes, err := chordScore(t).ReduceMelody(0, tt.reduction)
+			if err != nil {
+				t.Fatalf("ReduceMelody() error: %v", err)
+			}
+			if len(notes) != len(tt.want) {
+				t.Fatalf("got %d notes, want %d", len(notes), len(tt.want))
+			}
+			for i, key := range tt.want {
+				if notes[i].MIDINote != key {
+					t.Errorf("note %d = %d, want %d", i, notes[i].MIDINote, key)
+				}
+			}
+		})
+	}
+}
+
+func TestScore_ReduceMelody_Threshold(t *testing.T) {
+	// The upper note starts 30 ticks (about 16ms) late
+	r := buildTestMIDI(t, []testNote{
+		{start: 0, dur: 960, key: 60},
+		{start: 30, dur: 930, key: 67},
+	})
+	score, err := ParseScore(context.Background(), r)
+	if err != nil {
+		t.Fatalf("ParseScore() error: %v", err)
+	}
+
+	notes, err := score.ReduceMelody(0, ChordReduction{Policy: ChordHighest})
+	if err != nil {
+		t.Fatalf("ReduceMelody() error: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Errorf("default threshold: got %d notes, want 2 separate onsets", len(notes))
+	}
+
+	notes, err = score.ReduceMelody(0, ChordReduction{Policy: ChordHighest, Threshold: 0.020})
+	if err != nil {
+		t.Fatalf("ReduceMelody() error: %v", err)
+	}
+	if len(notes) != 1 || notes[0].MIDINote != 67 {
+		t.Errorf("20ms threshold: got %+v, want a single chord reduced to 67", notes)
+	}
+}
+
+func TestChordReduction_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		reduction ChordReduction
+		wantErr   bool
+	}{
+		{"zero value", ChordReduction{}, false},
+		{"voice", ChordReduction{Policy: ChordVoice, Voice: 1}, false},
+		{"voice without index", ChordReduction{Policy: ChordVoice}, true},
+		{"unknown policy", ChordReduction{Policy: "median"}, true},
+		{"negative threshold", ChordReduction{Threshold: -0.01}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.reduction.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseChordPolicy(t *testing.T) {
+	for _, name := range []string{"lowest", "highest", "loudest", "closest", "voice"} {
+		if got, err := ParseChordPolicy(name); err != nil || string(got) != name {
+			t.Errorf("ParseChordPolicy(%q) = %q, %v", name, got, err)
+		}
+	}
+	if got, err := ParseChordPolicy(""); err != nil || got != ChordLowest {
+		t.Errorf("ParseChordPolicy(\"\") = %q, %v; want lowest", got, err)
+	}
+	if _, err := ParseChordPolicy("skyline"); err == nil {
+		t.Error("ParseChordPolicy(\"skyline\") succeeded, want an error")
+	}
+}
@@ -0,0 +1,238 @@
+package fonspeak_midi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AutoTrack asks for the melody track to be detected rather than given
+const AutoTrack = -1
+
+// ParseTrack parses a track number, accepting "auto" for AutoTrack and
+// "all" for AllTracks
+func ParseTrack(s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "auto":
+		return AutoTrack, nil
+	case "all":
+		return AllTracks, nil
+	}
+	track, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || track < 0 {
+		return 0, fmt.Errorf("invalid track %q, expected a track number, auto or all", s)
+	}
+	return track, nil
+}
+
+// ParseChannel parses a 1-based MIDI channel, accepting "" or "all" for
+// AllChannels
+func ParseChannel(s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "all":
+		return AllChannels, nil
+	}
+	channel, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || channel < AllChannels || channel > 16 {
+		return 0, fmt.Errorf("invalid channel %q, expected 1-16 or all", s)
+	}
+	return channel, nil
+}
+
+// Weights of the melody detection criteria, each scored from 0 to 1
+const (
+	monophonyWeight = 3.0
+	rangeWeight     = 2.0
+	densityWeight   = 1.0
+	fitWeight       = 2.0
+	nameWeight      = 2.0
+)
+
+// Singable range and tempo of a hymn tune
+const (
+	voiceLowKey     = 48 // C3
+	voiceHighKey    = 84 // C6
+	voiceMaxSpan    = 24 // Two octaves
+	minNotesPerSec  = 0.5
+	maxNotesPerSec  = 5.0
+	maxVersePasses  = 8
+	minMatchPercent = 50
+)
+
+// Track name fragments that suggest a track is, or is not, the tune
+var (
+	melodyNameHints = []string{"melody", "voice", "vocal", "soprano", "tune", "lead", "sing", "cantor"}
+	accompNameHints = []string{"bass", "drum", "perc", "accomp", "chord", "pad", "alto", "tenor", "left"}
+)
+
+// TrackCandidate is a track scored as the possible melody, with the reasons
+// for its score
+type TrackCandidate struct {
+	Track   int      `json:"track"`
+	Channel int      `json:"channel,omitempty"` // 1-based channel within Track, AllChannels for all
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+// Source names the track and channel, e.g. "track 0, channel 2"
+func (c TrackCandidate) Source() string {
+	if c.Channel == AllChannels {
+		return fmt.Sprintf("track %d", c.Track)
+	}
+	return fmt.Sprintf("track %d, channel %d", c.Track, c.Channel)
+}
+
+// String describes the candidate, e.g. "track 2 (monophonic, ...)"
+func (c TrackCandidate) String() string {
+	return fmt.Sprintf("%s (%s)", c.Source(), strings.Join(c.Reasons, "; "))
+}
+
+// RankMelodyTracks scores every track with notes as the possible melody of
+// a song of syllables syllables, best first. Tracks are scored by how
+// monophonic they are, whether their range suits a voice, their note
+// density, name hints and how well their notes fit the syllables; a
+// syllable count of 0 skips the last. Drum tracks are never candidates.
+// Format 0 files with several channels are scored channel by channel. A
+// 1-based channel other than AllChannels scores only the notes of each
+// track on that channel.
+func (s *Score) RankMelodyTracks(syllables, channel int) []TrackCandidate {
+	var summaries []TrackSummary
+	switch {
+	case channel != AllChannels:
+		for _, track := range s.Tracks {
+			selected, err := s.Select(track.Number, channel)
+			if err != nil {
+				return []TrackCandidate{}
+			}
+			summaries = append(summaries, selected.Summary())
+		}
+	case s.SplitByChannel():
+		summaries, _ = s.ChannelSummaries(0)
+	default:
+		summaries = s.Summaries()
+	}
+
+	candidates := []TrackCandidate{}
+	for _, summary := range summaries {
+		if !summary.HasNotes() || summary.IsPercussion() {
+			continue
+		}
+		on := channel
+		if on == AllChannels && s.SplitByChannel() {
+			on = summary.Channels[0] + 1
+		}
+		melody, err := s.ChannelMelody(summary.Number, on, DefaultChordReduction())
+		if err != nil {
+			continue
+		}
+		candidate := scoreCandidate(summary, CountPitchedNotes(melody), syllables)
+		candidate.Channel = on
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+// DetectMelodyTrack returns the best scoring track of RankMelodyTracks
+func (s *Score) DetectMelodyTrack(syllables, channel int) (TrackCandidate, error) {
+	candidates := s.RankMelodyTracks(syllables, channel)
+	if len(candidates) == 0 {
+		if channel != AllChannels {
+			return TrackCandidate{}, fmt.Errorf("no track with notes on channel %d found", channel)
+		}
+		return TrackCandidate{}, fmt.Errorf("no track with notes found")
+	}
+	return candidates[0], nil
+}
+
+// scoreCandidate scores a track whose melody has melodyNotes notes once
+// chords are collapsed
+func scoreCandidate(t TrackSummary, melodyNotes, syllables int) TrackCandidate {
+	c := TrackCandidate{Track: t.Number}
+	add := func(weight, score float64, reason string, a ...any) {
+		c.Score += weight * score
+		c.Reasons = append(c.Reasons, fmt.Sprintf(reason, a...))
+	}
+
+	// Share of onsets that are single notes rather than chords
+	monophony := float64(melodyNotes) / float64(t.NoteCount)
+	if t.MaxPolyphony <= 1 {
+		add(monophonyWeight, monophony, "monophonic")
+	} else {
+		add(monophonyWeight, monophony, "%.0f%% single notes, up to %d at once", 100*monophony, t.MaxPolyphony)
+	}
+
+	rangeScore := 1.0
+	if t.LowestKey < voiceLowKey || t.HighestKey > voiceHighKey {
+		rangeScore /= 2
+	}
+	if span := t.HighestKey - t.LowestKey; span > voiceMaxSpan {
+		rangeScore *= float64(voiceMaxSpan) / float64(span)
+	}
+	if rangeScore == 1 {
+		add(rangeWeight, rangeScore, "range %s suits a voice", t.PitchRange())
+	} else {
+		add(rangeWeight, rangeScore, "range %s is hard to sing", t.PitchRange())
+	}
+
+	density := 0.0
+	if t.Duration > 0 {
+		density = float64(melodyNotes) / t.Duration
+	}
+	densityScore := 1.0
+	switch {
+	case density < minNotesPerSec:
+		densityScore = density / minNotesPerSec
+	case density > maxNotesPerSec:
+		densityScore = maxNotesPerSec / density
+	}
+	add(densityWeight, densityScore, "%.1f notes/s", density)
+
+	name := strings.ToLower(t.Name + " " + t.Instrument)
+	for _, hint := range melodyNameHints {
+		if strings.Contains(name, hint) {
+			add(nameWeight, 1, "name %q suggests the tune", strings.TrimSpace(t.Name+" "+t.Instrument))
+			break
+		}
+	}
+	for _, hint := range accompNameHints {
+		if strings.Contains(name, hint) {
+			add(nameWeight, -1, "name %q suggests accompaniment", strings.TrimSpace(t.Name+" "+t.Instrument))
+			break
+		}
+	}
+
+	if syllables > 0 {
+		passes, fit := syllableFit(melodyNotes, syllables)
+		if 100*fit >= minMatchPercent {
+			add(fitWeight, fit, "%d notes fit %d syllables in %d pass(es) (%.0f%%)", melodyNotes, syllables, passes, 100*fit)
+		} else {
+			add(fitWeight, fit, "%d notes fit %d syllables poorly (%.0f%%)", melodyNotes, syllables, 100*fit)
+		}
+	}
+
+	c.Score = math.Round(c.Score*100) / 100
+	return c
+}
+
+// syllableFit reports how well a melody of notes notes, repeated for each
+// verse, carries syllables syllables, as the number of passes through the
+// melody and a ratio from 0 to 1. Hymn tunes are usually sung once per
+// verse, so a tune with a quarter as many notes as there are syllables fits
+// as well as one with a note per syllable.
+func syllableFit(notes, syllables int) (int, float64) {
+	bestPasses, best := 1, 0.0
+	for passes := 1; passes <= maxVersePasses; passes++ {
+		sung := float64(notes * passes)
+		fit := math.Min(sung, float64(syllables)) / math.Max(sung, float64(syllables))
+		if fit > best {
+			bestPasses, best = passes, fit
+		}
+	}
+	return bestPasses, best
+}
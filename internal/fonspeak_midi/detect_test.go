@@ -0,0 +1,187 @@
+package fonspeak_midi
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// hymnScore builds a three track file: a named melody, block chords and a
+// bass line, each sixteen quarter notes at 120 BPM
+func hymnScore(t *testing.T, melodyName string) *Score {
+	t.Helper()
+
+	track := func(name string, channel uint8, keys ...uint8) smf.Track {
+		var tr smf.Track
+		tr.Add(0, smf.MetaTrackSequenceName(name))
+		for i := 0; i < 16; i++ {
+			for _, key := range keys {
+				tr.Add(0, midi.NoteOn(channel, key+uint8(i%4), 100))
+			}
+			for j, key := range keys {
+				delta := uint32(0)
+				if j == 0 {
+					delta = 960
+				}
+				tr.Add(delta, midi.NoteOff(channel, key+uint8(i%4)))
+			}
+		}
+		tr.Close(0)
+		return tr
+	}
+
+	s := smf.New()
+	for _, tr := range []smf.Track{
+		track("Chords", 1, 55, 60, 64),
+		track("Bass", 2, 36),
+		track(melodyName, 0, 67),
+	} {
+		if err := s.Add(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	score, err := ParseScore(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return score
+}
+
+func TestDetectMelodyTrack(t *testing.T) {
+	tests := []struct {
+		name       string
+		melodyName string
+		syllables  int
+	}{
+		{"named melody", "Soprano", 16},
+		{"unnamed melody", "Track 3", 16},
+		{"verses", "Track 3", 64},
+		{"no lyrics", "Track 3", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hymnScore(t, tt.melodyName).DetectMelodyTrack(tt.syllables, AllChannels)
+			if err != nil {
+				t.Fatalf("DetectMelodyTrack() error: %v", err)
+			}
+			if got.Track != 2 {
+				t.Errorf("DetectMelodyTrack() = %v, want track 2", got)
+			}
+			if len(got.Reasons) == 0 {
+				t.Error("DetectMelodyTrack() gave no reasons")
+			}
+		})
+	}
+}
+
+func TestRankMelodyTracks_Reasons(t *testing.T) {
+	ranked := hymnScore(t, "Melody").RankMelodyTracks(64, AllChannels)
+	if len(ranked) != 3 {
+		t.Fatalf("got %d candidates, want 3", len(ranked))
+	}
+	best := strings.Join(ranked[0].Reasons, "; ")
+	for _, want := range []string{"monophonic", `name "Melody" suggests the tune`, "16 notes fit 64 syllables in 4 pass(es) (100%)"} {
+		if !strings.Contains(best, want) {
+			t.Errorf("reasons %q missing %q", best, want)
+		}
+	}
+	if worst := ranked[len(ranked)-1]; worst.Track == 2 {
+		t.Errorf("melody ranked last: %v", ranked)
+	}
+}
+
+func TestDetectMelodyTrack_Format0(t *testing.T) {
+	got, err := format0Score(t).DetectMelodyTrack(8, AllChannels)
+	if err != nil {
+		t.Fatalf("DetectMelodyTrack() error: %v", err)
+	}
+	if got.Track != 0 || got.Channel != 2 {
+		t.Errorf("DetectMelodyTrack() = %v, want track 0, channel 2", got)
+	}
+	if got.Source() != "track 0, channel 2" {
+		t.Errorf("Source() = %q", got.Source())
+	}
+}
+
+func TestDetectMelodyTrack_Channel(t *testing.T) {
+	tests := []struct {
+		name    string
+		score   *Score
+		channel int
+		want    TrackCandidate
+		wantErr bool
+	}{
+		{name: "bass channel", score: hymnScore(t, "Melody"), channel: 3, want: TrackCandidate{Track: 1, Channel: 3}},
+		{name: "format 0 accompaniment", score: format0Score(t), channel: 1, want: TrackCandidate{Track: 0, Channel: 1}},
+		{name: "unused channel", score: hymnScore(t, "Melody"), channel: 5, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := tt.score.DetectMelodyTrack(64, tt.channel)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (got.Track != tt.want.Track || got.Channel != tt.want.Channel) {
+			t.Errorf("%s: DetectMelodyTrack() = %v, want %s", tt.name, got, tt.want.Source())
+		}
+	}
+}
+
+func TestParseChannel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"", AllChannels, false},
+		{"all", AllChannels, false},
+		{"1", 1, false},
+		{"16", 16, false},
+		{"17", 0, true},
+		{"-1", 0, true},
+		{"ch1", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseChannel(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseChannel(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDetectMelodyTrack_NoNotes(t *testing.T) {
+	if _, err := (&Score{}).DetectMelodyTrack(10, AllChannels); err == nil {
+		t.Error("DetectMelodyTrack() on an empty score succeeded, want an error")
+	}
+}
+
+func TestParseTrack(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"3", 3, false},
+		{"auto", AutoTrack, false},
+		{"all", AllTracks, false},
+		{"AUTO", AutoTrack, false},
+		{"-1", 0, true},
+		{"x", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTrack(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTrack(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -1,14 +1,231 @@
 package fonspeak_midi
 
 import (
+	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Alphabet is a phonetic alphabet lyrics can be written in
+type Alphabet string
+
+const (
+	// AlphabetXSAMPA is X-SAMPA, the ASCII transcription fonspeak sings
+	AlphabetXSAMPA Alphabet = "x-sampa"
+	// AlphabetIPA is the International Phonetic Alphabet
+	AlphabetIPA Alphabet = "ipa"
+)
+
+// xsampaSymbols pairs each X-SAMPA symbol with its IPA equivalent. Where a
+// sound has two spellings in one alphabet, the first listed is written.
+var xsampaSymbols = []struct{ xsampa, ipa string }{
+	// Lower case letters and their variants
+	{"a", "a"}, {"b", "b"}, {"b_<", "ɓ"}, {"c", "c"}, {"d", "d"}, {"d`", "ɖ"}, {"d_<", "ɗ"},
+	{"e", "e"}, {"f", "f"}, {"g", "ɡ"}, {"g_<", "ɠ"}, {"h", "h"}, {"h\\", "ɦ"}, {"i", "i"},
+	{"j", "j"}, {"j\\", "ʝ"}, {"k", "k"}, {"l", "l"}, {"l`", "ɭ"}, {"l\\", "ɺ"}, {"m", "m"},
+	{"n", "n"}, {"n`", "ɳ"}, {"o", "o"}, {"p", "p"}, {"p\\", "ɸ"}, {"q", "q"}, {"r", "r"},
+	{"r`", "ɽ"}, {"r\\", "ɹ"}, {"r\\`", "ɻ"}, {"s", "s"}, {"s`", "ʂ"}, {"s\\", "ɕ"}, {"t", "t"},
+	{"t`", "ʈ"}, {"u", "u"}, {"v", "v"}, {"v\\", "ʋ"}, {"P", "ʋ"}, {"w", "w"}, {"x", "x"},
+	{"x\\", "ɧ"}, {"y", "y"}, {"z", "z"}, {"z`", "ʐ"}, {"z\\", "ʑ"},
+
+	// Upper case letters and their variants
+	{"A", "ɑ"}, {"B", "β"}, {"B\\", "ʙ"}, {"C", "ç"}, {"D", "ð"}, {"E", "ɛ"}, {"F", "ɱ"},
+	{"G", "ɣ"}, {"G\\", "ɢ"}, {"G\\_<", "ʛ"}, {"H", "ɥ"}, {"H\\", "ʜ"}, {"I", "ɪ"}, {"I\\", "ᵻ"},
+	{"J", "ɲ"}, {"J\\", "ɟ"}, {"J\\_<", "ʄ"}, {"K", "ɬ"}, {"K\\", "ɮ"}, {"L", "ʎ"}, {"L\\", "ʟ"},
+	{"M", "ɯ"}, {"M\\", "ɰ"}, {"N", "ŋ"}, {"N\\", "ɴ"}, {"O", "ɔ"}, {"O\\", "ʘ"}, {"Q", "ɒ"},
+	{"R", "ʁ"}, {"R\\", "ʀ"}, {"S", "ʃ"}, {"T", "θ"}, {"U", "ʊ"}, {"U\\", "ᵿ"}, {"V", "ʌ"},
+	{"W", "ʍ"}, {"X", "χ"}, {"X\\", "ħ"}, {"Y", "ʏ"}, {"Z", "ʒ"},
+
+	// Affricates, written with a tie bar in IPA
+	{"tS", "t͡ʃ"}, {"dZ", "d͡ʒ"}, {"ts", "t͡s"}, {"dz", "d͡z"}, {"ts\\", "t͡ɕ"}, {"dz\\", "d͡ʑ"},
+
+	// Other symbols
+	{"@", "ə"}, {"@\\", "ɘ"}, {"@`", "ɚ"}, {"{", "æ"}, {"}", "ʉ"}, {"1", "ɨ"}, {"2", "ø"},
+	{"3", "ɜ"}, {"3\\", "ɞ"}, {"3`", "ɝ"}, {"4", "ɾ"}, {"5", "ɫ"}, {"6", "ɐ"}, {"7", "ɤ"},
+	{"8", "ɵ"}, {"9", "œ"}, {"&", "ɶ"}, {"?", "ʔ"}, {"?\\", "ʕ"}, {"<\\", "ʢ"}, {">\\", "ʡ"},
+	{"!\\", "ǃ"}, {"|\\", "ǀ"}, {"|\\|\\", "ǁ"}, {"=\\", "ǂ"}, {"-\\", "‿"},
+
+	// Suprasegmentals
+	{"\"", "ˈ"}, {"%", "ˌ"}, {":", "ː"}, {":\\", "ˑ"}, {".", "."}, {"|", "|"}, {"||", "‖"},
+	{"^", "ꜛ"}, {"!", "ꜜ"},
+
+	// Diacritics, which follow the symbol they modify
+	{"_\"", "̈"}, {"_+", "̟"}, {"_-", "̠"}, {"_/", "̌"}, {"_0", "̥"},
+	{"=", "̩"}, {"_=", "̩"}, {"_>", "ʼ"}, {"_?\\", "ˤ"}, {"_\\", "̂"},
+	{"_^", "̯"}, {"_}", "̚"}, {"`", "˞"}, {"~", "̃"}, {"_~", "̃"},
+	{"_A", "̘"}, {"_a", "̺"}, {"_B", "̏"}, {"_B_L", "᷅"}, {"_c", "̜"},
+	{"_d", "̪"}, {"_e", "̴"}, {"_F", "̂"}, {"_G", "ˠ"}, {"_H", "́"},
+	{"_H_T", "᷄"}, {"_h", "ʰ"}, {"_j", "ʲ"}, {"'", "ʲ"}, {"_k", "̰"}, {"_L", "̀"},
+	{"_l", "ˡ"}, {"_M", "̄"}, {"_m", "̻"}, {"_N", "̼"}, {"_n", "ⁿ"}, {"_O", "̹"},
+	{"_o", "̞"}, {"_q", "̙"}, {"_R", "̌"}, {"_R_F", "᷈"}, {"_r", "̝"},
+	{"_T", "̋"}, {"_t", "̤"}, {"_v", "̬"}, {"_w", "ʷ"}, {"_X", "̆"},
+	{"_x", "̽"},
+}
+
+// ipaAliases are IPA spellings read but never written: ligatures, ASCII g,
+// and tie bars, which X-SAMPA leaves out
+var ipaAliases = map[string]string{
+	"g": "g", "ʧ": "tS", "ʤ": "dZ", "ʦ": "ts", "ʣ": "dz", "ʨ": "ts\\", "ʥ": "dz\\",
+	"͡": "", "͜": "",
+}
+
+// xsampaToIPA and ipaToXSAMPA look up symbols in either direction, with the
+// length in bytes of their longest key
+var (
+	xsampaToIPA, ipaToXSAMPA = map[string]string{}, map[string]string{}
+	xsampaMaxLen, ipaMaxLen  int
 )
 
-// IPAToXSAMPA parses X-SAMPA text into individual syllables
-// The input should be space-separated syllables already in X-SAMPA format
-// The function name is kept for compatibility with the original design,
-// but it primarily splits the text into individual syllables
-func IPAToXSAMPA(text string) []string {
-	syllables := strings.Fields(text)
-	return syllables
+func init() {
+	for _, sym := range xsampaSymbols {
+		if _, ok := xsampaToIPA[sym.xsampa]; !ok {
+			xsampaToIPA[sym.xsampa] = sym.ipa
+		}
+		// IPA is matched decomposed, so ç is looked up as c and a cedilla
+		ipa := norm.NFD.String(sym.ipa)
+		if _, ok := ipaToXSAMPA[ipa]; !ok {
+			ipaToXSAMPA[ipa] = sym.xsampa
+		}
+		xsampaMaxLen = max(xsampaMaxLen, len(sym.xsampa))
+		ipaMaxLen = max(ipaMaxLen, len(ipa))
+	}
+	// X-SAMPA's separator has no IPA form
+	xsampaToIPA["-"] = ""
+	for ipa, xsampa := range ipaAliases {
+		ipaToXSAMPA[ipa] = xsampa
+	}
+}
+
+// DetectAlphabet reports the alphabet text is written in: IPA if it has any
+// characters outside ASCII, which X-SAMPA is limited to, and X-SAMPA otherwise
+func DetectAlphabet(text string) Alphabet {
+	for _, r := range text {
+		if r > unicode.MaxASCII {
+			return AlphabetIPA
+		}
+	}
+	return AlphabetXSAMPA
+}
+
+// ConvertIPAToXSAMPA transcribes IPA text to X-SAMPA, keeping whitespace.
+// Diacritics, length and stress marks carry over; tie bars are dropped, as
+// X-SAMPA writes affricates such as t͡ʃ as plain tS.
+func ConvertIPAToXSAMPA(ipa string) (string, error) {
+	return transcribe(norm.NFD.String(ipa), ipaToXSAMPA, ipaMaxLen, AlphabetIPA)
+}
+
+// ConvertXSAMPAToIPA transcribes X-SAMPA text to IPA, keeping whitespace.
+// The affricates tS, dZ, ts, dz, ts\ and dz\ are written with a tie bar, so
+// IPA that ties them survives a round trip through X-SAMPA unchanged.
+func ConvertXSAMPAToIPA(xsampa string) (string, error) {
+	ipa, err := transcribe(xsampa, xsampaToIPA, xsampaMaxLen, AlphabetXSAMPA)
+	if err != nil {
+		return "", err
+	}
+	return norm.NFC.String(ipa), nil
+}
+
+// transcribe replaces each symbol of text with its entry in symbols
+func transcribe(text string, symbols map[string]string, maxLen int, from Alphabet) (string, error) {
+	tokens, err := tokenize(text, symbols, maxLen, from)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, token := range tokens {
+		if out, ok := symbols[token]; ok {
+			b.WriteString(out)
+		} else {
+			b.WriteString(token)
+		}
+	}
+	return b.String(), nil
+}
+
+// tokenize splits text into the symbols of the table, matching the longest
+// symbol at each position, and single whitespace characters
+func tokenize(text string, symbols map[string]string, maxLen int, from Alphabet) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) {
+			tokens = append(tokens, text[i:i+size])
+			i += size
+			continue
+		}
+
+		n := min(maxLen, len(text)-i)
+		for ; n > 0; n-- {
+			if _, ok := symbols[text[i:i+n]]; ok {
+				break
+			}
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("unknown %s symbol %q in %q", alphabetName(from), r, wordAt(text, i))
+		}
+		tokens = append(tokens, text[i:i+n])
+		i += n
+	}
+	return tokens, nil
+}
+
+func alphabetName(a Alphabet) string {
+	if a == AlphabetIPA {
+		return "IPA"
+	}
+	return "X-SAMPA"
+}
+
+// wordAt returns the whitespace-delimited word around byte offset i
+func wordAt(text string, i int) string {
+	start := strings.LastIndexFunc(text[:i], unicode.IsSpace) + 1
+	end := strings.IndexFunc(text[i:], unicode.IsSpace)
+	if end < 0 {
+		return norm.NFC.String(text[start:])
+	}
+	return norm.NFC.String(text[start : i+end])
+}
+
+// IPAToXSAMPA parses lyrics written in IPA or X-SAMPA, detected with
+// DetectAlphabet, into X-SAMPA syllables. Syllables are separated by
+// whitespace, a syllable break (".") or X-SAMPA's separator ("-"). Stress
+// marks are dropped since each syllable is sung on its own note. Symbols
+// that are not part of the alphabet are reported as errors.
+func IPAToXSAMPA(text string) ([]string, error) {
+	if DetectAlphabet(text) == AlphabetIPA {
+		var err error
+		if text, err = ConvertIPAToXSAMPA(text); err != nil {
+			return nil, err
+		}
+	}
+	tokens, err := tokenize(text, xsampaToIPA, xsampaMaxLen, AlphabetXSAMPA)
+	if err != nil {
+		return nil, err
+	}
+
+	var syllables []string
+	var syllable strings.Builder
+	for _, token := range append(tokens, ".") {
+		switch {
+		case token == "." || token == "-" || strings.TrimSpace(token) == "":
+			if syllable.Len() > 0 {
+				syllables = append(syllables, syllable.String())
+				syllable.Reset()
+			}
+		case token != `"` && token != "%":
+			syllable.WriteString(token)
+		}
+	}
+	return syllables, nil
+}
+
+// xsampaVowels holds the X-SAMPA and IPA symbols that begin a vowel
+const xsampaVowels = "aeiouyAEIOUVYQM@{}1236789&" + "iyɨʉɯuɪʏʊeøɘɵɤoəɛœɜɞʌɔæɐaɶɑɒɚɝᵻᵿ"
+
+// IsVowelSymbol reports whether r begins a vowel in X-SAMPA or IPA
+func IsVowelSymbol(r rune) bool {
+	return strings.ContainsRune(xsampaVowels, r)
 }
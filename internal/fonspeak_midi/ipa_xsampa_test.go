@@ -0,0 +1,177 @@
+package fonspeak_midi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestConvertIPAToXSAMPA(t *testing.T) {
+	tests := []struct {
+		name string
+		ipa  string
+		want string
+	}{
+		{"Plain letters", "a don", "a don"},
+		{"Consonants and schwa", "ʃəχ", "S@X"},
+		{"Length and stress", "ˈnaː ˌlo", `"na: %lo`},
+		{"Affricate with tie bar", "t͡siʁ t͡ʃa", "tsiR tSa"},
+		{"Affricate ligature", "ʧa ʦa", "tSa tsa"},
+		{"Affricate without tie bar", "tʃa", "tSa"},
+		{"Diacritics", "tʰ ã n̩ kʷ tʲ", "t_h a~ n= k_w t_j"},
+		{"Precomposed and decomposed", "ç ç", "C C"},
+		{"Syllable break", "a.don", "a.don"},
+		{"Script g", "ɡ g", "g g"},
+		{"Retroflex and implosive", "ʈ ɖ ɓ", "t` d` b_<"},
+		{"Newlines kept", "a\nɔ", "a\nO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertIPAToXSAMPA(tt.ipa)
+			if err != nil {
+				t.Fatalf("ConvertIPAToXSAMPA(%q) error: %v", tt.ipa, err)
+			}
+			if got != tt.want {
+				t.Errorf("ConvertIPAToXSAMPA(%q) = %q, want %q", tt.ipa, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertXSAMPAToIPA(t *testing.T) {
+	tests := []struct {
+		xsampa string
+		want   string
+	}{
+		{"l@m", "ləm"},
+		{"na:", "naː"},
+		{"tsir", "t͡sir"},
+		{"r\\` s\\ ts\\", "ɻ ɕ t͡ɕ"},
+		{"a~", "ã"},
+		{"C", "ç"},
+		{"P v\\", "ʋ ʋ"},
+		{"t_j t'", "tʲ tʲ"},
+		{"a-don", "adon"},
+	}
+	for _, tt := range tests {
+		got, err := ConvertXSAMPAToIPA(tt.xsampa)
+		if err != nil {
+			t.Fatalf("ConvertXSAMPAToIPA(%q) error: %v", tt.xsampa, err)
+		}
+		if got != tt.want {
+			t.Errorf("ConvertXSAMPAToIPA(%q) = %q, want %q", tt.xsampa, got, tt.want)
+		}
+	}
+}
+
+func TestIPAXSAMPA_RoundTrip(t *testing.T) {
+	// Every symbol written in X-SAMPA survives a trip through IPA
+	written := map[string]bool{}
+	for _, sym := range xsampaSymbols {
+		if ipaToXSAMPA[norm.NFD.String(sym.ipa)] != sym.xsampa {
+			continue
+		}
+		written[sym.xsampa] = true
+		// Diacritics need a symbol to modify
+		xsampa := sym.xsampa
+		if strings.HasPrefix(xsampa, "_") || strings.ContainsAny(xsampa[:1], "=~`'") {
+			xsampa = "a" + xsampa
+		}
+
+		ipa, err := ConvertXSAMPAToIPA(xsampa)
+		if err != nil {
+			t.Errorf("ConvertXSAMPAToIPA(%q) error: %v", xsampa, err)
+			continue
+		}
+		back, err := ConvertIPAToXSAMPA(ipa)
+		if err != nil || back != xsampa {
+			t.Errorf("%q -> %q -> %q (error %v), want it back", xsampa, ipa, back, err)
+		}
+	}
+	if len(written) < 150 {
+		t.Errorf("only %d symbols round trip", len(written))
+	}
+
+	// And so do whole words in either direction
+	for _, ipa := range []string{"ʔaˈdon ʔoˈlam", "t͡sur ħevˈli", "ˈbʁiːtʃə", "ɲ̥ɔ̃ː.ʎi"} {
+		xsampa, err := ConvertIPAToXSAMPA(ipa)
+		if err != nil {
+			t.Fatalf("ConvertIPAToXSAMPA(%q) error: %v", ipa, err)
+		}
+		back, err := ConvertXSAMPAToIPA(xsampa)
+		if err != nil || norm.NFD.String(back) != norm.NFD.String(strings.ReplaceAll(ipa, "tʃ", "t͡ʃ")) {
+			t.Errorf("%q -> %q -> %q (error %v), want it back", ipa, xsampa, back, err)
+		}
+	}
+}
+
+func TestConvert_UnknownSymbols(t *testing.T) {
+	if _, err := ConvertIPAToXSAMPA("ʔa l@m"); err == nil || !strings.Contains(err.Error(), `'@' in "l@m"`) {
+		t.Errorf("ConvertIPAToXSAMPA() error = %v, want the unknown @ reported with its word", err)
+	}
+	if _, err := ConvertXSAMPAToIPA("a_ don"); err == nil || !strings.Contains(err.Error(), "X-SAMPA") {
+		t.Errorf("ConvertXSAMPAToIPA() error = %v, want a lone _ reported", err)
+	}
+	if _, err := ConvertXSAMPAToIPA("a#b"); err == nil {
+		t.Error("ConvertXSAMPAToIPA() accepted #")
+	}
+}
+
+func TestDetectAlphabet(t *testing.T) {
+	tests := []struct {
+		text string
+		want Alphabet
+	}{
+		{"a don o l@m", AlphabetXSAMPA},
+		{"a don o ləm", AlphabetIPA},
+		{"", AlphabetXSAMPA},
+	}
+	for _, tt := range tests {
+		if got := DetectAlphabet(tt.text); got != tt.want {
+			t.Errorf("DetectAlphabet(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestIPAToXSAMPA_Alphabets(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []string
+		wantErr bool
+	}{
+		{"X-SAMPA", "a don o l@m", []string{"a", "don", "o", "l@m"}, false},
+		{"IPA", "ʔa.ˈdon ʔo.ˈlam ʔa.ˈʃer", []string{"?a", "don", "?o", "lam", "?a", "Ser"}, false},
+		{"X-SAMPA syllable breaks and stress", `a."don`, []string{"a", "don"}, false},
+		{"X-SAMPA separators", "a-dOn", []string{"a", "dOn"}, false},
+		{"Centralized vowel keeps its quote", `e_"`, []string{`e_"`}, false},
+		{"Unknown IPA", "ʔa ð̼@", nil, true},
+		{"Unknown X-SAMPA", "a #don", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IPAToXSAMPA(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IPAToXSAMPA(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("IPAToXSAMPA(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsVowelSymbol(t *testing.T) {
+	for _, r := range "aeiouy@{}1236789&AEIOUVYQMəɛɔæɑʊɪʌøœ" {
+		if !IsVowelSymbol(r) {
+			t.Errorf("IsVowelSymbol(%q) = false, want true", r)
+		}
+	}
+	for _, r := range "bdSXZ45?ʃχ:\"" {
+		if IsVowelSymbol(r) {
+			t.Errorf("IsVowelSymbol(%q) = true, want false", r)
+		}
+	}
+}
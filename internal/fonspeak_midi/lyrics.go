@@ -0,0 +1,159 @@
+package fonspeak_midi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Lyric is a lyric meta event (FF 05) of a score track, or a text event
+// (FF 01) in tracks without any, as karaoke files use
+type Lyric struct {
+	Tick     int64    // Absolute tick of the event
+	Position Position // Bar and beat of the event
+	Text     string   // Syllable as written in the file, e.g. "A-" or "don"
+}
+
+// StartsSyllable reports whether the lyric starts a new syllable rather than
+// continuing the previous one over another note. Melisma markers such as
+// "_", "-", "~" and "+", and empty lyrics, continue the previous syllable.
+func (l Lyric) StartsSyllable() bool {
+	return strings.Trim(lyricText(l.Text), "_-~+") != ""
+}
+
+// lyricText strips the karaoke line and paragraph markers "/" and "\" and
+// surrounding whitespace from a lyric
+func lyricText(text string) string {
+	return strings.TrimSpace(strings.TrimLeft(text, `/\`))
+}
+
+// SelectLyrics returns the lyrics of a track, or of every track for
+// AllTracks, ordered by tick. Karaoke files often keep the words on a track
+// of their own, so a track without lyrics takes those of every track.
+func (s *Score) SelectLyrics(trackNo int) []Lyric {
+	var lyrics []Lyric
+	if track, err := s.Track(trackNo); err == nil {
+		lyrics = append(lyrics, track.Lyrics...)
+	}
+	if len(lyrics) == 0 {
+		for _, track := range s.Tracks {
+			lyrics = append(lyrics, track.Lyrics...)
+		}
+		sort.SliceStable(lyrics, func(i, j int) bool { return lyrics[i].Tick < lyrics[j].Tick })
+	}
+	return lyrics
+}
+
+// attachLyrics sets the Lyric of melody notes from lyrics ordered by tick.
+// Each lyric goes to the first pitched note without one that starts at or
+// after it, allowing a 32nd note of slack for lyrics placed just after the
+// onset they belong to.
+func (s *Score) attachLyrics(melody []Note, lyrics []Lyric) {
+	slack := int64(s.TicksPerQuarter / 8)
+	i := 0
+	for _, lyric := range lyrics {
+		for i < len(melody) && (melody[i].IsRest() || melody[i].Tick+slack < lyric.Tick) {
+			i++
+		}
+		if i == len(melody) {
+			return
+		}
+		melody[i].Lyric = lyricText(lyric.Text)
+		i++
+	}
+}
+
+// HasLyrics reports whether any pitched note carries a lyric
+func HasLyrics(notes []Note) bool {
+	return CountLyricSyllables(notes) > 0
+}
+
+// CountLyricSyllables returns the number of pitched notes whose lyric starts
+// a new syllable
+func CountLyricSyllables(notes []Note) int {
+	count := 0
+	for _, note := range notes {
+		if !note.IsRest() && note.StartsSyllable() {
+			count++
+		}
+	}
+	return count
+}
+
+// StartsSyllable reports whether the note's lyric starts a new syllable
+func (n Note) StartsSyllable() bool {
+	return Lyric{Text: n.Lyric}.StartsSyllable()
+}
+
+// AlignmentMode chooses how syllables are assigned to notes
+type AlignmentMode string
+
+const (
+	// AlignEven spreads syllables evenly over the notes
+	AlignEven AlignmentMode = "even"
+	// AlignLyrics follows the lyric events embedded in the MIDI file, falling
+	// back to AlignEven if the melody has none
+	AlignLyrics AlignmentMode = "lyrics"
+)
+
+// ParseAlignmentMode converts a mode name into an AlignmentMode
+// An empty name selects the default even mode
+func ParseAlignmentMode(name string) (AlignmentMode, error) {
+	switch AlignmentMode(name) {
+	case AlignEven, "":
+		return AlignEven, nil
+	case AlignLyrics:
+		return AlignLyrics, nil
+	default:
+		return "", fmt.Errorf("invalid alignment mode: %s (must be 'even' or 'lyrics')", name)
+	}
+}
+
+// AlignSyllablesToLyrics aligns syllables to the pitched notes of melody by
+// its embedded lyrics: each note whose lyric starts a syllable takes the next
+// syllable and the notes up to the next one extend its vowel (melisma). Notes
+// before the first lyric belong to the first syllable. If the melody has no
+// lyrics it falls back to AlignSyllablesToMelody. Like it, the result has a
+// syllable per pitched note and stops early when the syllables run out.
+func AlignSyllablesToLyrics(syllables []string, melody []Note) []string {
+	pitched := []Note{}
+	for _, note := range melody {
+		if !note.IsRest() {
+			pitched = append(pitched, note)
+		}
+	}
+	if !HasLyrics(pitched) {
+		return AlignSyllablesToMelody(syllables, len(pitched))
+	}
+
+	// The first syllable also covers any notes before the first lyric
+	first := 0
+	for !pitched[first].StartsSyllable() {
+		first++
+	}
+
+	result := []string{}
+	for start := 0; start < len(pitched) && len(syllables) > 0; {
+		end := max(start, first) + 1
+		for end < len(pitched) && !pitched[end].StartsSyllable() {
+			end++
+		}
+		result = append(result, extendSyllableVowel(syllables[0], end-start)...)
+		syllables = syllables[1:]
+		start = end
+	}
+	return result
+}
+
+// RepeatMelodyToCoverLyrics repeats melody like RepeatMelodyToCoverSyllables
+// until its lyrics start at least syllableCount syllables, repeating whole
+// passes so every lyric keeps its notes
+func RepeatMelodyToCoverLyrics(melody []Note, syllableCount int) []Note {
+	perPass := CountLyricSyllables(melody)
+	if perPass == 0 || syllableCount <= perPass {
+		return melody
+	}
+	passes := int(math.Ceil(float64(syllableCount) / float64(perPass)))
+	return RepeatMelodyToCoverSyllables(melody, passes*CountPitchedNotes(melody))
+}
@@ -0,0 +1,209 @@
+package fonspeak_midi
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// lyricScore returns a score of five quarter notes with lyric events, the
+// fourth held over from the third as a melisma
+func lyricScore(t *testing.T) *Score {
+	t.Helper()
+
+	var track smf.Track
+	for i, lyric := range []string{"A-", "don", "o-", "_", "lam"} {
+		track.Add(0, smf.MetaLyric(lyric))
+		track.Add(0, midi.NoteOn(0, uint8(60+i), 100))
+		track.Add(960, midi.NoteOff(0, uint8(60+i)))
+	}
+	track.Close(0)
+	return parseTracks(t, track)
+}
+
+func parseTracks(t *testing.T, tracks ...smf.Track) *Score {
+	t.Helper()
+
+	s := smf.New()
+	for _, track := range tracks {
+		if err := s.Add(track); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	score, err := ParseScore(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("ParseScore() error: %v", err)
+	}
+	return score
+}
+
+func TestScore_MelodyLyrics(t *testing.T) {
+	score := lyricScore(t)
+	if got := score.Summaries()[0].Lyrics; got != 5 {
+		t.Errorf("summary lyrics = %d, want 5", got)
+	}
+
+	melody, err := score.Melody(0)
+	if err != nil {
+		t.Fatalf("Melody() error: %v", err)
+	}
+	var lyrics []string
+	for _, note := range melody {
+		lyrics = append(lyrics, note.Lyric)
+	}
+	if want := []string{"A-", "don", "o-", "_", "lam"}; !reflect.DeepEqual(lyrics, want) {
+		t.Errorf("lyrics = %q, want %q", lyrics, want)
+	}
+	if got := CountLyricSyllables(melody); got != 4 {
+		t.Errorf("CountLyricSyllables() = %d, want 4", got)
+	}
+}
+
+func TestScore_KaraokeTextLyrics(t *testing.T) {
+	// Karaoke files keep the words as text events on a track of their own
+	var melody, words smf.Track
+	for i := range 3 {
+		melody.Add(0, midi.NoteOn(0, uint8(60+i), 100))
+		melody.Add(960, midi.NoteOff(0, uint8(60+i)))
+	}
+	melody.Close(0)
+	words.Add(0, smf.MetaText("@TAdon Olam"))
+	words.Add(0, smf.MetaText(`\A`))
+	words.Add(970, smf.MetaText("don")) // just after the onset
+	words.Add(950, smf.MetaText("/o"))
+	words.Close(0)
+
+	score := parseTracks(t, melody, words)
+	notes, err := score.Melody(0)
+	if err != nil {
+		t.Fatalf("Melody() error: %v", err)
+	}
+	var lyrics []string
+	for _, note := range notes {
+		lyrics = append(lyrics, note.Lyric)
+	}
+	if want := []string{"A", "don", "o"}; !reflect.DeepEqual(lyrics, want) {
+		t.Errorf("lyrics = %q, want %q", lyrics, want)
+	}
+}
+
+func TestAlignSyllablesToLyrics(t *testing.T) {
+	notes := func(lyrics ...string) []Note {
+		var melody []Note
+		for _, lyric := range lyrics {
+			if lyric == "rest" {
+				melody = append(melody, Note{Kind: Rest})
+				continue
+			}
+			melody = append(melody, Note{Kind: Pitched, Lyric: lyric})
+		}
+		return melody
+	}
+
+	tests := []struct {
+		name      string
+		syllables []string
+		melody    []Note
+		want      []string
+	}{
+		{
+			name:      "One note per lyric",
+			syllables: []string{"a", "don"},
+			melody:    notes("A-", "don"),
+			want:      []string{"a", "don"},
+		},
+		{
+			name:      "Melisma marker extends the vowel",
+			syllables: []string{"a", "don", "o", "lam"},
+			melody:    notes("A-", "don", "o-", "_", "lam"),
+			want:      []string{"a", "don", "o", "o", "lam"},
+		},
+		{
+			name:      "Notes without lyrics extend the vowel",
+			syllables: []string{"a", "don"},
+			melody:    notes("A-", "don", "", ""),
+			want:      []string{"a", "do", "o", "on"},
+		},
+		{
+			name:      "Notes before the first lyric join the first syllable",
+			syllables: []string{"don", "lam"},
+			melody:    notes("", "don", "lam"),
+			want:      []string{"do", "on", "lam"},
+		},
+		{
+			name:      "Rests are skipped",
+			syllables: []string{"a", "don"},
+			melody:    notes("A-", "rest", "don"),
+			want:      []string{"a", "don"},
+		},
+		{
+			name:      "Fewer syllables than lyrics",
+			syllables: []string{"a"},
+			melody:    notes("A-", "don"),
+			want:      []string{"a"},
+		},
+		{
+			name:      "No lyrics falls back to even distribution",
+			syllables: []string{"a", "don"},
+			melody:    notes("", "", "", ""),
+			want:      AlignSyllablesToMelody([]string{"a", "don"}, 4),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AlignSyllablesToLyrics(tt.syllables, tt.melody)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AlignSyllablesToLyrics() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepeatMelodyToCoverLyrics(t *testing.T) {
+	melody := []Note{
+		{Kind: Pitched, Start: 0, Duration: 1, Lyric: "a"},
+		{Kind: Pitched, Start: 1, Duration: 1, Lyric: "_"},
+		{Kind: Pitched, Start: 2, Duration: 1, Lyric: "don"},
+	}
+
+	if got := RepeatMelodyToCoverLyrics(melody, 2); len(got) != 3 {
+		t.Errorf("2 syllables: got %d notes, want the melody unchanged", len(got))
+	}
+
+	got := RepeatMelodyToCoverLyrics(melody, 3)
+	if len(got) != 6 || CountLyricSyllables(got) != 4 {
+		t.Fatalf("3 syllables: got %d notes with %d lyric syllables, want two whole passes", len(got), CountLyricSyllables(got))
+	}
+	if got[3].Start != 3 || got[3].Lyric != "a" {
+		t.Errorf("second pass starts with %+v, want lyric a at 3s", got[3])
+	}
+}
+
+func TestParseAlignmentMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    AlignmentMode
+		wantErr bool
+	}{
+		{"", AlignEven, false},
+		{"even", AlignEven, false},
+		{"lyrics", AlignLyrics, false},
+		{"karaoke", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAlignmentMode(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAlignmentMode(%q) = %q, %v, want %q (error %v)", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -1,139 +1,174 @@
 package fonspeak_midi
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"sort"
-
-	"gitlab.com/gomidi/midi/v2"
-	"gitlab.com/gomidi/midi/v2/smf"
 )
 
 // ExtractMonophonicMelody reads a MIDI file and extracts a monophonic melody
 // from the specified track. If multiple notes occur simultaneously (chord),
-// it selects the lowest pitch.
+// it selects the lowest pitch; Score.ReduceMelody offers other policies.
+// Gaps between notes, including any silence before the first note, are
+// returned as Rest notes so the melody keeps the timeline of the original file.
 func ExtractMonophonicMelody(reader io.Reader, trackNo int) ([]Note, error) {
-	var events []smf.TrackEvent
-
-	// Read all track events from the MIDI file
-	smf.ReadTracksFrom(reader).Do(func(te smf.TrackEvent) {
-		events = append(events, te)
-	})
+	return ExtractMonophonicMelodyContext(context.Background(), reader, trackNo)
+}
 
-	if len(events) == 0 {
-		return nil, fmt.Errorf("no MIDI events found")
+// ExtractChannelMelody is ExtractMonophonicMelody restricted to notes on a
+// 1-based MIDI channel, or on every channel for AllChannels. trackNo may be
+// AllTracks to take the channel from every track, as in format 0 files
+// where all instruments share one track.
+func ExtractChannelMelody(reader io.Reader, trackNo, channel int) ([]Note, error) {
+	score, err := ParseScore(context.Background(), reader)
+	if err != nil {
+		return nil, err
 	}
+	return score.ChannelMelody(trackNo, channel, DefaultChordReduction())
+}
 
-	// Group events by time to detect simultaneous notes (chords)
-	type noteEvent struct {
-		time     uint32
-		key      uint8
-		duration uint32
+// ExtractMelodyWithWarnings is ExtractChannelMelody that also returns the
+// warnings ParseScore raised for the selected track and channel
+func ExtractMelodyWithWarnings(reader io.Reader, trackNo, channel int) ([]Note, []Warning, error) {
+	score, err := ParseScore(context.Background(), reader)
+	if err != nil {
+		return nil, nil, err
 	}
+	notes, err := score.ChannelMelody(trackNo, channel, DefaultChordReduction())
+	if err != nil {
+		return nil, nil, err
+	}
+	return notes, score.SelectWarnings(trackNo, channel), nil
+}
 
-	noteEvents := []noteEvent{}
+// ExtractMonophonicMelodyContext is ExtractMonophonicMelody with cancellation.
+// The context is checked while pairing note events, which dominates the cost
+// of large files.
+func ExtractMonophonicMelodyContext(ctx context.Context, reader io.Reader, trackNo int) ([]Note, error) {
+	score, err := ParseScore(ctx, reader)
+	if err != nil {
+		return nil, err
+	}
+	return score.Melody(trackNo)
+}
 
-	// Build a map of note-on to note-off for duration calculation
-	for i, te := range events {
-		if te.TrackNo == trackNo && te.Message.Is(midi.NoteOnMsg) {
-			var channel, key, velocity uint8
-			te.Message.GetNoteOn(&channel, &key, &velocity)
+// Melody extracts a monophonic melody from the specified track as described
+// for ExtractMonophonicMelody. Times follow the score's tempo map.
+func (s *Score) Melody(trackNo int) ([]Note, error) {
+	return s.ReduceMelody(trackNo, DefaultChordReduction())
+}
 
-			// Skip if velocity is 0 (some MIDI files use note-on with velocity 0 as note-off)
-			if velocity == 0 {
-				continue
-			}
+// ReduceMelody extracts a monophonic melody from the specified track like
+// Melody, reducing chords as configured by reduction. Each chord takes the
+// longest duration among its notes.
+func (s *Score) ReduceMelody(trackNo int, reduction ChordReduction) ([]Note, error) {
+	return s.ChannelMelody(trackNo, AllChannels, reduction)
+}
 
-			noteOnTime := te.AbsMicroSeconds
-
-			// Search for corresponding NoteOff
-			for j := i + 1; j < len(events); j++ {
-				te2 := events[j]
-				if te2.TrackNo == trackNo && te2.AbsMicroSeconds > noteOnTime {
-					var matched bool
-
-					// Check for NoteOff message
-					if te2.Message.Is(midi.NoteOffMsg) {
-						var channel2, keyOff, velocity2 uint8
-						te2.Message.GetNoteOff(&channel2, &keyOff, &velocity2)
-						if keyOff == key {
-							matched = true
-						}
-					}
-
-					// Also check for NoteOn with velocity 0 (alternative note-off)
-					if te2.Message.Is(midi.NoteOnMsg) {
-						var channel2, keyOn, velocity2 uint8
-						te2.Message.GetNoteOn(&channel2, &keyOn, &velocity2)
-						if keyOn == key && velocity2 == 0 {
-							matched = true
-						}
-					}
-
-					if matched {
-						duration := te2.AbsMicroSeconds - noteOnTime // in microseconds
-						noteEvents = append(noteEvents, noteEvent{
-							time:     uint32(noteOnTime / 1000), // convert to milliseconds
-							key:      key,
-							duration: uint32(duration / 1000), // convert to milliseconds
-						})
-						break
-					}
-				}
-			}
-		}
+// ChannelMelody extracts a monophonic melody like ReduceMelody from the
+// notes Select picks by track and channel. A note still sounding when the
+// next one starts, as in legato playing or a retriggered key, is cut short
+// so only one note sounds at a time. Notes carry the lyrics SelectLyrics
+// finds for the track.
+func (s *Score) ChannelMelody(trackNo, channel int, reduction ChordReduction) ([]Note, error) {
+	if err := reduction.Validate(); err != nil {
+		return nil, err
 	}
+	reduction = reduction.withDefaults()
+	threshold := reduction.Threshold
 
-	if len(noteEvents) == 0 {
-		return nil, fmt.Errorf("no notes found in track %d", trackNo)
+	selected, err := s.Select(trackNo, channel)
+	if err != nil {
+		return nil, err
 	}
-
-	// Sort by time
-	sort.Slice(noteEvents, func(i, j int) bool {
-		return noteEvents[i].time < noteEvents[j].time
-	})
-
-	// Collapse simultaneous notes (chords) to lowest pitch
-	// Group notes that start within a small time window as simultaneous
-	// 10ms threshold chosen to account for MIDI timing jitter while still detecting true chords
-	const simultaneousThreshold = 10 // milliseconds
+	if len(selected.Notes) == 0 {
+		return nil, noNotesError(trackNo, channel)
+	}
+	notes := selected.Notes
 
 	result := []Note{}
+	var prevEnd float64   // end of the previous chosen note, in seconds
+	var prevEndTick int64 // and in ticks
+	prevKey := -1         // key of the previous chosen note
 	i := 0
-	for i < len(noteEvents) {
-		currentTime := noteEvents[i].time
+	for i < len(notes) {
+		current := notes[i]
+
+		// Anything longer than the chord threshold between the previous note
+		// ending and this one starting is a rest
+		if current.Start-prevEnd > threshold {
+			result = append(result, Note{
+				Start:    prevEnd,
+				Duration: current.Start - prevEnd,
+				Kind:     Rest,
+				Tick:     prevEndTick,
+				Position: s.PositionAt(prevEndTick),
+			})
+		}
 
 		// Collect all notes that start at approximately the same time
-		simultaneousNotes := []noteEvent{noteEvents[i]}
+		simultaneousNotes := []ScoreNote{current}
 		j := i + 1
-		for j < len(noteEvents) && noteEvents[j].time-currentTime <= simultaneousThreshold {
-			simultaneousNotes = append(simultaneousNotes, noteEvents[j])
+		for j < len(notes) && notes[j].Start-current.Start <= threshold {
+			simultaneousNotes = append(simultaneousNotes, notes[j])
 			j++
 		}
 
-		// Select the lowest pitch
-		lowestNote := simultaneousNotes[0]
+		// Select a pitch by policy and the longest duration among simultaneous notes
+		chosen := reduction.pick(simultaneousNotes, prevKey)
+		longest := simultaneousNotes[0]
 		for _, note := range simultaneousNotes[1:] {
-			if note.key < lowestNote.key {
-				lowestNote = note
-			}
-		}
-
-		// Use the longest duration among simultaneous notes
-		maxDuration := lowestNote.duration
-		for _, note := range simultaneousNotes {
-			if note.duration > maxDuration {
-				maxDuration = note.duration
+			if note.Duration > longest.Duration {
+				longest = note
 			}
 		}
+		prevKey = chosen.Key
 
 		result = append(result, Note{
-			MIDINote: int(lowestNote.key),
-			Duration: float64(maxDuration) / 1000.0, // convert to seconds
+			MIDINote:   chosen.Key,
+			Start:      current.Start,
+			Duration:   longest.Duration,
+			Kind:       Pitched,
+			Tick:       current.StartTick,
+			Position:   current.Position,
+			Velocity:   chosen.Velocity,
+			Volume:     chosen.Volume,
+			Expression: chosen.Expression,
 		})
 
+		if end := current.Start + longest.Duration; end > prevEnd {
+			prevEnd = end
+			prevEndTick = current.StartTick + (longest.EndTick - longest.StartTick)
+		}
+
 		i = j
 	}
 
+	truncateOverlaps(result)
+	s.attachLyrics(result, s.SelectLyrics(trackNo))
 	return result, nil
 }
+
+// noNotesError names the track and channel a melody was looked for on
+func noNotesError(trackNo, channel int) error {
+	switch {
+	case trackNo == AllTracks && channel == AllChannels:
+		return fmt.Errorf("no notes found in any track")
+	case channel == AllChannels:
+		return fmt.Errorf("no notes found in track %d", trackNo)
+	case trackNo == AllTracks:
+		return fmt.Errorf("no notes found on channel %d of any track", channel)
+	default:
+		return fmt.Errorf("no notes found on channel %d of track %d", channel, trackNo)
+	}
+}
+
+// truncateOverlaps ends each note where the next one starts if it would
+// otherwise sound over it. Rests only fill gaps, so they never overlap.
+func truncateOverlaps(melody []Note) {
+	for i := 0; i+1 < len(melody); i++ {
+		if end := melody[i].Start + melody[i].Duration; end > melody[i+1].Start {
+			melody[i].Duration = melody[i+1].Start - melody[i].Start
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package fonspeak_midi
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// testNote describes a note for building test MIDI files, timed in ticks
+// at 960 ticks per quarter note and the default 120 BPM (1 tick = 0.52 ms)
+type testNote struct {
+	start uint32
+	dur   uint32
+	key   uint8
+}
+
+// buildTestMIDI writes a single-track SMF containing the given notes
+func buildTestMIDI(t *testing.T, notes []testNote) *bytes.Reader {
+	t.Helper()
+
+	type event struct {
+		tick uint32
+		msg  midi.Message
+	}
+	var events []event
+	for _, n := range notes {
+		events = append(events,
+			event{n.start, midi.NoteOn(0, n.key, 100)},
+			event{n.start + n.dur, midi.NoteOff(0, n.key)},
+		)
+	}
+	// Stable insertion sort keeps note-offs before note-ons at equal ticks
+	for i := 1; i < len(events); i++ {
+		for j := i; j > 0 && events[j].tick < events[j-1].tick; j-- {
+			events[j], events[j-1] = events[j-1], events[j]
+		}
+	}
+
+	var track smf.Track
+	var last uint32
+	for _, e := range events {
+		track.Add(e.tick-last, e.msg)
+		last = e.tick
+	}
+	track.Close(0)
+
+	s := smf.New()
+	if err := s.Add(track); err != nil {
+		t.Fatalf("failed to add track: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatalf("failed to write SMF: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestExtractMonophonicMelody_Rests(t *testing.T) {
+	// Quarter notes at 120 BPM are 0.5s; leave a quarter rest before the
+	// first note and between the second and third
+	r := buildTestMIDI(t, []testNote{
+		{start: 960, dur: 960, key: 60},
+		{start: 1920, dur: 960, key: 62},
+		{start: 3840, dur: 960, key: 64},
+	})
+
+	notes, err := ExtractMonophonicMelody(r, 0)
+	if err != nil {
+		t.Fatalf("ExtractMonophonicMelody() error: %v", err)
+	}
+
+	want := []Note{
+		{Start: 0, Duration: 0.5, Kind: Rest},
+		{MIDINote: 60, Start: 0.5, Duration: 0.5, Kind: Pitched},
+		{MIDINote: 62, Start: 1.0, Duration: 0.5, Kind: Pitched},
+		{Start: 1.5, Duration: 0.5, Kind: Rest},
+		{MIDINote: 64, Start: 2.0, Duration: 0.5, Kind: Pitched},
+	}
+
+	if len(notes) != len(want) {
+		t.Fatalf("got %d notes, want %d: %+v", len(notes), len(want), notes)
+	}
+	for i, w := range want {
+		got := notes[i]
+		if got.Kind != w.Kind || got.MIDINote != w.MIDINote ||
+			math.Abs(got.Start-w.Start) > 0.002 || math.Abs(got.Duration-w.Duration) > 0.002 {
+			t.Errorf("note %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestExtractMonophonicMelody_ChordKeepsLowest(t *testing.T) {
+	r := buildTestMIDI(t, []testNote{
+		{start: 0, dur: 960, key: 67},
+		{start: 0, dur: 960, key: 60},
+		{start: 0, dur: 960, key: 64},
+	})
+
+	notes, err := ExtractMonophonicMelody(r, 0)
+	if err != nil {
+		t.Fatalf("ExtractMonophonicMelody() error: %v", err)
+	}
+
+	if len(notes) != 1 || notes[0].MIDINote != 60 {
+		t.Errorf("got %+v, want a single note with key 60", notes)
+	}
+}
+
+func TestExtractMonophonicMelody_TruncatesOverlaps(t *testing.T) {
+	tests := []struct {
+		name  string
+		notes []testNote
+		want  []Note
+	}{
+		{
+			name: "legato",
+			notes: []testNote{
+				{start: 0, dur: 1200, key: 60},
+				{start: 960, dur: 960, key: 62},
+			},
+			want: []Note{
+				{MIDINote: 60, Start: 0, Duration: 0.5, Kind: Pitched},
+				{MIDINote: 62, Start: 0.5, Duration: 0.5, Kind: Pitched},
+			},
+		},
+		{
+			name: "retriggered key",
+			notes: []testNote{
+				{start: 0, dur: 960, key: 60},
+				{start: 480, dur: 960, key: 60},
+			},
+			want: []Note{
+				{MIDINote: 60, Start: 0, Duration: 0.25, Kind: Pitched},
+				{MIDINote: 60, Start: 0.25, Duration: 0.5, Kind: Pitched},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notes, err := ExtractMonophonicMelody(buildTestMIDI(t, tt.notes), 0)
+			if err != nil {
+				t.Fatalf("ExtractMonophonicMelody() error: %v", err)
+			}
+			if len(notes) != len(tt.want) {
+				t.Fatalf("got %d notes, want %d: %+v", len(notes), len(tt.want), notes)
+			}
+			for i, w := range tt.want {
+				got := notes[i]
+				if got.MIDINote != w.MIDINote || got.Kind != w.Kind ||
+					math.Abs(got.Start-w.Start) > 0.002 || math.Abs(got.Duration-w.Duration) > 0.002 {
+					t.Errorf("note %d = %+v, want %+v", i, got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractMelodyWithWarnings(t *testing.T) {
+	// A lone note-off before a normal note
+	var track smf.Track
+	track.Add(0, midi.NoteOff(0, 67))
+	track.Add(0, midi.NoteOn(0, 60, 100))
+	track.Add(960, midi.NoteOff(0, 60))
+	track.Close(0)
+
+	s := smf.New()
+	if err := s.Add(track); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	notes, warnings, err := ExtractMelodyWithWarnings(&buf, 0, AllChannels)
+	if err != nil {
+		t.Fatalf("ExtractMelodyWithWarnings() error: %v", err)
+	}
+	if len(notes) != 1 || len(warnings) != 1 || warnings[0].Kind != OrphanNoteOff {
+		t.Errorf("got %d notes and warnings %v, want 1 note and an orphan note-off", len(notes), warnings)
+	}
+}
@@ -0,0 +1,406 @@
+package fonspeak_midi
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// musicXMLTicksPerQuarter is the resolution MusicXML durations are converted
+// to, fine enough for any divisions value in practice
+const musicXMLTicksPerQuarter = 960
+
+// maxMXLEntryBytes caps how far a file in a compressed MusicXML archive is
+// decompressed, so a small upload cannot expand to fill memory
+const maxMXLEntryBytes = 32 << 20
+
+// musicXMLForte is the velocity MusicXML's dynamics percentages are relative to
+const musicXMLForte = 90
+
+// ReadScore reads a Standard MIDI File, MusicXML or compressed MusicXML
+// (.mxl) score or ABC tune, telling them apart by content
+func ReadScore(ctx context.Context, reader io.Reader) (*Score, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read score: %w", err)
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return parseMXL(ctx, data)
+	case bytes.HasPrefix(bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), []byte("<")):
+		return ParseMusicXML(ctx, bytes.NewReader(data))
+	case isABC(data):
+		return ParseABC(ctx, bytes.NewReader(data))
+	default:
+		return ParseScore(ctx, bytes.NewReader(data))
+	}
+}
+
+// ParseMXL reads a compressed MusicXML (.mxl) archive into a Score like
+// ParseMusicXML
+func ParseMXL(ctx context.Context, reader io.Reader) (*Score, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MusicXML archive: %w", err)
+	}
+	return parseMXL(ctx, data)
+}
+
+func parseMXL(ctx context.Context, data []byte) (*Score, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MusicXML archive: %w", err)
+	}
+
+	// The container names the score, which is otherwise the first XML file
+	// outside META-INF
+	name := ""
+	if container, err := readMXLEntry(archive, "META-INF/container.xml"); err == nil {
+		var manifest struct {
+			Rootfiles []struct {
+				FullPath string `xml:"full-path,attr"`
+			} `xml:"rootfiles>rootfile"`
+		}
+		err := xml.Unmarshal(container, &manifest)
+		if err == nil && len(manifest.Rootfiles) > 0 {
+			name = manifest.Rootfiles[0].FullPath
+		}
+	}
+	if name == "" {
+		for _, file := range archive.File {
+			ext := path.Ext(file.Name)
+			if !strings.HasPrefix(file.Name, "META-INF/") && (ext == ".xml" || ext == ".musicxml") {
+				name = file.Name
+				break
+			}
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no score found in MusicXML archive")
+	}
+
+	file, err := readMXLEntry(archive, name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMusicXML(ctx, bytes.NewReader(file))
+}
+
+// readMXLEntry decompresses the named file of a MusicXML archive, refusing
+// files larger than maxMXLEntryBytes
+func readMXLEntry(archive *zip.Reader, name string) ([]byte, error) {
+	for _, f := range archive.File {
+		if f.Name != name {
+			continue
+		}
+		tooLarge := fmt.Errorf("%s in MusicXML archive is larger than %d MB", name, maxMXLEntryBytes>>20)
+		if f.UncompressedSize64 > maxMXLEntryBytes {
+			return nil, tooLarge
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s in MusicXML archive: %w", name, err)
+		}
+		defer rc.Close()
+		// The size in the archive's header may lie, so limit the read too
+		data, err := io.ReadAll(io.LimitReader(rc, maxMXLEntryBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s in MusicXML archive: %w", name, err)
+		}
+		if len(data) > maxMXLEntryBytes {
+			return nil, tooLarge
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("failed to open %s in MusicXML archive: file not found", name)
+}
+
+// mxlScore is a partwise MusicXML document, the layout every notation
+// program exports
+type mxlScore struct {
+	XMLName  xml.Name
+	PartList []mxlScorePart `xml:"part-list>score-part"`
+	Parts    []mxlPart      `xml:"part"`
+}
+
+type mxlScorePart struct {
+	ID          string `xml:"id,attr"`
+	Name        string `xml:"part-name"`
+	Instruments []struct {
+		Name string `xml:"instrument-name"`
+	} `xml:"score-instrument"`
+	MIDIInstruments []struct {
+		Channel int `xml:"midi-channel"` // 1-based
+		Program int `xml:"midi-program"` // 1-based
+	} `xml:"midi-instrument"`
+}
+
+type mxlPart struct {
+	ID       string       `xml:"id,attr"`
+	Measures []mxlMeasure `xml:"measure"`
+}
+
+type mxlMeasure struct {
+	Elements []mxlElement `xml:",any"`
+}
+
+// mxlElement is any child of a measure, kept in order. Only the fields of
+// its kind are set.
+type mxlElement struct {
+	XMLName xml.Name
+
+	// note, backup and forward
+	Duration int `xml:"duration"`
+
+	// note
+	Chord    *struct{}  `xml:"chord"`
+	Grace    *struct{}  `xml:"grace"`
+	Rest     *struct{}  `xml:"rest"`
+	Pitch    *mxlPitch  `xml:"pitch"`
+	Voice    string     `xml:"voice"`
+	Dynamics float64    `xml:"dynamics,attr"` // Percentage of musicXMLForte
+	Ties     []mxlTie   `xml:"tie"`
+	Tied     []mxlTie   `xml:"notations>tied"`
+	Lyrics   []mxlLyric `xml:"lyric"`
+
+	// attributes
+	Divisions int      `xml:"divisions"`
+	Time      *mxlTime `xml:"time"`
+
+	// direction holds sound elements, which may also stand on their own
+	Sounds []mxlSound `xml:"sound"`
+	Tempo  float64    `xml:"tempo,attr"`
+}
+
+type mxlPitch struct {
+	Step   string  `xml:"step"`
+	Alter  float64 `xml:"alter"`
+	Octave int     `xml:"octave"`
+}
+
+// key returns the MIDI note number of the pitch, rounding microtones
+func (p mxlPitch) key() int {
+	steps := map[string]int{"C": 0, "D": 2, "E": 4, "F": 5, "G": 7, "A": 9, "B": 11}
+	return (p.Octave+1)*12 + steps[strings.ToUpper(p.Step)] + int(math.Round(p.Alter))
+}
+
+type mxlTie struct {
+	Type string `xml:"type,attr"` // start or stop
+}
+
+type mxlLyric struct {
+	Syllabic string   `xml:"syllabic"` // single, begin, middle or end
+	Texts    []string `xml:"text"`     // Several when syllables are elided
+}
+
+// text returns the syllable with a trailing hyphen if the word goes on, as
+// MIDI lyric events are written
+func (l mxlLyric) text() string {
+	text := strings.Join(l.Texts, " ")
+	if l.Syllabic == "begin" || l.Syllabic == "middle" {
+		text += "-"
+	}
+	return text
+}
+
+type mxlTime struct {
+	Beats    string `xml:"beats"` // May be compound, e.g. "3+2"
+	BeatType int    `xml:"beat-type"`
+}
+
+// numerator sums the beats of a compound meter
+func (t mxlTime) numerator() int {
+	total := 0
+	for _, part := range strings.Split(t.Beats, "+") {
+		beats, _ := strconv.Atoi(strings.TrimSpace(part))
+		total += beats
+	}
+	return total
+}
+
+type mxlSound struct {
+	Tempo float64 `xml:"tempo,attr"`
+}
+
+// hasTie reports whether ties include one of the given type
+func hasTie(ties []mxlTie, kind string) bool {
+	for _, tie := range ties {
+		if tie.Type == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseMusicXML reads a partwise MusicXML score into a Score with a track
+// per part. Tied notes become one note, and each note's first lyric is kept
+// as a lyric event with a trailing hyphen inside words; notes under a slur
+// or lyric extension carry no lyric, so they continue the previous
+// syllable. Tempo and time signature changes build the tempo map and
+// meters, while repeats are not expanded.
+func ParseMusicXML(ctx context.Context, reader io.Reader) (*Score, error) {
+	var doc mxlScore
+	if err := xml.NewDecoder(reader).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to read MusicXML score: %w", err)
+	}
+	switch doc.XMLName.Local {
+	case "score-partwise":
+	case "score-timewise":
+		return nil, fmt.Errorf("timewise MusicXML is not supported, export the score as partwise MusicXML")
+	default:
+		return nil, fmt.Errorf("not a MusicXML score: root element is <%s>", doc.XMLName.Local)
+	}
+	if len(doc.Parts) == 0 {
+		return nil, fmt.Errorf("no parts found in MusicXML score")
+	}
+
+	// Notes are collected in ticks first, as their times in seconds depend on
+	// tempo changes in any part
+	type tickNote struct {
+		key, velocity, channel int
+		start, end             int64
+	}
+	type tickLyric struct {
+		tick int64
+		text string
+	}
+	score := &Score{Format: 1, TicksPerQuarter: musicXMLTicksPerQuarter}
+	var events []timelineEvent
+	notes := make([][]tickNote, len(doc.Parts))
+	lyrics := make([][]tickLyric, len(doc.Parts))
+
+	for number, part := range doc.Parts {
+		track := ScoreTrack{Number: number, Program: -1, ChannelPrograms: map[int]int{}}
+		channel := number % 16
+		for _, info := range doc.PartList {
+			if info.ID != part.ID {
+				continue
+			}
+			track.Name = info.Name
+			if len(info.Instruments) > 0 {
+				track.Instrument = info.Instruments[0].Name
+			}
+			if len(info.MIDIInstruments) > 0 {
+				if ch := info.MIDIInstruments[0].Channel; ch >= 1 && ch <= 16 {
+					channel = ch - 1
+				}
+				if program := info.MIDIInstruments[0].Program; program >= 1 && program <= 128 {
+					track.Program = program - 1
+					track.ChannelPrograms[channel] = program - 1
+				}
+			}
+		}
+		score.Tracks = append(score.Tracks, track)
+
+		divisions := 1
+		ticks := func(duration int) int64 {
+			return int64(duration) * musicXMLTicksPerQuarter / int64(divisions)
+		}
+
+		// Notes waiting for the rest of their tie, by voice and key, so a
+		// tie in one voice never extends another voice's note
+		type tieKey struct {
+			voice string
+			key   int
+		}
+		tied := map[tieKey]int{}
+		// Lyrics are taken from the first voice that has any, as the other
+		// voices of a part usually repeat its words to their own rhythm
+		lyricVoice := ""
+
+		var measureStart, cursor, chordStart int64
+		for _, measure := range part.Measures {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			cursor = measureStart
+			measureEnd := measureStart
+			for _, el := range measure.Elements {
+				switch el.XMLName.Local {
+				case "attributes":
+					if el.Divisions > 0 {
+						divisions = el.Divisions
+					}
+					if el.Time != nil {
+						events = append(events, timelineEvent{tick: cursor, numerator: el.Time.numerator(), denominator: el.Time.BeatType})
+					}
+				case "direction":
+					for _, sound := range el.Sounds {
+						events = append(events, timelineEvent{tick: cursor, bpm: sound.Tempo})
+					}
+				case "sound":
+					events = append(events, timelineEvent{tick: cursor, bpm: el.Tempo})
+				case "backup":
+					cursor -= ticks(el.Duration)
+				case "forward":
+					cursor += ticks(el.Duration)
+					measureEnd = max(measureEnd, cursor)
+				case "note":
+					// Grace notes take no time of their own
+					if el.Grace != nil {
+						continue
+					}
+					if el.Chord == nil {
+						chordStart = cursor
+						cursor += ticks(el.Duration)
+					}
+					measureEnd = max(measureEnd, cursor)
+					if el.Rest != nil || el.Pitch == nil {
+						continue
+					}
+
+					key := el.Pitch.key()
+					end := chordStart + ticks(el.Duration)
+					tieStart := hasTie(el.Ties, "start") || hasTie(el.Tied, "start")
+					tieStop := hasTie(el.Ties, "stop") || hasTie(el.Tied, "stop")
+					tie := tieKey{el.Voice, key}
+					if i, ok := tied[tie]; ok && tieStop {
+						notes[number][i].end = end
+						if !tieStart {
+							delete(tied, tie)
+						}
+						continue
+					}
+
+					velocity := musicXMLForte
+					if el.Dynamics > 0 {
+						velocity = min(max(int(math.Round(el.Dynamics*musicXMLForte/100)), 1), 127)
+					}
+					notes[number] = append(notes[number], tickNote{key: key, velocity: velocity, channel: channel, start: chordStart, end: end})
+					if tieStart {
+						tied[tie] = len(notes[number]) - 1
+					}
+					if len(el.Lyrics) > 0 && len(lyrics[number]) == 0 {
+						lyricVoice = el.Voice
+					}
+					// One lyric per chord
+					if n := len(lyrics[number]); len(el.Lyrics) > 0 && el.Voice == lyricVoice && (n == 0 || lyrics[number][n-1].tick != chordStart) {
+						lyrics[number] = append(lyrics[number], tickLyric{tick: chordStart, text: el.Lyrics[0].text()})
+					}
+				}
+			}
+			measureStart = max(measureEnd, cursor)
+		}
+	}
+
+	score.setTimeline(events)
+
+	for number := range score.Tracks {
+		track := &score.Tracks[number]
+		for _, note := range notes[number] {
+			track.Notes = append(track.Notes, score.newNote(note.key, note.velocity, note.channel, note.start, note.end))
+		}
+		sortNotes(track.Notes)
+		for _, lyric := range lyrics[number] {
+			track.Lyrics = append(track.Lyrics, Lyric{Tick: lyric.tick, Position: score.PositionAt(lyric.tick), Text: lyric.text})
+		}
+	}
+	return score, nil
+}
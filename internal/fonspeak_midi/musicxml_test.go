@@ -0,0 +1,237 @@
+package fonspeak_midi
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// hymnMusicXML is a two-part score at 60 BPM in 3/4: a voice singing
+// "A-don" then "o" after a tied melisma, with a wordless second voice, and
+// a piano on MIDI channel 2
+const hymnMusicXML = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<!DOCTYPE score-partwise PUBLIC "-//Recordare//DTD MusicXML 4.0 Partwise//EN" "http://www.musicxml.org/dtds/partwise.dtd">
+<score-partwise version="4.0">
+  <part-list>
+    <score-part id="P1"><part-name>Voice</part-name></score-part>
+    <score-part id="P2">
+      <part-name>Piano</part-name>
+      <score-instrument id="P2-I1"><instrument-name>Grand Piano</instrument-name></score-instrument>
+      <midi-instrument id="P2-I1"><midi-channel>2</midi-channel><midi-program>1</midi-program></midi-instrument>
+    </score-part>
+  </part-list>
+  <part id="P1">
+    <measure number="1">
+      <attributes><divisions>2</divisions><time><beats>3</beats><beat-type>4</beat-type></time></attributes>
+      <direction><sound tempo="60"/></direction>
+      <note><pitch><step>C</step><octave>4</octave></pitch><duration>2</duration><voice>1</voice>
+        <lyric number="1"><syllabic>begin</syllabic><text>A</text></lyric></note>
+      <note><pitch><step>D</step><octave>4</octave></pitch><duration>2</duration><voice>1</voice>
+        <lyric number="1"><syllabic>end</syllabic><text>don</text><extend/></lyric></note>
+      <note><pitch><step>E</step><octave>4</octave></pitch><duration>2</duration><tie type="start"/><voice>1</voice>
+        <notations><tied type="start"/><slur type="start"/></notations></note>
+    </measure>
+    <measure number="2">
+      <note><pitch><step>E</step><octave>4</octave></pitch><duration>2</duration><tie type="stop"/><voice>1</voice>
+        <notations><tied type="stop"/></notations></note>
+      <note><pitch><step>G</step><octave>4</octave></pitch><duration>4</duration><voice>1</voice>
+        <lyric number="1"><syllabic>single</syllabic><text>o</text></lyric></note>
+      <note><chord/><pitch><step>B</step><alter>-1</alter><octave>4</octave></pitch><duration>4</duration><voice>1</voice>
+        <lyric number="1"><syllabic>single</syllabic><text>o</text></lyric></note>
+      <backup><duration>6</duration></backup>
+      <note><pitch><step>C</step><octave>3</octave></pitch><duration>6</duration><voice>2</voice>
+        <lyric number="1"><syllabic>single</syllabic><text>la</text></lyric></note>
+    </measure>
+  </part>
+  <part id="P2">
+    <measure number="1">
+      <attributes><divisions>1</divisions></attributes>
+      <note dynamics="50"><pitch><step>C</step><octave>3</octave></pitch><duration>3</duration></note>
+    </measure>
+  </part>
+</score-partwise>`
+
+func TestParseMusicXML(t *testing.T) {
+	score, err := ParseMusicXML(context.Background(), strings.NewReader(hymnMusicXML))
+	if err != nil {
+		t.Fatalf("ParseMusicXML() error: %v", err)
+	}
+	if len(score.Tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(score.Tracks))
+	}
+	if score.Tempos[0].BPM != 60 || score.Meters[0].Numerator != 3 || score.Meters[0].Denominator != 4 {
+		t.Errorf("tempo %v, meter %v, want 60 BPM in 3/4", score.Tempos[0], score.Meters[0])
+	}
+
+	type note struct {
+		key             int
+		start, duration float64
+	}
+	var got []note
+	for _, n := range score.Tracks[0].Notes {
+		got = append(got, note{n.Key, n.Start, n.Duration})
+	}
+	// The tied E is one note, the second voice's C3 starts the second bar
+	want := []note{{60, 0, 1}, {62, 1, 1}, {64, 2, 2}, {48, 3, 3}, {67, 4, 2}, {70, 4, 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("notes = %v, want %v", got, want)
+	}
+
+	var lyrics []string
+	for _, lyric := range score.Tracks[0].Lyrics {
+		lyrics = append(lyrics, lyric.Text)
+	}
+	if want := []string{"A-", "don", "o"}; !reflect.DeepEqual(lyrics, want) {
+		t.Errorf("lyrics = %q, want %q, one per chord from the first voice", lyrics, want)
+	}
+
+	piano := score.Tracks[1]
+	if piano.Name != "Piano" || piano.Instrument != "Grand Piano" || piano.Program != 0 {
+		t.Errorf("piano track = %+v", piano)
+	}
+	if n := piano.Notes[0]; n.Channel != 1 || n.Velocity != 45 || n.Duration != 3 {
+		t.Errorf("piano note = %+v, want channel 2 (0-based 1), velocity 45, 3s", n)
+	}
+}
+
+func TestParseMusicXML_TiesByVoice(t *testing.T) {
+	// Both voices tie an E4 over the bar line, voice 1 from the downbeat and
+	// voice 2 from the third beat, each ending at a different time
+	const twoVoices = `<score-partwise version="4.0">
+  <part-list><score-part id="P1"><part-name>Organ</part-name></score-part></part-list>
+  <part id="P1">
+    <measure number="1">
+      <attributes><divisions>1</divisions></attributes>
+      <note><pitch><step>E</step><octave>4</octave></pitch><duration>4</duration><tie type="start"/><voice>1</voice></note>
+      <backup><duration>4</duration></backup>
+      <note><pitch><step>C</step><octave>4</octave></pitch><duration>2</duration><voice>2</voice></note>
+      <note><pitch><step>E</step><octave>4</octave></pitch><duration>2</duration><tie type="start"/><voice>2</voice></note>
+    </measure>
+    <measure number="2">
+      <note><pitch><step>E</step><octave>4</octave></pitch><duration>1</duration><tie type="stop"/><voice>1</voice></note>
+      <note><pitch><step>D</step><octave>4</octave></pitch><duration>3</duration><voice>1</voice></note>
+      <backup><duration>4</duration></backup>
+      <note><pitch><step>E</step><octave>4</octave></pitch><duration>3</duration><tie type="stop"/><voice>2</voice></note>
+      <note><pitch><step>C</step><octave>4</octave></pitch><duration>1</duration><voice>2</voice></note>
+    </measure>
+  </part>
+</score-partwise>`
+	score, err := ParseMusicXML(context.Background(), strings.NewReader(twoVoices))
+	if err != nil {
+		t.Fatalf("ParseMusicXML() error: %v", err)
+	}
+
+	type note struct {
+		key             int
+		start, duration float64
+	}
+	var got []note
+	for _, n := range score.Tracks[0].Notes {
+		got = append(got, note{n.Key, n.Start, n.Duration})
+	}
+	sort.Slice(got, func(i, j int) bool {
+		if got[i].start != got[j].start {
+			return got[i].start < got[j].start
+		}
+		return got[i].key < got[j].key
+	})
+	// At the default 120 BPM each tied E lasts five beats
+	want := []note{{60, 0, 1}, {64, 0, 2.5}, {64, 1, 2.5}, {62, 2.5, 1.5}, {60, 3.5, 0.5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("notes = %v, want %v", got, want)
+	}
+}
+
+func TestParseMusicXML_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		xml  string
+	}{
+		{"Not XML", "<score-partwise"},
+		{"Timewise", `<score-timewise version="4.0"></score-timewise>`},
+		{"Other document", `<html></html>`},
+		{"No parts", `<score-partwise version="4.0"></score-partwise>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMusicXML(context.Background(), strings.NewReader(tt.xml)); err == nil {
+				t.Error("ParseMusicXML() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestParseMXL_SizeLimit(t *testing.T) {
+	// A score of whitespace compresses to a few kilobytes but would expand
+	// past the limit
+	var mxl bytes.Buffer
+	archive := zip.NewWriter(&mxl)
+	w, err := archive.Create("bomb.musicxml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(hymnMusicXML))
+	w.Write(bytes.Repeat([]byte(" "), maxMXLEntryBytes))
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if mxl.Len() > 1<<20 {
+		t.Fatalf("archive is %d bytes, want a small one", mxl.Len())
+	}
+
+	_, err = ParseMXL(context.Background(), &mxl)
+	if err == nil || !strings.Contains(err.Error(), "larger than 32 MB") {
+		t.Errorf("ParseMXL() error = %v, want the archive refused for its size", err)
+	}
+}
+
+func TestReadScore(t *testing.T) {
+	var mxl bytes.Buffer
+	archive := zip.NewWriter(&mxl)
+	for name, content := range map[string]string{
+		"META-INF/container.xml": `<container><rootfiles><rootfile full-path="hymn.musicxml"/></rootfiles></container>`,
+		"hymn.musicxml":          hymnMusicXML,
+	} {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var midi bytes.Buffer
+	if _, err := buildScoreMIDI(t, nil, 60, 62).WriteTo(&midi); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantTracks int
+		wantErr    bool
+	}{
+		{"MIDI", midi.Bytes(), 2, false},
+		{"MusicXML", []byte(hymnMusicXML), 2, false},
+		{"MusicXML with BOM", append([]byte("\xef\xbb\xbf\n"), hymnMusicXML...), 2, false},
+		{"Compressed MusicXML", mxl.Bytes(), 2, false},
+		{"Neither", []byte("not a score"), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, err := ReadScore(context.Background(), bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadScore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(score.Tracks) != tt.wantTracks {
+				t.Errorf("got %d tracks, want %d", len(score.Tracks), tt.wantTracks)
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+package fonspeak_midi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// DefaultBPM is the tempo of a MIDI file until its first tempo event
+const DefaultBPM = 120.0
+
+// Channel volume (CC7) and expression (CC11) until a file sets them, as
+// General MIDI devices reset them
+const (
+	DefaultVolume     = 100
+	DefaultExpression = 127
+)
+
+// Control change numbers read from MIDI files
+const (
+	controllerVolume     = 7
+	controllerExpression = 11
+)
+
+// TempoChange is an entry of a score's tempo map
+type TempoChange struct {
+	Tick    int64   // Absolute tick the tempo takes effect at
+	Seconds float64 // Time of Tick in seconds from the start of the file
+	BPM     float64 // Quarter notes per minute
+}
+
+// MeterChange is a time signature taking effect at a bar line
+type MeterChange struct {
+	Tick        int64 // Absolute tick the meter takes effect at
+	Bar         int   // 1-based bar the meter takes effect at
+	Numerator   int   // Beats per bar
+	Denominator int   // Note value of a beat, e.g. 4 for quarter notes
+}
+
+// Position is a musical position in a score
+type Position struct {
+	Bar  int     // 1-based bar number
+	Beat float64 // 1-based beat within the bar, fractional between beats
+}
+
+// IsDownbeat reports whether the position is the first beat of a bar
+func (p Position) IsDownbeat() bool {
+	return p.Beat == 1
+}
+
+// ScoreNote is a note of a score track with both musical and clock timing
+type ScoreNote struct {
+	Key        int      // MIDI note number (0-127)
+	Velocity   int      // Note-on velocity (1-127)
+	Volume     int      // Channel volume (CC7) at the onset (0-127)
+	Expression int      // Channel expression (CC11) at the onset (0-127)
+	Channel    int      // MIDI channel (0-15)
+	StartTick  int64    // Absolute onset in ticks
+	EndTick    int64    // Absolute release in ticks
+	Start      float64  // Onset in seconds from the start of the file
+	Duration   float64  // Duration in seconds
+	Position   Position // Bar and beat of the onset
+}
+
+// Selection constants for Score.Select
+const (
+	// AllTracks selects notes from every track
+	AllTracks = -2
+	// AllChannels selects notes on every channel. Channels are otherwise
+	// selected 1-based, as numbered in sequencers.
+	AllChannels = 0
+)
+
+// ScoreTrack holds the notes of one MIDI track
+type ScoreTrack struct {
+	Number          int         // Track number in the file, 0-based, or AllTracks
+	Name            string      // Track name meta event, if any
+	Instrument      string      // Instrument name meta event, if any
+	Program         int         // First program change in the track, -1 if none
+	ChannelPrograms map[int]int // First program change on each 0-based channel
+	Notes           []ScoreNote // Notes ordered by onset, then pitch
+	Lyrics          []Lyric     // Lyric events ordered by tick, or text events if there are none
+}
+
+// Score is a parsed MIDI file: its tracks' notes together with the tempo
+// map and meter changes needed to place them in time
+type Score struct {
+	Format          int // SMF format: 0 for a single multi-channel track, 1 for parallel tracks
+	TicksPerQuarter int
+	Tempos          []TempoChange // Ordered by tick, the first always at tick 0
+	Meters          []MeterChange // Ordered by tick, the first always at tick 0
+	Tracks          []ScoreTrack
+	Warnings        []Warning // Note events that could not be paired, by track then tick
+}
+
+// ParseScore reads a Standard MIDI File into a Score. Note events are paired
+// in a single pass: each note-off releases the oldest sounding note of the
+// same channel and key. Notes that are never released or have no length are
+// dropped and note-offs with nothing to release are ignored, each recorded
+// in the score's Warnings.
+func ParseScore(ctx context.Context, reader io.Reader) (*Score, error) {
+	file, err := smf.ReadFrom(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MIDI file: %w", err)
+	}
+	if len(file.Tracks) == 0 {
+		return nil, fmt.Errorf("no MIDI events found")
+	}
+
+	ticks, ok := file.TimeFormat.(smf.MetricTicks)
+	if !ok {
+		return nil, fmt.Errorf("unsupported MIDI time format: %v", file.TimeFormat)
+	}
+	score := &Score{Format: int(file.Format()), TicksPerQuarter: int(ticks)}
+	if score.TicksPerQuarter == 0 {
+		score.TicksPerQuarter = 960
+	}
+
+	score.buildTimeline(file.Tracks)
+
+	for number, track := range file.Tracks {
+		parsed, warnings, err := score.parseTrack(ctx, number, track)
+		if err != nil {
+			return nil, err
+		}
+		score.Tracks = append(score.Tracks, parsed)
+		score.Warnings = append(score.Warnings, warnings...)
+	}
+
+	return score, nil
+}
+
+// timelineEvent is a tempo change (BPM set) or time signature (Numerator
+// and Denominator set) at an absolute tick
+type timelineEvent struct {
+	tick        int64
+	bpm         float64
+	numerator   int
+	denominator int
+}
+
+// buildTimeline collects tempo and time signature events from every track
+// into the tempo map and meter list
+func (s *Score) buildTimeline(tracks []smf.Track) {
+	var events []timelineEvent
+	for _, track := range tracks {
+		var tick int64
+		for _, ev := range track {
+			tick += int64(ev.Delta)
+			var bpm float64
+			var num, denom uint8
+			switch {
+			case ev.Message.GetMetaTempo(&bpm):
+				events = append(events, timelineEvent{tick: tick, bpm: bpm})
+			case ev.Message.GetMetaMeter(&num, &denom):
+				events = append(events, timelineEvent{tick: tick, numerator: int(num), denominator: int(denom)})
+			}
+		}
+	}
+	s.setTimeline(events)
+}
+
+// setTimeline builds the tempo map and meter list from events in any order.
+// Later events on the same tick replace earlier ones.
+func (s *Score) setTimeline(events []timelineEvent) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].tick < events[j].tick })
+
+	s.Tempos = []TempoChange{{BPM: DefaultBPM}}
+	s.Meters = []MeterChange{{Bar: 1, Numerator: 4, Denominator: 4}}
+
+	for _, ev := range events {
+		switch {
+		case ev.bpm > 0:
+			change := TempoChange{Tick: ev.tick, Seconds: s.Seconds(ev.tick), BPM: ev.bpm}
+			if last := &s.Tempos[len(s.Tempos)-1]; last.Tick == ev.tick {
+				*last = change
+			} else {
+				s.Tempos = append(s.Tempos, change)
+			}
+		case ev.numerator > 0 && ev.denominator > 0:
+			// A meter change always starts a new bar, even mid-bar
+			pos := s.PositionAt(ev.tick)
+			bar := pos.Bar
+			if !pos.IsDownbeat() {
+				bar++
+			}
+			change := MeterChange{Tick: ev.tick, Bar: bar, Numerator: ev.numerator, Denominator: ev.denominator}
+			if last := &s.Meters[len(s.Meters)-1]; last.Tick == ev.tick {
+				*last = change
+			} else {
+				s.Meters = append(s.Meters, change)
+			}
+		}
+	}
+}
+
+// parseTrack pairs the note events of one track
+func (s *Score) parseTrack(ctx context.Context, number int, track smf.Track) (ScoreTrack, []Warning, error) {
+	var warnings []Warning
+	warn := func(kind WarningKind, channel, key int, tick int64) {
+		warnings = append(warnings, Warning{
+			Kind: kind, Track: number, Channel: channel, Key: key, Tick: tick, Position: s.PositionAt(tick),
+		})
+	}
+
+	parsed := ScoreTrack{Number: number, Program: -1, ChannelPrograms: map[int]int{}}
+
+	type pendingNote struct {
+		tick       int64
+		velocity   int
+		volume     int
+		expression int
+	}
+	// Note-ons waiting for their note-off, oldest first, by channel and key
+	pending := map[[2]uint8][]pendingNote{}
+
+	// Volume and expression controllers by channel, as set so far
+	var volume, expression [16]int
+	for ch := range volume {
+		volume[ch], expression[ch] = DefaultVolume, DefaultExpression
+	}
+
+	// Text events stand in for lyrics in karaoke files without lyric events
+	var texts []Lyric
+
+	var tick int64
+	for _, ev := range track {
+		if err := ctx.Err(); err != nil {
+			return ScoreTrack{}, nil, err
+		}
+		tick += int64(ev.Delta)
+
+		var channel, key, velocity, program, controller, value uint8
+		var text string
+		switch {
+		case ev.Message.GetNoteStart(&channel, &key, &velocity):
+			id := [2]uint8{channel, key}
+			pending[id] = append(pending[id], pendingNote{
+				tick: tick, velocity: int(velocity), volume: volume[channel], expression: expression[channel],
+			})
+		case ev.Message.GetNoteEnd(&channel, &key):
+			id := [2]uint8{channel, key}
+			if len(pending[id]) == 0 {
+				warn(OrphanNoteOff, int(channel), int(key), tick)
+				continue
+			}
+			on := pending[id][0]
+			pending[id] = pending[id][1:]
+			// Zero-length notes cannot be sung
+			if tick == on.tick {
+				warn(ZeroLengthNote, int(channel), int(key), tick)
+				continue
+			}
+			note := s.newNote(int(key), on.velocity, int(channel), on.tick, tick)
+			note.Volume, note.Expression = on.volume, on.expression
+			parsed.Notes = append(parsed.Notes, note)
+		case ev.Message.GetControlChange(&channel, &controller, &value):
+			switch controller {
+			case controllerVolume:
+				volume[channel] = int(value)
+			case controllerExpression:
+				expression[channel] = int(value)
+			}
+		case ev.Message.GetMetaLyric(&text):
+			parsed.Lyrics = append(parsed.Lyrics, Lyric{Tick: tick, Position: s.PositionAt(tick), Text: text})
+		case ev.Message.GetMetaText(&text):
+			// Karaoke files start with "@" headers naming the song and language
+			if !strings.HasPrefix(text, "@") {
+				texts = append(texts, Lyric{Tick: tick, Position: s.PositionAt(tick), Text: text})
+			}
+		case ev.Message.GetMetaTrackName(&text):
+			if parsed.Name == "" {
+				parsed.Name = text
+			}
+		case ev.Message.GetMetaInstrument(&text):
+			if parsed.Instrument == "" {
+				parsed.Instrument = text
+			}
+		case ev.Message.GetProgramChange(&channel, &program):
+			if parsed.Program < 0 {
+				parsed.Program = int(program)
+			}
+			if _, ok := parsed.ChannelPrograms[int(channel)]; !ok {
+				parsed.ChannelPrograms[int(channel)] = int(program)
+			}
+		}
+	}
+
+	for id, notes := range pending {
+		for _, on := range notes {
+			warn(DanglingNoteOn, int(id[0]), int(id[1]), on.tick)
+		}
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		a, b := warnings[i], warnings[j]
+		if a.Tick != b.Tick {
+			return a.Tick < b.Tick
+		}
+		if a.Channel != b.Channel {
+			return a.Channel < b.Channel
+		}
+		return a.Key < b.Key
+	})
+
+	if len(parsed.Lyrics) == 0 {
+		parsed.Lyrics = texts
+	}
+
+	sortNotes(parsed.Notes)
+	return parsed, warnings, nil
+}
+
+// sortNotes orders notes by onset, then pitch
+func sortNotes(notes []ScoreNote) {
+	sort.SliceStable(notes, func(i, j int) bool {
+		a, b := notes[i], notes[j]
+		if a.StartTick != b.StartTick {
+			return a.StartTick < b.StartTick
+		}
+		return a.Key < b.Key
+	})
+}
+
+// newNote places a note in time, at the default volume and expression
+func (s *Score) newNote(key, velocity, channel int, startTick, endTick int64) ScoreNote {
+	start := s.Seconds(startTick)
+	return ScoreNote{
+		Key:        key,
+		Velocity:   velocity,
+		Volume:     DefaultVolume,
+		Expression: DefaultExpression,
+		Channel:    channel,
+		StartTick:  startTick,
+		EndTick:    endTick,
+		Start:      start,
+		Duration:   s.Seconds(endTick) - start,
+		Position:   s.PositionAt(startTick),
+	}
+}
+
+// Seconds converts an absolute tick to seconds using the tempo map
+func (s *Score) Seconds(tick int64) float64 {
+	i := sort.Search(len(s.Tempos), func(i int) bool { return s.Tempos[i].Tick > tick }) - 1
+	tempo := s.Tempos[max(i, 0)]
+
+	quarters := float64(tick-tempo.Tick) / float64(s.TicksPerQuarter)
+	return tempo.Seconds + quarters*60/tempo.BPM
+}
+
+// PositionAt returns the bar and beat of an absolute tick
+func (s *Score) PositionAt(tick int64) Position {
+	i := sort.Search(len(s.Meters), func(i int) bool { return s.Meters[i].Tick > tick }) - 1
+	meter := s.Meters[max(i, 0)]
+
+	// Short beats at a low resolution, like 4/128 at 24 ticks per quarter,
+	// round down to a tick rather than to nothing
+	beatTicks := max(int64(s.TicksPerQuarter)*4/int64(meter.Denominator), 1)
+	barTicks := beatTicks * int64(meter.Numerator)
+
+	offset := tick - meter.Tick
+	bars := offset / barTicks
+	return Position{
+		Bar:  meter.Bar + int(bars),
+		Beat: 1 + float64(offset-bars*barTicks)/float64(beatTicks),
+	}
+}
+
+// Track returns the track with the given number
+func (s *Score) Track(trackNo int) (*ScoreTrack, error) {
+	if trackNo < 0 || trackNo >= len(s.Tracks) {
+		return nil, fmt.Errorf("track %d not found, the file has %d tracks", trackNo, len(s.Tracks))
+	}
+	return &s.Tracks[trackNo], nil
+}
+
+// Select returns the notes of a track, or of every track for AllTracks,
+// played on a 1-based channel, or on every channel for AllChannels. The
+// program reported is the channel's when one is selected.
+func (s *Score) Select(trackNo, channel int) (ScoreTrack, error) {
+	if channel < AllChannels || channel > 16 {
+		return ScoreTrack{}, fmt.Errorf("invalid channel %d, must be 1-16", channel)
+	}
+
+	var tracks []ScoreTrack
+	if trackNo == AllTracks {
+		tracks = s.Tracks
+	} else {
+		track, err := s.Track(trackNo)
+		if err != nil {
+			return ScoreTrack{}, err
+		}
+		tracks = []ScoreTrack{*track}
+	}
+
+	selected := ScoreTrack{Number: trackNo, Program: -1, ChannelPrograms: map[int]int{}}
+	if len(tracks) == 1 {
+		selected.Name, selected.Instrument, selected.Program = tracks[0].Name, tracks[0].Instrument, tracks[0].Program
+	}
+	for _, track := range tracks {
+		for ch, program := range track.ChannelPrograms {
+			if _, ok := selected.ChannelPrograms[ch]; !ok {
+				selected.ChannelPrograms[ch] = program
+			}
+		}
+		for _, note := range track.Notes {
+			if channel == AllChannels || note.Channel == channel-1 {
+				selected.Notes = append(selected.Notes, note)
+			}
+		}
+	}
+
+	if channel != AllChannels {
+		selected.Program = -1
+		if program, ok := selected.ChannelPrograms[channel-1]; ok {
+			selected.Program = program
+		}
+	}
+	if len(tracks) > 1 {
+		sortNotes(selected.Notes)
+	}
+	return selected, nil
+}
+
+// Channels returns the 0-based channels notes are played on in the file
+func (s *Score) Channels() []int {
+	all, _ := s.Select(AllTracks, AllChannels)
+	return all.Summary().Channels
+}
+
+// SplitByChannel reports whether the file is format 0 with several channels
+// on its one track, so its parts must be told apart by channel
+func (s *Score) SplitByChannel() bool {
+	return s.Format == 0 && len(s.Channels()) > 1
+}
+
+// ScaleTempo returns a copy of notes played scale times as fast: 2 doubles
+// the tempo, 0.5 halves it. Ticks and bar positions are left as they are.
+func ScaleTempo(notes []Note, scale float64) []Note {
+	scaled := make([]Note, len(notes))
+	for i, note := range notes {
+		note.Start /= scale
+		note.Duration /= scale
+		scaled[i] = note
+	}
+	return scaled
+}
@@ -0,0 +1,403 @@
+package fonspeak_midi
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// buildScoreMIDI writes a two-track SMF: a conductor track with the given
+// tempo and meter events and a melody track of consecutive quarter notes
+func buildScoreMIDI(t *testing.T, conductor smf.Track, keys ...uint8) *bytes.Reader {
+	t.Helper()
+
+	conductor.Close(0)
+
+	var melody smf.Track
+	melody.Add(0, smf.MetaTrackSequenceName("Melody"))
+	for _, key := range keys {
+		melody.Add(0, midi.NoteOn(0, key, 90))
+		melody.Add(960, midi.NoteOff(0, key))
+	}
+	melody.Close(0)
+
+	s := smf.New()
+	for _, track := range []smf.Track{conductor, melody} {
+		if err := s.Add(track); err != nil {
+			t.Fatalf("failed to add track: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatalf("failed to write SMF: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestParseScore_TempoMap(t *testing.T) {
+	// 120 BPM for two quarters, then 60 BPM
+	var conductor smf.Track
+	conductor.Add(0, smf.MetaTempo(120))
+	conductor.Add(1920, smf.MetaTempo(60))
+
+	score, err := ParseScore(context.Background(), buildScoreMIDI(t, conductor, 60, 62, 64, 65))
+	if err != nil {
+		t.Fatalf("ParseScore() error: %v", err)
+	}
+
+	if len(score.Tempos) != 2 || score.Tempos[1].Tick != 1920 || math.Abs(score.Tempos[1].Seconds-1.0) > 1e-9 {
+		t.Fatalf("tempo map = %+v, want 120 BPM then 60 BPM from tick 1920 (1s)", score.Tempos)
+	}
+
+	track, err := score.Track(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track.Name != "Melody" {
+		t.Errorf("track name = %q, want Melody", track.Name)
+	}
+
+	wantStarts := []float64{0, 0.5, 1.0, 2.0}
+	wantDurations := []float64{0.5, 0.5, 1.0, 1.0}
+	if len(track.Notes) != len(wantStarts) {
+		t.Fatalf("got %d notes, want %d", len(track.Notes), len(wantStarts))
+	}
+	for i, note := range track.Notes {
+		if math.Abs(note.Start-wantStarts[i]) > 1e-9 || math.Abs(note.Duration-wantDurations[i]) > 1e-9 {
+			t.Errorf("note %d = %.3fs for %.3fs, want %.3fs for %.3fs", i, note.Start, note.Duration, wantStarts[i], wantDurations[i])
+		}
+		if note.StartTick != int64(i)*960 || note.Velocity != 90 {
+			t.Errorf("note %d tick = %d velocity = %d, want %d and 90", i, note.StartTick, note.Velocity, i*960)
+		}
+	}
+}
+
+func TestParseScore_Meters(t *testing.T) {
+	// One bar of 3/4, then 2/4 from bar 2
+	var conductor smf.Track
+	conductor.Add(0, smf.MetaMeter(3, 4))
+	conductor.Add(2880, smf.MetaMeter(2, 4))
+
+	score, err := ParseScore(context.Background(), buildScoreMIDI(t, conductor, 60, 62, 64, 65, 67, 69))
+	if err != nil {
+		t.Fatalf("ParseScore() error: %v", err)
+	}
+
+	want := []Position{{1, 1}, {1, 2}, {1, 3}, {2, 1}, {2, 2}, {3, 1}}
+	for i, note := range score.Tracks[1].Notes {
+		if note.Position != want[i] {
+			t.Errorf("note %d position = %+v, want %+v", i, note.Position, want[i])
+		}
+	}
+	if !score.Tracks[1].Notes[3].Position.IsDownbeat() {
+		t.Error("first note of bar 2 should be a downbeat")
+	}
+
+	// Eighth note offsets are fractional beats
+	if pos := score.PositionAt(480); pos != (Position{Bar: 1, Beat: 1.5}) {
+		t.Errorf("PositionAt(480) = %+v, want bar 1 beat 1.5", pos)
+	}
+}
+
+func TestParseScore_ShortBeats(t *testing.T) {
+	// 128th note beats are shorter than a tick at 24 ticks per quarter
+	var track smf.Track
+	track.Add(0, smf.MetaMeter(4, 128))
+	track.Add(0, midi.NoteOn(0, 60, 100))
+	track.Add(24, midi.NoteOff(0, 60))
+	track.Close(0)
+
+	s := smf.New()
+	s.TimeFormat = smf.MetricTicks(24)
+	if err := s.Add(track); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	score, err := ParseScore(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("ParseScore() error: %v", err)
+	}
+	if score.Meters[0].Denominator != 128 {
+		t.Fatalf("meters = %+v, want 4/128", score.Meters)
+	}
+	if pos := score.PositionAt(24); pos != (Position{Bar: 7, Beat: 1}) {
+		t.Errorf("PositionAt(24) = %+v, want bar 7 beat 1", pos)
+	}
+}
+
+func TestParseScore_OverlappingSameKey(t *testing.T) {
+	// A second note-on of the same key before the first is released pairs
+	// first-in first-out
+	var track smf.Track
+	track.Add(0, midi.NoteOn(0, 60, 100))
+	track.Add(480, midi.NoteOn(0, 60, 100))
+	track.Add(480, midi.NoteOff(0, 60))
+	track.Add(480, midi.NoteOff(0, 60))
+	track.Close(0)
+
+	s := smf.New()
+	if err := s.Add(track); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	score, err := ParseScore(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("ParseScore() error: %v", err)
+	}
+	notes := score.Tracks[0].Notes
+	if len(notes) != 2 || notes[0].EndTick != 960 || notes[1].StartTick != 480 || notes[1].EndTick != 1440 {
+		t.Errorf("notes = %+v, want 0-960 and 480-1440", notes)
+	}
+}
+
+func TestScaleTempo(t *testing.T) {
+	notes := []Note{
+		{MIDINote: 60, Start: 0, Duration: 1},
+		{Start: 1, Duration: 0.5, Kind: Rest},
+	}
+
+	scaled := ScaleTempo(notes, 2)
+	if scaled[1].Start != 0.5 || scaled[1].Duration != 0.25 || scaled[0].Duration != 0.5 {
+		t.Errorf("ScaleTempo(2) = %+v, want times halved", scaled)
+	}
+	if notes[0].Duration != 1 {
+		t.Error("ScaleTempo modified its input")
+	}
+}
+
+// format0Score parses a single-track file with a piano accompaniment on
+// channel 1 and a flute melody on channel 2, the way many sequencers export
+func format0Score(t *testing.T) *Score {
+	t.Helper()
+
+	var track smf.Track
+	track.Add(0, midi.ProgramChange(0, 0))
+	track.Add(0, midi.ProgramChange(1, 73))
+	for i := uint8(0); i < 8; i++ {
+		track.Add(0, midi.NoteOn(0, 48+i%4, 100))
+		track.Add(0, midi.NoteOn(0, 55+i%4, 100))
+		track.Add(0, midi.NoteOn(1, 67+i%4, 100))
+		track.Add(480, midi.NoteOff(0, 48+i%4))
+		track.Add(0, midi.NoteOff(0, 55+i%4))
+		track.Add(0, midi.NoteOff(1, 67+i%4))
+	}
+	track.Close(0)
+
+	s := smf.New()
+	if err := s.Add(track); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	score, err := ParseScore(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("ParseScore() error: %v", err)
+	}
+	return score
+}
+
+func TestScore_SplitByChannel(t *testing.T) {
+	score := format0Score(t)
+	if score.Format != 0 || !score.SplitByChannel() {
+		t.Errorf("Format = %d, SplitByChannel() = %v, want a format 0 file to split", score.Format, score.SplitByChannel())
+	}
+
+	single, err := ParseScore(context.Background(), buildScoreMIDI(t, nil, 60, 62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if single.SplitByChannel() {
+		t.Error("SplitByChannel() = true for a file with one channel")
+	}
+}
+
+func TestScore_Select(t *testing.T) {
+	score := format0Score(t)
+
+	tests := []struct {
+		name        string
+		track       int
+		channel     int
+		wantNotes   int
+		wantProgram int
+	}{
+		{"whole track", 0, AllChannels, 24, 0},
+		{"piano channel", 0, 1, 16, 0},
+		{"flute channel", 0, 2, 8, 73},
+		{"channel across tracks", AllTracks, 2, 8, 73},
+		{"unused channel", 0, 10, 0, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := score.Select(tt.track, tt.channel)
+			if err != nil {
+				t.Fatalf("Select() error: %v", err)
+			}
+			if len(got.Notes) != tt.wantNotes || got.Program != tt.wantProgram {
+				t.Errorf("Select() = %d notes, program %d; want %d notes, program %d", len(got.Notes), got.Program, tt.wantNotes, tt.wantProgram)
+			}
+		})
+	}
+
+	if _, err := score.Select(0, 17); err == nil {
+		t.Error("Select() with channel 17 succeeded, want an error")
+	}
+}
+
+func TestScore_ChannelMelody(t *testing.T) {
+	score := format0Score(t)
+
+	melody, err := score.ChannelMelody(0, 2, DefaultChordReduction())
+	if err != nil {
+		t.Fatalf("ChannelMelody() error: %v", err)
+	}
+	if len(melody) != 8 || melody[0].MIDINote != 67 || melody[1].MIDINote != 68 {
+		t.Errorf("ChannelMelody() = %+v, want the 8 flute notes from 67", melody)
+	}
+
+	if _, err := score.ChannelMelody(0, 10, DefaultChordReduction()); err == nil || err.Error() != "no notes found on channel 10 of track 0" {
+		t.Errorf("ChannelMelody() on an empty channel error = %v", err)
+	}
+	if _, err := score.ChannelMelody(AllTracks, 10, DefaultChordReduction()); err == nil || err.Error() != "no notes found on channel 10 of any track" {
+		t.Errorf("ChannelMelody() on an empty channel of all tracks error = %v", err)
+	}
+	if _, err := score.ChannelMelody(5, AllChannels, DefaultChordReduction()); err == nil || err.Error() != "track 5 not found, the file has 1 tracks" {
+		t.Errorf("ChannelMelody() on a missing track error = %v", err)
+	}
+}
+
+func TestNoNotesError(t *testing.T) {
+	tests := []struct {
+		trackNo, channel int
+		want             string
+	}{
+		{AllTracks, AllChannels, "no notes found in any track"},
+		{2, AllChannels, "no notes found in track 2"},
+		{AllTracks, 3, "no notes found on channel 3 of any track"},
+		{2, 3, "no notes found on channel 3 of track 2"},
+	}
+	for _, tt := range tests {
+		if got := noNotesError(tt.trackNo, tt.channel).Error(); got != tt.want {
+			t.Errorf("noNotesError(%d, %d) = %q, want %q", tt.trackNo, tt.channel, got, tt.want)
+		}
+	}
+}
+
+func TestParseScore_Dynamics(t *testing.T) {
+	// A crescendo on channel 1 by expression, while channel 2 keeps the defaults
+	var track smf.Track
+	track.Add(0, midi.ControlChange(0, 7, 80))
+	track.Add(0, midi.ControlChange(0, 11, 40))
+	track.Add(0, midi.NoteOn(0, 60, 50))
+	track.Add(0, midi.NoteOn(1, 48, 70))
+	track.Add(960, midi.NoteOff(0, 60))
+	track.Add(0, midi.ControlChange(0, 11, 120))
+	track.Add(0, midi.NoteOn(0, 62, 110))
+	track.Add(960, midi.NoteOff(0, 62))
+	track.Add(0, midi.NoteOff(1, 48))
+	track.Close(0)
+	score := parseTracks(t, track)
+
+	type dynamics struct{ velocity, volume, expression int }
+	var got []dynamics
+	for _, note := range score.Tracks[0].Notes {
+		got = append(got, dynamics{note.Velocity, note.Volume, note.Expression})
+	}
+	want := []dynamics{{70, DefaultVolume, DefaultExpression}, {50, 80, 40}, {110, 80, 120}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dynamics = %v, want %v", got, want)
+	}
+
+	melody, err := score.ChannelMelody(0, 1, DefaultChordReduction())
+	if err != nil {
+		t.Fatalf("ChannelMelody() error: %v", err)
+	}
+	if n := melody[1]; n.Velocity != 110 || n.Volume != 80 || n.Expression != 120 {
+		t.Errorf("second melody note = %+v, want the dynamics of its source note", n)
+	}
+}
+
+func TestScore_ChannelSummaries(t *testing.T) {
+	summaries, err := format0Score(t).ChannelSummaries(0)
+	if err != nil {
+		t.Fatalf("ChannelSummaries() error: %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries, want 2", len(summaries))
+	}
+	piano, flute := summaries[0], summaries[1]
+	if piano.Channels[0] != 0 || piano.MaxPolyphony != 2 || piano.InstrumentName() != "Piano (program 0)" {
+		t.Errorf("piano summary = %+v", piano)
+	}
+	if flute.Channels[0] != 1 || flute.MaxPolyphony != 1 || flute.InstrumentName() != "Pipe (program 73)" {
+		t.Errorf("flute summary = %+v", flute)
+	}
+}
+
+func TestParseScore_Warnings(t *testing.T) {
+	var track smf.Track
+	track.Add(0, midi.NoteOff(0, 59))     // nothing to release
+	track.Add(0, midi.NoteOn(0, 60, 100)) // released on the same tick
+	track.Add(0, midi.NoteOff(0, 60))
+	track.Add(0, midi.NoteOn(0, 62, 100))
+	track.Add(960, midi.NoteOff(0, 62))
+	track.Add(0, midi.NoteOn(1, 64, 100)) // never released
+	track.Close(960)
+
+	s := smf.New()
+	if err := s.Add(track); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	score, err := ParseScore(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("ParseScore() error: %v", err)
+	}
+	if notes := score.Tracks[0].Notes; len(notes) != 1 || notes[0].Key != 62 {
+		t.Errorf("notes = %+v, want only the D", notes)
+	}
+
+	want := []Warning{
+		{Kind: OrphanNoteOff, Channel: 0, Key: 59, Tick: 0, Position: Position{Bar: 1, Beat: 1}},
+		{Kind: ZeroLengthNote, Channel: 0, Key: 60, Tick: 0, Position: Position{Bar: 1, Beat: 1}},
+		{Kind: DanglingNoteOn, Channel: 1, Key: 64, Tick: 960, Position: Position{Bar: 1, Beat: 2}},
+	}
+	if len(score.Warnings) != len(want) {
+		t.Fatalf("warnings = %v, want %v", score.Warnings, want)
+	}
+	for i, w := range want {
+		if score.Warnings[i] != w {
+			t.Errorf("warning %d = %+v, want %+v", i, score.Warnings[i], w)
+		}
+	}
+
+	if got := score.Warnings[2].String(); got != "track 0, channel 2, bar 1 beat 2: E4 is never released and was dropped" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := score.SelectWarnings(0, 2); len(got) != 1 || got[0].Kind != DanglingNoteOn {
+		t.Errorf("SelectWarnings(0, 2) = %v, want the dangling note-on", got)
+	}
+	if got := score.SelectWarnings(1, AllChannels); len(got) != 0 {
+		t.Errorf("SelectWarnings(1, all) = %v, want none", got)
+	}
+}
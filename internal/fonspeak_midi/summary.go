@@ -0,0 +1,162 @@
+package fonspeak_midi
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TrackSummary describes a track so users can pick the one holding the melody
+type TrackSummary struct {
+	Number       int     `json:"number"`
+	Name         string  `json:"name,omitempty"`
+	Instrument   string  `json:"instrument,omitempty"`
+	Program      int     `json:"program"`  // First program change, -1 if none
+	Channels     []int   `json:"channels"` // Channels notes are played on, 0-based
+	NoteCount    int     `json:"noteCount"`
+	LowestKey    int     `json:"lowestKey"`
+	HighestKey   int     `json:"highestKey"`
+	MaxPolyphony int     `json:"maxPolyphony"` // Most notes sounding at once
+	Start        float64 `json:"start"`        // Onset of the first note in seconds
+	Duration     float64 `json:"duration"`     // Seconds from the first onset to the last release
+	Lyrics       int     `json:"lyrics"`       // Lyric (or karaoke text) events in the track
+}
+
+// HasNotes reports whether the track has any notes to sing
+func (t TrackSummary) HasNotes() bool {
+	return t.NoteCount > 0
+}
+
+// PitchRange formats the lowest and highest notes, e.g. "C4-G5"
+func (t TrackSummary) PitchRange() string {
+	if !t.HasNotes() {
+		return ""
+	}
+	return NoteName(t.LowestKey) + "-" + NoteName(t.HighestKey)
+}
+
+// gmFamilies are the General MIDI instrument families, eight programs each
+var gmFamilies = [16]string{
+	"Piano", "Chromatic Percussion", "Organ", "Guitar", "Bass", "Strings", "Ensemble", "Brass",
+	"Reed", "Pipe", "Synth Lead", "Synth Pad", "Synth Effects", "Ethnic", "Percussive", "Sound Effects",
+}
+
+// percussionChannel is the General MIDI drum channel, 0-based
+const percussionChannel = 9
+
+// IsPercussion reports whether the track only plays the General MIDI drum channel
+func (t TrackSummary) IsPercussion() bool {
+	return len(t.Channels) == 1 && t.Channels[0] == percussionChannel
+}
+
+// InstrumentName describes the track's instrument: its instrument meta event
+// if set, otherwise the General MIDI family of its program
+func (t TrackSummary) InstrumentName() string {
+	switch {
+	case t.Instrument != "":
+		return t.Instrument
+	case t.IsPercussion():
+		return "Drums"
+	case t.Program >= 0:
+		return fmt.Sprintf("%s (program %d)", gmFamilies[t.Program/8], t.Program)
+	default:
+		return ""
+	}
+}
+
+// Summaries describes every track of the score
+func (s *Score) Summaries() []TrackSummary {
+	summaries := make([]TrackSummary, 0, len(s.Tracks))
+	for _, track := range s.Tracks {
+		summaries = append(summaries, track.Summary())
+	}
+	return summaries
+}
+
+// ChannelSummaries describes the notes on each channel of a track, or of the
+// whole file for AllTracks. Each summary has a single channel.
+func (s *Score) ChannelSummaries(trackNo int) ([]TrackSummary, error) {
+	all, err := s.Select(trackNo, AllChannels)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := []TrackSummary{}
+	for _, ch := range all.Summary().Channels {
+		selected, err := s.Select(trackNo, ch+1)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, selected.Summary())
+	}
+	return summaries, nil
+}
+
+// Summary describes the track
+func (t ScoreTrack) Summary() TrackSummary {
+	summary := TrackSummary{
+		Number:     t.Number,
+		Name:       t.Name,
+		Instrument: t.Instrument,
+		Program:    t.Program,
+		Channels:   []int{},
+		NoteCount:  len(t.Notes),
+		Lyrics:     len(t.Lyrics),
+	}
+	if len(t.Notes) == 0 {
+		return summary
+	}
+
+	channels := map[int]bool{}
+	summary.LowestKey, summary.HighestKey = t.Notes[0].Key, t.Notes[0].Key
+	end := 0.0
+	for _, note := range t.Notes {
+		channels[note.Channel] = true
+		summary.LowestKey = min(summary.LowestKey, note.Key)
+		summary.HighestKey = max(summary.HighestKey, note.Key)
+		end = max(end, note.Start+note.Duration)
+	}
+	for channel := range channels {
+		summary.Channels = append(summary.Channels, channel)
+	}
+	sort.Ints(summary.Channels)
+
+	summary.Start = t.Notes[0].Start
+	summary.Duration = end - summary.Start
+	summary.MaxPolyphony = maxPolyphony(t.Notes)
+
+	return summary
+}
+
+// maxPolyphony sweeps note onsets and releases to find the most notes
+// sounding at once. A release and an onset on the same tick do not overlap.
+func maxPolyphony(notes []ScoreNote) int {
+	type edge struct {
+		tick  int64
+		delta int
+	}
+	edges := make([]edge, 0, 2*len(notes))
+	for _, note := range notes {
+		edges = append(edges, edge{note.StartTick, 1}, edge{note.EndTick, -1})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].tick != edges[j].tick {
+			return edges[i].tick < edges[j].tick
+		}
+		return edges[i].delta < edges[j].delta
+	})
+
+	sounding, most := 0, 0
+	for _, e := range edges {
+		sounding += e.delta
+		most = max(most, sounding)
+	}
+	return most
+}
+
+// noteNames are the pitch classes of MIDI note numbers, spelled with sharps
+var noteNames = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// NoteName returns the scientific pitch name of a MIDI note, e.g. 60 is "C4"
+func NoteName(key int) string {
+	return fmt.Sprintf("%s%d", noteNames[key%12], key/12-1)
+}
@@ -0,0 +1,98 @@
+package fonspeak_midi
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+func TestScore_Summaries(t *testing.T) {
+	var conductor smf.Track
+	conductor.Add(0, smf.MetaTempo(120))
+	conductor.Close(0)
+
+	// A C major triad on channel 0 followed by a G on channel 2
+	var chords smf.Track
+	chords.Add(0, smf.MetaTrackSequenceName("Piano"))
+	chords.Add(0, smf.MetaInstrument("Grand Piano"))
+	chords.Add(0, midi.ProgramChange(0, 1))
+	chords.Add(0, midi.NoteOn(0, 60, 100))
+	chords.Add(0, midi.NoteOn(0, 64, 100))
+	chords.Add(0, midi.NoteOn(0, 67, 100))
+	chords.Add(960, midi.NoteOff(0, 60))
+	chords.Add(0, midi.NoteOff(0, 64))
+	chords.Add(0, midi.NoteOff(0, 67))
+	chords.Add(0, midi.NoteOn(2, 79, 100))
+	chords.Add(960, midi.NoteOff(2, 79))
+	chords.Close(0)
+
+	s := smf.New()
+	for _, track := range []smf.Track{conductor, chords} {
+		if err := s.Add(track); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	score, err := ParseScore(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("ParseScore() error: %v", err)
+	}
+	summaries := score.Summaries()
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries, want 2", len(summaries))
+	}
+
+	if summaries[0].HasNotes() || summaries[0].Program != -1 {
+		t.Errorf("conductor summary = %+v, want no notes or program", summaries[0])
+	}
+
+	got := summaries[1]
+	if got.Name != "Piano" || got.Instrument != "Grand Piano" || got.Program != 1 {
+		t.Errorf("name/instrument/program = %q/%q/%d, want Piano/Grand Piano/1", got.Name, got.Instrument, got.Program)
+	}
+	if got.NoteCount != 4 || got.MaxPolyphony != 3 || got.PitchRange() != "C4-G5" {
+		t.Errorf("notes = %d, polyphony = %d, range = %s, want 4, 3, C4-G5", got.NoteCount, got.MaxPolyphony, got.PitchRange())
+	}
+	if len(got.Channels) != 2 || got.Channels[0] != 0 || got.Channels[1] != 2 {
+		t.Errorf("channels = %v, want [0 2]", got.Channels)
+	}
+	if got.Duration != 1.0 {
+		t.Errorf("duration = %v, want 1.0", got.Duration)
+	}
+}
+
+func TestTrackSummary_InstrumentName(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary TrackSummary
+		want    string
+	}{
+		{"meta event", TrackSummary{Instrument: "Voice", Program: 0}, "Voice"},
+		{"program", TrackSummary{Program: 41}, "Strings (program 41)"},
+		{"drums", TrackSummary{Program: -1, Channels: []int{9}}, "Drums"},
+		{"unknown", TrackSummary{Program: -1, Channels: []int{0}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.summary.InstrumentName(); got != tt.want {
+				t.Errorf("InstrumentName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoteName(t *testing.T) {
+	tests := map[int]string{60: "C4", 69: "A4", 61: "C#4", 0: "C-1", 127: "G9"}
+	for key, want := range tests {
+		if got := NoteName(key); got != want {
+			t.Errorf("NoteName(%d) = %q, want %q", key, got, want)
+		}
+	}
+}
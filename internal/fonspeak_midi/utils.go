@@ -2,14 +2,48 @@ package fonspeak_midi
 
 import (
 	"math"
-	"strings"
 	"unicode"
 )
 
-// Note represents a musical note with pitch (MIDI number) and duration in seconds
+// NoteKind distinguishes sounding notes from rests
+type NoteKind int
+
+const (
+	// Pitched is a sounding note
+	Pitched NoteKind = iota
+	// Rest is a silence between sounding notes
+	Rest
+)
+
+// Note represents a musical note with pitch (MIDI number), onset and duration in seconds
 type Note struct {
-	MIDINote int     // MIDI note number (0-127)
-	Duration float64 // Duration in seconds
+	MIDINote int      // MIDI note number (0-127), unused for rests
+	Start    float64  // Onset time in seconds from the start of the file
+	Duration float64  // Duration in seconds
+	Kind     NoteKind // Pitched or Rest
+	Tick     int64    // Onset in MIDI ticks in the source file
+	Position Position // Bar and beat of the onset in the source file
+	Lyric    string   // Lyric embedded in the source file for this note, if any
+	// Dynamics of the source note, all 0 for rests and notes built without them
+	Velocity   int // Note-on velocity (1-127)
+	Volume     int // Channel volume (CC7) at the onset (0-127)
+	Expression int // Channel expression (CC11) at the onset (0-127)
+}
+
+// IsRest reports whether the note is a rest
+func (n Note) IsRest() bool {
+	return n.Kind == Rest
+}
+
+// CountPitchedNotes returns the number of non-rest notes
+func CountPitchedNotes(notes []Note) int {
+	count := 0
+	for _, note := range notes {
+		if !note.IsRest() {
+			count++
+		}
+	}
+	return count
 }
 
 // MIDINoteToHz converts a MIDI note number to frequency in Hz with optional octave shift
@@ -49,6 +83,9 @@ func FindMaxFrequency(notes []Note) float64 {
 
 	maxHz := 0.0
 	for _, note := range notes {
+		if note.IsRest() {
+			continue
+		}
 		hz := MIDINoteToHz(note.MIDINote, 0)
 		if hz > maxHz {
 			maxHz = hz
@@ -82,21 +119,32 @@ func WPMFromDuration(seconds float64) int {
 }
 
 // RepeatMelodyToCoverSyllables repeats the melody sequence until it covers all syllables
+// Only pitched notes count towards the syllable count; rests are carried along
+// and each repetition is shifted in time so onsets keep increasing
 func RepeatMelodyToCoverSyllables(notes []Note, syllableCount int) []Note {
-	if len(notes) == 0 {
+	if CountPitchedNotes(notes) == 0 {
 		return notes
 	}
 
-	if len(notes) >= syllableCount {
-		return notes[:syllableCount]
+	// Length of one pass through the melody, used to offset repeated onsets
+	span := 0.0
+	for _, note := range notes {
+		if end := note.Start + note.Duration; end > span {
+			span = end
+		}
 	}
 
 	result := make([]Note, 0, syllableCount)
-	for len(result) < syllableCount {
+	pitched := 0
+	for pass := 0; pitched < syllableCount; pass++ {
 		for _, note := range notes {
+			note.Start += float64(pass) * span
 			result = append(result, note)
-			if len(result) >= syllableCount {
-				break
+			if !note.IsRest() {
+				pitched++
+				if pitched >= syllableCount {
+					break
+				}
 			}
 		}
 	}
@@ -106,8 +154,7 @@ func RepeatMelodyToCoverSyllables(notes []Note, syllableCount int) []Note {
 
 // isVowelChar checks if a character is a vowel (including X-SAMPA vowel symbols)
 func isVowelChar(r rune) bool {
-	vowels := "aeiouæɑɔəɛɪʊʌAEIOUyY@69"
-	return strings.ContainsRune(vowels, r)
+	return IsVowelSymbol(r)
 }
 
 // isVowelPhoneme checks if a phoneme string represents a vowel
@@ -116,8 +163,11 @@ func isVowelPhoneme(phoneme string) bool {
 		return false
 	}
 	for _, r := range phoneme {
-		if unicode.IsLetter(r) || r == '@' {
-			return isVowelChar(r)
+		if isVowelChar(r) {
+			return true
+		}
+		if unicode.IsLetter(r) {
+			return false
 		}
 	}
 	return false
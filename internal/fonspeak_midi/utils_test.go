@@ -103,6 +103,27 @@ func TestRepeatMelodyToCoverSyllables(t *testing.T) {
 	}
 }
 
+func TestRepeatMelodyToCoverSyllables_Rests(t *testing.T) {
+	notes := []Note{
+		{MIDINote: 60, Start: 0, Duration: 0.5},
+		{Start: 0.5, Duration: 0.5, Kind: Rest},
+		{MIDINote: 62, Start: 1.0, Duration: 0.5},
+	}
+
+	got := RepeatMelodyToCoverSyllables(notes, 3)
+
+	// Two pitched notes and a rest, then the first note of the second pass
+	if len(got) != 4 {
+		t.Fatalf("RepeatMelodyToCoverSyllables() returned %d notes, want 4: %+v", len(got), got)
+	}
+	if CountPitchedNotes(got) != 3 {
+		t.Errorf("CountPitchedNotes() = %d, want 3", CountPitchedNotes(got))
+	}
+	if got[3].MIDINote != 60 || math.Abs(got[3].Start-1.5) > 1e-9 {
+		t.Errorf("repeated note = %+v, want key 60 starting at 1.5s", got[3])
+	}
+}
+
 func TestAlignSyllablesToMelody(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -239,11 +260,15 @@ func TestIPAToXSAMPA(t *testing.T) {
 		{"Multiple syllables", "a don o l@m", 4},
 		{"Empty string", "", 0},
 		{"Multiple spaces", "a  b   c", 3},
+		{"Hyphenated syllables", "a-dOn o-l@m", 4},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := IPAToXSAMPA(tt.input)
+			got, err := IPAToXSAMPA(tt.input)
+			if err != nil {
+				t.Fatalf("IPAToXSAMPA(%q) error: %v", tt.input, err)
+			}
 			if len(got) != tt.wantLen {
 				t.Errorf("IPAToXSAMPA(%q) returned %d syllables, want %d", tt.input, len(got), tt.wantLen)
 			}
@@ -0,0 +1,55 @@
+package fonspeak_midi
+
+import "fmt"
+
+// WarningKind classifies a problem found while pairing note events
+type WarningKind string
+
+const (
+	// DanglingNoteOn is a note-on never released before the track ends. The
+	// note is dropped.
+	DanglingNoteOn WarningKind = "dangling-note-on"
+	// OrphanNoteOff is a note-off with no sounding note to release. It is
+	// ignored.
+	OrphanNoteOff WarningKind = "orphan-note-off"
+	// ZeroLengthNote is a note released on the tick it started. It is
+	// dropped, as it cannot be sung.
+	ZeroLengthNote WarningKind = "zero-length-note"
+)
+
+// Warning reports a note event ParseScore could not use
+type Warning struct {
+	Kind     WarningKind
+	Track    int      // Track number, 0-based
+	Channel  int      // MIDI channel, 0-based like ScoreNote.Channel
+	Key      int      // MIDI note number
+	Tick     int64    // Absolute tick of the event
+	Position Position // Bar and beat of the event
+}
+
+// String describes the warning for people, with the channel 1-based
+func (w Warning) String() string {
+	where := fmt.Sprintf("track %d, channel %d, bar %d beat %g", w.Track, w.Channel+1, w.Position.Bar, w.Position.Beat)
+	switch w.Kind {
+	case DanglingNoteOn:
+		return fmt.Sprintf("%s: %s is never released and was dropped", where, NoteName(w.Key))
+	case OrphanNoteOff:
+		return fmt.Sprintf("%s: note-off for %s has no matching note-on and was ignored", where, NoteName(w.Key))
+	case ZeroLengthNote:
+		return fmt.Sprintf("%s: %s has no length and was dropped", where, NoteName(w.Key))
+	default:
+		return fmt.Sprintf("%s: %s for %s", where, w.Kind, NoteName(w.Key))
+	}
+}
+
+// SelectWarnings returns the warnings for the notes Select picks by track
+// and channel
+func (s *Score) SelectWarnings(trackNo, channel int) []Warning {
+	var selected []Warning
+	for _, w := range s.Warnings {
+		if (trackNo == AllTracks || w.Track == trackNo) && (channel == AllChannels || w.Channel == channel-1) {
+			selected = append(selected, w)
+		}
+	}
+	return selected
+}
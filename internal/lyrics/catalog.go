@@ -0,0 +1,131 @@
+package lyrics
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultTextID is the text sung when none is chosen
+const DefaultTextID = "adon-olam"
+
+// Text is a liturgical text from the catalog
+type Text struct {
+	ID            string        `json:"id"`
+	Title         string        `json:"title"`
+	Language      string        `json:"language"`
+	Pronunciation Pronunciation `json:"pronunciation"` // Tradition the text is usually sung in
+	Verses        []string      `json:"verses"`        // Verses in order, lines separated by newlines
+}
+
+// Lyrics returns the whole text, verses separated by blank lines
+func (t Text) Lyrics() string {
+	return strings.Join(t.Verses, "\n\n")
+}
+
+// Syllables transliterates the text into X-SAMPA syllables. An empty
+// pronunciation reads it in the text's own pronunciation.
+func (t Text) Syllables(pronunciation Pronunciation) ([]string, error) {
+	if pronunciation == "" {
+		pronunciation = t.Pronunciation
+	}
+	return Parse(t.Lyrics(), pronunciation)
+}
+
+// texts holds the catalog files. Each starts with "key: value" header lines
+// (title, language and pronunciation) ended by a blank line, followed by
+// the verses, each separated by a blank line.
+//
+//go:embed texts/*.txt
+var texts embed.FS
+
+// catalog holds every text sorted by ID, read once at startup
+var catalog = loadCatalog()
+
+// Catalog lists every text in the catalog, sorted by ID
+func Catalog() []Text {
+	return append([]Text(nil), catalog...)
+}
+
+// Lookup finds a text by ID. An empty ID selects DefaultTextID.
+func Lookup(id string) (Text, error) {
+	if id == "" {
+		id = DefaultTextID
+	}
+	for _, t := range catalog {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return Text{}, fmt.Errorf("unknown text: %s", id)
+}
+
+// loadCatalog reads the embedded texts. They are part of the binary, so a
+// malformed one is a programming error.
+func loadCatalog() []Text {
+	entries, err := texts.ReadDir("texts")
+	if err != nil {
+		panic(err)
+	}
+	var result []Text
+	for _, e := range entries {
+		data, err := texts.ReadFile(path.Join("texts", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		t, err := parseText(strings.TrimSuffix(e.Name(), ".txt"), string(data))
+		if err != nil {
+			panic(err)
+		}
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// parseText reads a catalog file into a Text
+func parseText(id, data string) (Text, error) {
+	t := Text{ID: id}
+	header, body, ok := strings.Cut(strings.ReplaceAll(data, "\r\n", "\n"), "\n\n")
+	if !ok {
+		return Text{}, fmt.Errorf("text %s: missing blank line after header", id)
+	}
+	for _, line := range strings.Split(header, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return Text{}, fmt.Errorf("text %s: invalid header line %q", id, line)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "title":
+			t.Title = value
+		case "language":
+			t.Language = value
+		case "pronunciation":
+			p, err := ParsePronunciation(value)
+			if err != nil {
+				return Text{}, fmt.Errorf("text %s: %w", id, err)
+			}
+			t.Pronunciation = p
+		default:
+			return Text{}, fmt.Errorf("text %s: unknown header %q", id, key)
+		}
+	}
+	if t.Title == "" {
+		return Text{}, fmt.Errorf("text %s: missing title", id)
+	}
+	if t.Pronunciation == "" {
+		t.Pronunciation = PronunciationModern
+	}
+	for _, verse := range strings.Split(body, "\n\n") {
+		if verse = strings.TrimSpace(verse); verse != "" {
+			t.Verses = append(t.Verses, verse)
+		}
+	}
+	if len(t.Verses) == 0 {
+		return Text{}, fmt.Errorf("text %s: no verses", id)
+	}
+	return t, nil
+}
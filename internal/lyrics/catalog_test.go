@@ -0,0 +1,116 @@
+package lyrics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	want := []string{"adon-olam", "anim-zemirot", "ein-keloheinu", "lecha-dodi", "shalom-aleichem", "yigdal"}
+	var ids []string
+	for _, text := range Catalog() {
+		ids = append(ids, text.ID)
+		if text.Title == "" || text.Language == "" || len(text.Verses) == 0 {
+			t.Errorf("text %s is missing metadata or verses: %+v", text.ID, text)
+		}
+		for _, p := range Pronunciations {
+			if _, err := text.Syllables(p); err != nil {
+				t.Errorf("text %s in %s: %v", text.ID, p, err)
+			}
+		}
+	}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("Catalog() IDs = %q, want %q", ids, want)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		id        string
+		wantTitle string
+		wantErr   bool
+	}{
+		{"", "Adon Olam", false},
+		{"adon-olam", "Adon Olam", false},
+		{"lecha-dodi", "Lecha Dodi", false},
+		{"Lecha Dodi", "", true},
+		{"kol-nidre", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Lookup(tt.id)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Lookup(%q) error = %v, wantErr %v", tt.id, err, tt.wantErr)
+			continue
+		}
+		if got.Title != tt.wantTitle {
+			t.Errorf("Lookup(%q).Title = %q, want %q", tt.id, got.Title, tt.wantTitle)
+		}
+	}
+}
+
+func TestText_Syllables(t *testing.T) {
+	text, err := Lookup("anim-zemirot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text.Pronunciation != PronunciationAshkenazi {
+		t.Fatalf("anim-zemirot pronunciation = %q, want %q", text.Pronunciation, PronunciationAshkenazi)
+	}
+	if len(text.Verses) != 31 {
+		t.Errorf("anim-zemirot has %d verses, want 31", len(text.Verses))
+	}
+
+	tests := []struct {
+		pronunciation Pronunciation
+		want          string
+	}{
+		{"", "an im z@ mi rois v@ Si rim e e roig"},
+		{PronunciationModern, "an im z@ mi rot v@ Si rim e e rog"},
+	}
+	for _, tt := range tests {
+		got, err := text.Syllables(tt.pronunciation)
+		if err != nil {
+			t.Fatalf("Syllables(%q) error: %v", tt.pronunciation, err)
+		}
+		want := strings.Fields(tt.want)
+		if len(got) < len(want) || !reflect.DeepEqual(got[:len(want)], want) {
+			t.Errorf("Syllables(%q) starts %q, want %q", tt.pronunciation, got[:min(len(got), len(want))], want)
+		}
+	}
+}
+
+func TestParseText(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Text
+		wantErr bool
+	}{
+		{
+			name: "verses",
+			data: "title: Test\nlanguage: he\n\nא\nב\n\n\nג\n",
+			want: Text{ID: "test", Title: "Test", Language: "he", Pronunciation: PronunciationModern, Verses: []string{"א\nב", "ג"}},
+		},
+		{
+			name: "pronunciation",
+			data: "title: Test\npronunciation: sephardi\n\nא\n",
+			want: Text{ID: "test", Title: "Test", Pronunciation: PronunciationSephardi, Verses: []string{"א"}},
+		},
+		{name: "no header", data: "א\nב\n", wantErr: true},
+		{name: "no title", data: "language: he\n\nא\n", wantErr: true},
+		{name: "unknown key", data: "title: Test\nauthor: Me\n\nא\n", wantErr: true},
+		{name: "bad pronunciation", data: "title: Test\npronunciation: klingon\n\nא\n", wantErr: true},
+		{name: "no verses", data: "title: Test\n\n\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseText("test", tt.data)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,477 @@
+package lyrics
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Hebrew points and marks, as they appear once text is decomposed
+const (
+	shva        = 'ְ'
+	hatafSegol  = 'ֱ'
+	hatafPatach = 'ֲ'
+	hatafQamats = 'ֳ'
+	hiriq       = 'ִ'
+	tsere       = 'ֵ'
+	segol       = 'ֶ'
+	patach      = 'ַ'
+	qamats      = 'ָ'
+	holam       = 'ֹ'
+	holamHaser  = 'ֺ' // holam on a consonantal vav
+	qubuts      = 'ֻ'
+	dagesh      = 'ּ' // also mappiq in he and shuruk in vav
+	meteg       = 'ֽ'
+	maqaf       = '־'
+	rafe        = 'ֿ'
+	shinDot     = 'ׁ'
+	sinDot      = 'ׂ'
+	qamatsQatan = 'ׇ'
+)
+
+// vowelSounds gives the X-SAMPA vowel of each vowel point in the modern
+// pronunciation. Qamats is read as "a" unless it is found to be qamats qatan.
+var vowelSounds = map[rune]string{
+	hatafSegol:  "e",
+	hatafPatach: "a",
+	hatafQamats: "o",
+	hiriq:       "i",
+	tsere:       "e",
+	segol:       "e",
+	patach:      "a",
+	qamats:      "a",
+	holam:       "o",
+	holamHaser:  "o",
+	qubuts:      "u",
+	qamatsQatan: "o",
+}
+
+// consonants gives the X-SAMPA of each letter without and with a dagesh in
+// the modern pronunciation, where a dagesh only changes the begadkefat
+// letters that are still distinguished. Alef and ayin are silent, so their
+// syllables start with the vowel.
+var consonants = map[rune][2]string{
+	'א': {"", ""},
+	'ב': {"v", "b"},
+	'ג': {"g", "g"},
+	'ד': {"d", "d"},
+	'ה': {"h", "h"},
+	'ו': {"v", "v"},
+	'ז': {"z", "z"},
+	'ח': {"X", "X"},
+	'ט': {"t", "t"},
+	'י': {"j", "j"},
+	'כ': {"X", "k"},
+	'ל': {"l", "l"},
+	'מ': {"m", "m"},
+	'נ': {"n", "n"},
+	'ס': {"s", "s"},
+	'ע': {"", ""},
+	'פ': {"f", "p"},
+	'צ': {"ts", "ts"},
+	'ק': {"k", "k"},
+	'ר': {"r", "r"},
+	'ש': {"S", "S"},
+	'ת': {"t", "t"},
+}
+
+// finalForms folds each final letter into its regular form
+var finalForms = map[rune]rune{'ך': 'כ', 'ם': 'מ', 'ן': 'נ', 'ף': 'פ', 'ץ': 'צ'}
+
+// adonai is read in place of the tetragrammaton, since the name is never
+// pronounced as written
+const adonai = "אֲדֹנָי"
+
+// letter is one consonant of a pointed word with the marks written on it,
+// and how it is pronounced once the word has been read
+type letter struct {
+	base   rune // with final forms folded
+	dagesh bool
+	sin    bool
+	meteg  bool
+	vowel  rune // vowel point, 0 if none
+	male   bool // vowel written with a following vav or yod
+	fixed  bool // consonant already set, for a shuruk sung alone
+	glide  bool // yod closing a diphthong
+	na     bool // vocal shva
+	qatan  bool // qamats read as qamats qatan
+
+	consonant string
+	nucleus   string // vowel sung on the letter, "" if it closes a syllable
+	silent    bool   // mater lectionis or final he
+	geminate  bool   // doubled by a dagesh forte
+	furtive   bool   // patach sung before a final guttural
+}
+
+// word is a run of letters between spaces, punctuation or a maqaf
+type word struct {
+	text    string
+	letters []letter
+	// proclitic is set for a word joined to the next by a maqaf, which
+	// loses its stress
+	proclitic bool
+}
+
+// IsHebrew reports whether text contains any Hebrew letters
+func IsHebrew(text string) bool {
+	for _, r := range text {
+		if isHebrewLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// HebrewSyllables transliterates pointed (nikkud) Hebrew text into X-SAMPA
+// syllables in a pronunciation, the modern one if it is empty. Cantillation
+// and punctuation are ignored, and words joined by a maqaf are read as
+// separate words with the first unstressed. A word with letters that have
+// no vowel points, or any non-Hebrew letter, is reported as an error.
+func HebrewSyllables(text string, pronunciation Pronunciation) ([]string, error) {
+	t := pronunciation.tradition()
+	words, err := splitWords(text)
+	if err != nil {
+		return nil, err
+	}
+	var syllables []string
+	for _, w := range words {
+		s, err := w.syllables(t)
+		if err != nil {
+			return nil, err
+		}
+		syllables = append(syllables, s...)
+	}
+	return syllables, nil
+}
+
+// splitWords splits decomposed text into words, attaching each point to the
+// letter it is written under
+func splitWords(text string) ([]word, error) {
+	var words []word
+	for _, field := range strings.Fields(norm.NFD.String(text)) {
+		current := word{text: norm.NFC.String(field)}
+		for _, r := range field {
+			switch {
+			case isHebrewLetter(r):
+				base := r
+				if regular, ok := finalForms[r]; ok {
+					base = regular
+				}
+				current.letters = append(current.letters, letter{base: base})
+			case r >= '֑' && r <= '֯', r == '׳', r == '״':
+				// Cantillation, geresh and gershayim
+			case r == maqaf, unicode.IsPunct(r):
+				if len(current.letters) > 0 {
+					current.proclitic = r == maqaf
+					words = append(words, current)
+				}
+				current = word{text: current.text}
+			case r >= shva && r <= qamatsQatan:
+				if len(current.letters) == 0 {
+					return nil, fmt.Errorf("vowel point %q without a letter in %q", r, current.text)
+				}
+				current.letters[len(current.letters)-1].mark(r)
+			default:
+				return nil, fmt.Errorf("unexpected %q in Hebrew word %q", r, current.text)
+			}
+		}
+		if len(current.letters) > 0 {
+			words = append(words, current)
+		}
+	}
+	return words, nil
+}
+
+// isHebrewLetter reports whether r is one of the 27 Hebrew letter forms
+func isHebrewLetter(r rune) bool {
+	return r >= 'א' && r <= 'ת'
+}
+
+// mark records a point written on the letter
+func (l *letter) mark(r rune) {
+	switch r {
+	case dagesh:
+		l.dagesh = true
+	case meteg:
+		l.meteg = true
+	case shinDot:
+		l.sin = false
+	case sinDot:
+		l.sin = true
+	case rafe, 'ׄ', 'ׅ':
+		// Rafe only confirms the missing dagesh; upper and lower dots are
+		// editorial marks
+	default:
+		if _, ok := vowelSounds[r]; ok || r == shva {
+			l.vowel = r
+		}
+	}
+}
+
+// consonants returns the letters of the word without their points
+func (w word) consonants() string {
+	var b strings.Builder
+	for _, l := range w.letters {
+		b.WriteRune(l.base)
+	}
+	return b.String()
+}
+
+// syllables reads the word in a tradition and splits it into X-SAMPA
+// syllables, each opened by a consonant (or a silent alef or ayin) with a
+// vowel
+func (w word) syllables(t tradition) ([]string, error) {
+	if c := w.consonants(); c == "יהוה" || c == "יי" {
+		return HebrewSyllables(adonai, t.pronunciation)
+	}
+	w.letters = append([]letter(nil), w.letters...)
+	w.readMatres()
+	w.readConsonants(t)
+	w.readShvas()
+	w.readFinal()
+	w.readVowels(t)
+
+	var syllables []string
+	for _, l := range w.letters {
+		switch {
+		case l.silent:
+		case l.furtive:
+			syllables = append(syllables, l.nucleus+l.consonant)
+		case l.nucleus != "":
+			if l.geminate && len(syllables) > 0 {
+				syllables[len(syllables)-1] += l.consonant
+			}
+			syllables = append(syllables, l.consonant+l.nucleus)
+		case len(syllables) > 0:
+			syllables[len(syllables)-1] += l.consonant
+		default:
+			return nil, fmt.Errorf("missing vowel points (nikkud) in %q", w.text)
+		}
+	}
+	if len(syllables) == 0 {
+		return nil, fmt.Errorf("missing vowel points (nikkud) in %q", w.text)
+	}
+	return syllables, nil
+}
+
+// readMatres finds the letters that only spell the vowel before them:
+// shuruk, holam male, a yod after hiriq or segol, an alef after a vowel and
+// a final he. A yod after any other vowel closes it as a diphthong.
+func (w word) readMatres() {
+	for i := range w.letters {
+		l := &w.letters[i]
+		shuruk := l.vowel == 0 && l.dagesh
+		holamMale := l.vowel == holam && !l.dagesh
+		if l.base != 'ו' || !shuruk && !holamMale {
+			continue
+		}
+		switch {
+		case i > 0 && w.letters[i-1].vowel == 0:
+			prev := &w.letters[i-1]
+			prev.vowel, prev.male = l.vowel, true
+			if shuruk {
+				prev.vowel = qubuts
+			}
+			l.silent = true
+		case shuruk:
+			// Shuruk starting a word or following a vowel is sung alone
+			l.vowel, l.male, l.dagesh = qubuts, true, false
+			l.consonant, l.fixed = "", true
+		}
+	}
+
+	for i := range w.letters {
+		l := &w.letters[i]
+		prev := w.previous(i)
+		if prev == nil || l.vowel != 0 || l.silent {
+			continue
+		}
+		switch l.base {
+		case 'י':
+			if l.dagesh {
+				continue
+			}
+			switch prev.vowel {
+			case hiriq, segol:
+				prev.male = true
+				l.silent = true
+			case patach, qamats, tsere, holam, qubuts:
+				// The yod of the -av suffix is silent
+				if next := i + 1; next == len(w.letters)-1 && w.letters[next].base == 'ו' && w.letters[next].vowel == 0 {
+					l.silent = true
+				} else {
+					l.glide = true
+				}
+			}
+		case 'ו':
+			// A bare vav after a holam or qubuts written on the letter before
+			if !l.dagesh && (prev.vowel == holam || prev.vowel == qubuts) {
+				prev.male = true
+				l.silent = true
+			}
+		case 'א':
+			l.silent = prev.vowel != 0 && prev.vowel != shva
+		case 'ה':
+			l.silent = i == len(w.letters)-1 && !l.dagesh
+		}
+	}
+}
+
+// readConsonants sets each letter's consonant in a tradition, telling a
+// dagesh lene, which hardens a begadkefat letter, from a dagesh forte, which
+// doubles a letter following a full vowel
+func (w word) readConsonants(t tradition) {
+	for i := range w.letters {
+		l := &w.letters[i]
+		switch {
+		case l.silent, l.fixed:
+			continue
+		case l.glide:
+			l.consonant = "i"
+			continue
+		}
+		sounds := t.consonant(l.base)
+		l.consonant = sounds[0]
+		if l.dagesh {
+			l.consonant = sounds[1]
+			if prev := w.previous(i); prev != nil && prev.vowel != 0 && prev.vowel != shva && l.base != 'ה' {
+				l.geminate = true
+			}
+		}
+		if l.base == 'ש' && l.sin {
+			l.consonant = "s"
+		}
+	}
+}
+
+// readShvas decides for each shva whether it is vocal (na), sung as a short
+// vowel, or silent (nach), closing the syllable before it. A shva is vocal at the start
+// of a word, under a doubled letter, after another silent shva, under the
+// first of two identical letters, and after a long vowel or one marked with
+// a meteg. A qamats closed by a silent shva is qamats qatan, unless the
+// shva is under a yod, as in לָיְלָה.
+func (w word) readShvas() {
+	prevNach := false
+	for i := range w.letters {
+		l := &w.letters[i]
+		if l.silent {
+			continue
+		}
+		prev := w.previous(i)
+		if l.vowel != shva {
+			if l.vowel == hatafQamats && prev != nil && prev.vowel == qamats && !prev.meteg {
+				prev.qatan = true
+			}
+			prevNach = false
+			continue
+		}
+
+		na := false
+		switch {
+		case w.last(i):
+		case prev == nil, l.geminate, prevNach:
+			na = true
+		case w.letters[i+1].base == l.base:
+			na = true
+		case prev.meteg, prev.male, prev.vowel == tsere, prev.vowel == holam:
+			na = true
+		}
+		l.na = na
+		if !na && prev != nil && prev.vowel == qamats && !w.last(i) && l.base != 'י' {
+			prev.qatan = true
+		}
+		prevNach = !na
+	}
+}
+
+// readFinal handles the end of the word: a furtive patach under a final
+// het, ayin or he with mappiq is sung before its consonant, and the closed
+// last syllable of a word joined by a maqaf has qamats qatan
+func (w word) readFinal() {
+	last := len(w.letters) - 1
+	if l := &w.letters[last]; l.vowel == patach && (l.base == 'ח' || l.base == 'ע' || l.base == 'ה' && l.dagesh) {
+		if prev := w.previous(last); prev != nil && prev.sung() {
+			l.furtive = true
+		}
+	}
+
+	if !w.proclitic {
+		return
+	}
+	for i := last; i >= 0; i-- {
+		l := &w.letters[i]
+		if l.silent || !l.sung() {
+			continue
+		}
+		if l.vowel == qamats && !l.meteg && !w.last(i) {
+			l.qatan = true
+		}
+		return
+	}
+}
+
+// readVowels sets the vowel sung on each letter in a tradition. A yod
+// closing a diphthong is dropped after a vowel the tradition already sings
+// as one, so a holam sung "oi" before it is not doubled.
+func (w word) readVowels(t tradition) {
+	for i := range w.letters {
+		l := &w.letters[i]
+		switch {
+		case l.silent:
+		case l.glide:
+			if prev := w.previous(i); prev != nil && strings.HasSuffix(prev.nucleus, "i") {
+				l.silent = true
+			}
+		case l.vowel == shva:
+			if l.na {
+				l.nucleus = t.shvaNa
+			}
+		case l.qatan:
+			l.nucleus = t.vowel(qamatsQatan)
+		case l.vowel == qamats && t.qamatsClosed != "" && w.closed(i):
+			l.nucleus = t.qamatsClosed
+		case l.vowel != 0:
+			l.nucleus = t.vowel(l.vowel)
+		}
+	}
+}
+
+// sung reports whether the letter carries a vowel of its own
+func (l *letter) sung() bool {
+	return l.vowel != 0 && (l.vowel != shva || l.na)
+}
+
+// closed reports whether the syllable opened by letter i ends in a
+// consonant: one without a vowel of its own, or the first half of a
+// doubled letter
+func (w word) closed(i int) bool {
+	for _, l := range w.letters[i+1:] {
+		if l.silent {
+			continue
+		}
+		return l.geminate || !l.sung() && !l.glide
+	}
+	return false
+}
+
+// previous returns the closest letter before i that is pronounced, or nil
+func (w word) previous(i int) *letter {
+	for j := i - 1; j >= 0; j-- {
+		if !w.letters[j].silent {
+			return &w.letters[j]
+		}
+	}
+	return nil
+}
+
+// last reports whether no pronounced letter follows i
+func (w word) last(i int) bool {
+	for _, l := range w.letters[i+1:] {
+		if !l.silent {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package lyrics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHebrewSyllables(t *testing.T) {
+	tests := []struct {
+		name   string
+		hebrew string
+		want   string
+	}{
+		{"Open syllables", "שָׁלוֹם", "Sa lom"},
+		{"Shva na at the start", "בְּטֶרֶם", "b@ te rem"},
+		{"Shva nach closes a syllable", "נִבְרָא", "niv ra"},
+		{"Second of two shvas", "יִשְׁמְרוּ", "jiS m@ ru"},
+		{"Shva under a doubled letter", "הַמְּלָכִים", "ham m@ la Xim"},
+		{"Shva before an identical letter", "הַלְלוּ", "ha l@ lu"},
+		{"Shva after a long vowel", "שׁוֹמְרִים", "So m@ rim"},
+		{"Final shva is silent", "מֶלֶךְ", "me leX"},
+		{"Dagesh lene hardens begadkefat", "כִּכְלוֹת", "kiX lot"},
+		{"Dagesh lene after a silent shva", "מִשְׁפָּט", "miS pat"},
+		{"Dagesh forte doubles", "נִסִּי", "nis si"},
+		{"Dagesh forte in the article", "הַכֹּל", "hak kol"},
+		{"Shin and sin", "שֵׁנִי מִשְׂרָה", "Se ni mis ra"},
+		{"Silent alef and ayin", "אֲדוֹן עוֹלָם", "a don o lam"},
+		{"Hataf vowels", "נַעֲשָׂה אֱמֶת", "na a sa e met"},
+		{"Shuruk starting a word", "וּמָנוֹס", "u ma nos"},
+		{"Hiriq male", "אִירָא", "i ra"},
+		{"Diphthongs", "וְאֵין וְחַי גּוֹי", "v@ ein v@ Xai goi"},
+		{"Silent yod of -av", "עָלָיו", "a lav"},
+		{"Consonantal vav", "הֹוֶה מִצְוֹת", "ho ve mits vot"},
+		{"Furtive patach", "רוּחַ", "ru aX"},
+		{"Furtive patach under mappiq", "גָּבֹהַּ", "ga vo ah"},
+		{"Mappiq he", "הַלְלוּיָהּ", "ha l@ lu jah"},
+		{"Qamats qatan before shva nach", "חָכְמָה", "XoX ma"},
+		{"Qamats qatan before hataf qamats", "צָהֳרַיִם", "tso ho ra jim"},
+		{"Qamats qatan before maqaf", "כָּל־יְצִיר", "kol j@ tsir"},
+		{"Qamats gadol without maqaf", "כָּל", "kal"},
+		{"Explicit qamats qatan", "כׇּל", "kol"},
+		{"Meteg keeps qamats gadol", "שָֽׁמְרָה", "Sa m@ ra"},
+		{"Tetragrammaton", "יְיָ יְהֹוָה", "a do nai a do nai"},
+		{"Cantillation ignored", "אֲדֹנָ֣י", "a do nai"},
+		{"Punctuation ignored", "אָמֵן, אָמֵן׃", "a men a men"},
+		{"Final forms", "אֶרֶץ כָּנָף", "e rets ka naf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HebrewSyllables(tt.hebrew, PronunciationModern)
+			if err != nil {
+				t.Fatalf("HebrewSyllables(%q) error: %v", tt.hebrew, err)
+			}
+			if want := strings.Fields(tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("HebrewSyllables(%q) = %q, want %q", tt.hebrew, got, want)
+			}
+		})
+	}
+}
+
+func TestHebrewSyllables_AdonOlam(t *testing.T) {
+	text, err := Lookup("adon-olam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := HebrewSyllables(text.Lyrics(), PronunciationModern)
+	if err != nil {
+		t.Fatalf("HebrewSyllables error: %v", err)
+	}
+	want := strings.Fields(`a don o lam a Ser ma laX b@ te rem kol j@ tsir niv ra
+		l@ et na a sa v@ Xef tso kol a zai me leX S@ mo nik ra
+		v@ a Xa rei kiX lot hak kol l@ vad do jim loX no ra
+		v@ hu ha ja v@ hu ho ve v@ hu jih je b@ tif a ra
+		v@ hu e Xad v@ ein Se ni l@ ham Sil lo l@ haX bi ra
+		b@ li re Sit b@ li taX lit v@ lo ha oz v@ ham mis ra
+		v@ hu e li v@ Xai go a li v@ tsur Xev li b@ et tsa ra
+		v@ hu nis si u ma nos li m@ nat ko si b@ jom ek ra
+		b@ ja do af kid ru Xi b@ et i San v@ a i ra
+		v@ im ru Xi g@ vij ja ti a do nai li v@ lo i ra`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HebrewSyllables = %q\nwant %q", got, want)
+	}
+}
+
+func TestHebrewSyllables_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		hebrew  string
+		wantErr string
+	}{
+		{"Unpointed", "אדון עולם", `missing vowel points (nikkud) in "אדון"`},
+		{"Latin letters", "שָׁלוֹם adon", `unexpected 'a' in Hebrew word "adon"`},
+		{"Point without a letter", "ָשׁ", "without a letter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := HebrewSyllables(tt.hebrew, PronunciationModern)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("HebrewSyllables(%q) error = %v, want it to contain %q", tt.hebrew, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package lyrics
+
+import (
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+)
+
+// FormatHebrew names lyrics written in pointed Hebrew script
+const FormatHebrew = "hebrew"
+
+// Parse reads lyrics into the X-SAMPA syllables fonspeak sings. Pointed
+// Hebrew is transliterated by HebrewSyllables in the pronunciation; anything
+// else is read as IPA or X-SAMPA by fonspeak_midi.IPAToXSAMPA, which are
+// already written as they are to be sung.
+func Parse(text string, pronunciation Pronunciation) ([]string, error) {
+	if IsHebrew(text) {
+		return HebrewSyllables(text, pronunciation)
+	}
+	return fonspeak_midi.IPAToXSAMPA(text)
+}
+
+// Format names the form Parse reads text in: FormatHebrew, or the phonetic
+// alphabet fonspeak_midi.DetectAlphabet finds
+func Format(text string) string {
+	if IsHebrew(text) {
+		return FormatHebrew
+	}
+	return string(fonspeak_midi.DetectAlphabet(text))
+}
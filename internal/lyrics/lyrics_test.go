@@ -0,0 +1,31 @@
+package lyrics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text       string
+		want       string
+		wantFormat string
+	}{
+		{"a don o l@m", "a don o l@m", "x-sampa"},
+		{"a don o ləm", "a don o l@m", "ipa"},
+		{"אֲדוֹן עוֹלָם", "a don o lam", FormatHebrew},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.text, PronunciationModern)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", tt.text, err)
+		}
+		if want := strings.Fields(tt.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(%q) = %q, want %q", tt.text, got, want)
+		}
+		if format := Format(tt.text); format != tt.wantFormat {
+			t.Errorf("Format(%q) = %q, want %q", tt.text, format, tt.wantFormat)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package lyrics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pronunciation is a tradition of reading Hebrew, which sets the sounds of
+// its letters and vowels and the voice that sings them
+type Pronunciation string
+
+const (
+	// PronunciationModern is Modern Israeli Hebrew (the default)
+	PronunciationModern Pronunciation = "modern"
+	// PronunciationAshkenazi reads qamats as "o", holam as "oi", tsere as
+	// "ei" and tav without a dagesh as "s"
+	PronunciationAshkenazi Pronunciation = "ashkenazi"
+	// PronunciationLithuanian is Ashkenazi with holam read as "ei"
+	PronunciationLithuanian Pronunciation = "ashkenazi-lithuanian"
+	// PronunciationPolish is Ashkenazi with qamats read as "u" in open
+	// syllables, as in Poland and Galicia
+	PronunciationPolish Pronunciation = "ashkenazi-polish"
+	// PronunciationGerman is Ashkenazi with holam read as "au", as in
+	// Germany and the Netherlands
+	PronunciationGerman Pronunciation = "ashkenazi-german"
+	// PronunciationSephardi keeps the pharyngeal het and ayin and reads a
+	// vocal shva as "e"
+	PronunciationSephardi Pronunciation = "sephardi"
+	// PronunciationYemenite keeps a sound for every begadkefat letter with
+	// and without a dagesh, reads qof as "g", vav as "w", holam as "2" (ø) and
+	// a vocal shva as "a", with the pharyngeal het and ayin
+	PronunciationYemenite Pronunciation = "yemenite"
+)
+
+// Pronunciations lists every pronunciation, the default first
+var Pronunciations = []Pronunciation{
+	PronunciationModern,
+	PronunciationAshkenazi,
+	PronunciationLithuanian,
+	PronunciationPolish,
+	PronunciationGerman,
+	PronunciationSephardi,
+	PronunciationYemenite,
+}
+
+// ParsePronunciation converts a pronunciation name into a Pronunciation
+// An empty name selects the default modern pronunciation
+func ParsePronunciation(name string) (Pronunciation, error) {
+	if name == "" {
+		return PronunciationModern, nil
+	}
+	for _, p := range Pronunciations {
+		if Pronunciation(name) == p {
+			return p, nil
+		}
+	}
+	names := make([]string, len(Pronunciations))
+	for i, p := range Pronunciations {
+		names[i] = fmt.Sprintf("'%s'", p)
+	}
+	return "", fmt.Errorf("invalid pronunciation: %s (must be %s or %s)", name, strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+}
+
+// Voice returns the espeak-ng voice whose phonemes best fit the
+// pronunciation, used unless another voice is chosen
+func (p Pronunciation) Voice() string {
+	return p.tradition().voice
+}
+
+// tradition holds how a pronunciation differs from the modern one
+type tradition struct {
+	pronunciation Pronunciation
+	voice         string
+	consonants    map[rune][2]string // sounds without and with a dagesh
+	vowels        map[rune]string
+	qamatsClosed  string // qamats gadol in a closed syllable, if it differs
+	shvaNa        string
+}
+
+// consonant returns the sounds of a letter without and with a dagesh
+func (t tradition) consonant(r rune) [2]string {
+	if sounds, ok := t.consonants[r]; ok {
+		return sounds
+	}
+	return consonants[r]
+}
+
+// vowel returns the sound of a vowel point
+func (t tradition) vowel(r rune) string {
+	if sound, ok := t.vowels[r]; ok {
+		return sound
+	}
+	return vowelSounds[r]
+}
+
+// ashkenazi builds an Ashkenazi tradition with its own holam and qamats
+func ashkenazi(p Pronunciation, holamSound, qamatsOpen string) tradition {
+	return tradition{
+		pronunciation: p,
+		voice:         "de",
+		consonants:    map[rune][2]string{'ת': {"s", "t"}},
+		vowels: map[rune]string{
+			qamats:     qamatsOpen,
+			tsere:      "ei",
+			holam:      holamSound,
+			holamHaser: holamSound,
+		},
+		qamatsClosed: "o",
+		shvaNa:       "@",
+	}
+}
+
+// traditions holds every pronunciation but the modern one
+var traditions = map[Pronunciation]tradition{
+	PronunciationAshkenazi:  ashkenazi(PronunciationAshkenazi, "oi", "o"),
+	PronunciationLithuanian: ashkenazi(PronunciationLithuanian, "ei", "o"),
+	PronunciationPolish:     ashkenazi(PronunciationPolish, "oi", "u"),
+	PronunciationGerman:     ashkenazi(PronunciationGerman, "au", "o"),
+	PronunciationSephardi: {
+		pronunciation: PronunciationSephardi,
+		voice:         "he",
+		consonants: map[rune][2]string{
+			'ח': {`X\`, `X\`},
+			'ע': {`?\`, `?\`},
+		},
+		shvaNa: "e",
+	},
+	PronunciationYemenite: {
+		pronunciation: PronunciationYemenite,
+		voice:         "ar",
+		consonants: map[rune][2]string{
+			'ג': {"G", "dZ"},
+			'ד': {"D", "d"},
+			'ו': {"w", "w"},
+			'ח': {`X\`, `X\`},
+			'ע': {`?\`, `?\`},
+			'ק': {"g", "g"},
+			'ת': {"T", "t"},
+		},
+		vowels: map[rune]string{
+			qamats:     "o",
+			holam:      "2",
+			holamHaser: "2",
+		},
+		shvaNa: "a",
+	},
+}
+
+// tradition returns the sounds of the pronunciation, falling back to the
+// modern ones
+func (p Pronunciation) tradition() tradition {
+	if t, ok := traditions[p]; ok {
+		return t
+	}
+	return tradition{pronunciation: PronunciationModern, voice: "he", shvaNa: "@"}
+}
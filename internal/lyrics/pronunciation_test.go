@@ -0,0 +1,104 @@
+package lyrics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePronunciation(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Pronunciation
+		wantErr bool
+	}{
+		{"", PronunciationModern, false},
+		{"modern", PronunciationModern, false},
+		{"ashkenazi", PronunciationAshkenazi, false},
+		{"ashkenazi-lithuanian", PronunciationLithuanian, false},
+		{"ashkenazi-polish", PronunciationPolish, false},
+		{"ashkenazi-german", PronunciationGerman, false},
+		{"sephardi", PronunciationSephardi, false},
+		{"yemenite", PronunciationYemenite, false},
+		{"Ashkenazi", "", true},
+		{"litvish", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePronunciation(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePronunciation(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParsePronunciation(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPronunciation_Voice(t *testing.T) {
+	tests := []struct {
+		pronunciation Pronunciation
+		want          string
+	}{
+		{"", "he"},
+		{PronunciationModern, "he"},
+		{PronunciationAshkenazi, "de"},
+		{PronunciationPolish, "de"},
+		{PronunciationSephardi, "he"},
+		{PronunciationYemenite, "ar"},
+	}
+	for _, tt := range tests {
+		if got := tt.pronunciation.Voice(); got != tt.want {
+			t.Errorf("%q.Voice() = %q, want %q", tt.pronunciation, got, tt.want)
+		}
+	}
+}
+
+func TestHebrewSyllables_Pronunciations(t *testing.T) {
+	// Words with a tav without dagesh, holam, qamats gadol and qatan, ayin
+	// and het, and the divine name
+	const text = "רֵאשִׁית אֲדוֹן עוֹלָם גֹּאֲלִי אָז חָכְמָה יְיָ"
+	tests := []struct {
+		pronunciation Pronunciation
+		want          string
+	}{
+		{PronunciationModern, "re Sit a don o lam go a li az XoX ma a do nai"},
+		{PronunciationAshkenazi, "rei Sis a doin oi lom goi a li oz XoX mo a doi noi"},
+		{PronunciationLithuanian, "rei Sis a dein ei lom gei a li oz XoX mo a dei noi"},
+		{PronunciationPolish, "rei Sis a doin oi lom goi a li oz XoX mu a doi nui"},
+		{PronunciationGerman, "rei Sis a daun au lom gau a li oz XoX mo a dau noi"},
+		{PronunciationSephardi, `re Sit a don ?\o lam go a li az X\oX ma a do nai`},
+		{PronunciationYemenite, `re SiT a D2n ?\2 lom dZ2 a li oz X\oX mo a D2 noi`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.pronunciation), func(t *testing.T) {
+			got, err := HebrewSyllables(text, tt.pronunciation)
+			if err != nil {
+				t.Fatalf("HebrewSyllables error: %v", err)
+			}
+			if want := strings.Fields(tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("HebrewSyllables(%q) = %q, want %q", tt.pronunciation, got, want)
+			}
+		})
+	}
+}
+
+func TestHebrewSyllables_VocalShva(t *testing.T) {
+	tests := []struct {
+		pronunciation Pronunciation
+		want          string
+	}{
+		{PronunciationModern, "b@ te rem"},
+		{PronunciationSephardi, "be te rem"},
+		{PronunciationYemenite, "ba te rem"},
+	}
+	for _, tt := range tests {
+		got, err := HebrewSyllables("בְּטֶרֶם", tt.pronunciation)
+		if err != nil {
+			t.Fatalf("HebrewSyllables error: %v", err)
+		}
+		if want := strings.Fields(tt.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("HebrewSyllables(%q) = %q, want %q", tt.pronunciation, got, want)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+	"github.com/sammyshear/adon-olam/internal/synth"
+	"github.com/sammyshear/adon-olam/internal/timing"
+	"github.com/sammyshear/adon-olam/internal/wav"
+)
+
+// Defaults applied to zero-valued RenderRequest fields
+const (
+	DefaultVoice = "he"
+	DefaultMaxHz = 500.0
+)
+
+// Stage identifies a step of the render pipeline
+type Stage string
+
+const (
+	StageValidate   Stage = "validating"
+	StageParse      Stage = "parsing"
+	StageAlign      Stage = "aligning"
+	StageTiming     Stage = "timing"
+	StageSynthesize Stage = "synthesizing"
+)
+
+// Sentinel errors wrapped in *Error by Render
+var (
+	ErrNoMIDI         = errors.New("no MIDI input provided")
+	ErrNoSyllables    = errors.New("no syllables to sing")
+	ErrNoNotes        = errors.New("no notes found in the specified track")
+	ErrNoSynthesizer  = errors.New("no synthesizer configured")
+	ErrInvalidMaxHz   = errors.New("maximum frequency must be positive")
+	ErrInvalidTempo   = errors.New("tempo scale must be positive")
+	ErrInvalidChannel = errors.New("channel must be between 1 and 16, or 0 for all")
+)
+
+// Error reports the stage a render failed in
+type Error struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Hooks are optional callbacks invoked as a render progresses
+type Hooks struct {
+	// OnStageStart is called before each stage runs
+	OnStageStart func(stage Stage)
+	// OnStageDone is called after each stage completes successfully
+	OnStageDone func(stage Stage, elapsed time.Duration)
+	// OnProgress is called as a stage works through its items, currently
+	// the syllables sung during synthesis
+	OnProgress func(stage Stage, done, total int)
+}
+
+// RenderRequest holds everything needed to sing syllables to a MIDI melody
+type RenderRequest struct {
+	MIDI           io.Reader                    // Standard MIDI file, MusicXML or .mxl score or ABC tune to read the melody from
+	TrackNo        int                          // MIDI track number holding the melody, fonspeak_midi.AutoTrack to detect it or AllTracks
+	Channel        int                          // 1-based MIDI channel of the melody, 0 for all channels
+	Syllables      []string                     // X-SAMPA syllables to sing
+	Voice          string                       // Synthesis voice, DefaultVoice if empty
+	MaxHz          float64                      // Pitch cap for the global octave drop, DefaultMaxHz if zero
+	TempoScale     float64                      // Playback speed relative to the MIDI tempo map, 1 if zero
+	ChordReduction fonspeak_midi.ChordReduction // How chords collapse to one note, lowest within 10ms if zero
+	TimingStrategy timing.TimingStrategy        // Phoneme timing strategy, per-syllable if empty
+	Alignment      fonspeak_midi.AlignmentMode  // How syllables are assigned to notes, even if empty
+	Dynamics       synth.DynamicsCurve          // How note velocity, volume and expression set the gain, off if empty
+	Synthesizer    synth.Synthesizer            // Backend that renders the plan to audio
+	Hooks          Hooks                        // Optional progress callbacks
+}
+
+// RenderResult is the output of a successful render along with what was
+// decided along the way
+type RenderResult struct {
+	TrackNo      int                           // Track the melody was taken from
+	Channel      int                           // 1-based channel the melody was taken from, 0 for all
+	TrackChoice  *fonspeak_midi.TrackCandidate // Why TrackNo was chosen, if it was detected
+	Notes        []fonspeak_midi.Note          // Melody extracted from the MIDI file, including rests
+	Warnings     []fonspeak_midi.Warning       // Note events of the melody's track that could not be used
+	MaxFrequency float64                       // Highest pitch in the melody before transposition, in Hz
+	OctaveDrop   int                           // Octaves the melody was transposed down by
+	Repeated     bool                          // Whether the melody was repeated to cover all syllables
+	Alignment    fonspeak_midi.AlignmentMode   // Alignment used, even if lyrics were asked for but the melody has none
+	Plan         synth.Plan                    // Aligned note/syllable/phoneme plan handed to the synthesizer
+	Audio        *wav.Audio                    // Rendered audio
+	Timings      map[Stage]time.Duration       // Wall-clock time spent in each stage
+}
+
+// Render runs extraction, octave capping, syllable alignment, duration
+// allocation and synthesis. Failures are returned as *Error; if ctx is
+// cancelled or times out the error wraps ctx.Err().
+func Render(ctx context.Context, req RenderRequest) (*RenderResult, error) {
+	result := &RenderResult{Timings: map[Stage]time.Duration{}}
+
+	err := runStage(ctx, req.Hooks, result, StageValidate, func() error {
+		return req.validate()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	voice := req.Voice
+	if voice == "" {
+		voice = DefaultVoice
+	}
+	maxHz := req.MaxHz
+	if maxHz == 0 {
+		maxHz = DefaultMaxHz
+	}
+	strategy := req.TimingStrategy
+	if strategy == "" {
+		strategy = timing.PerSyllable
+	}
+	tempoScale := req.TempoScale
+	if tempoScale == 0 {
+		tempoScale = 1
+	}
+
+	// Extract the melody and work out the global octave drop
+	err = runStage(ctx, req.Hooks, result, StageParse, func() error {
+		score, err := fonspeak_midi.ReadScore(ctx, req.MIDI)
+		if err != nil {
+			return err
+		}
+
+		result.TrackNo, result.Channel = req.TrackNo, req.Channel
+		if req.TrackNo == fonspeak_midi.AutoTrack {
+			choice, err := score.DetectMelodyTrack(len(req.Syllables), req.Channel)
+			if err != nil {
+				return err
+			}
+			result.TrackNo, result.Channel = choice.Track, choice.Channel
+			result.TrackChoice = &choice
+		}
+
+		notes, err := score.ChannelMelody(result.TrackNo, result.Channel, req.ChordReduction)
+		if err != nil {
+			return err
+		}
+		result.Warnings = score.SelectWarnings(result.TrackNo, result.Channel)
+		if fonspeak_midi.CountPitchedNotes(notes) == 0 {
+			return ErrNoNotes
+		}
+		if tempoScale != 1 {
+			notes = fonspeak_midi.ScaleTempo(notes, tempoScale)
+		}
+
+		result.Notes = notes
+		result.MaxFrequency = fonspeak_midi.FindMaxFrequency(notes)
+		result.OctaveDrop = fonspeak_midi.ComputeGlobalOctaveDropFromHz(result.MaxFrequency, maxHz)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Align syllables to melody with vowel extension
+	// If more syllables than notes, repeat melody
+	// If more notes than syllables, distribute syllables evenly and extend vowels only
+	// Rests never carry a syllable, so only pitched notes are counted
+	// In lyrics mode the MIDI file's own lyrics place the syllables instead
+	var alignedNotes []fonspeak_midi.Note
+	var alignedSyllables []string
+	err = runStage(ctx, req.Hooks, result, StageAlign, func() error {
+		result.Alignment = fonspeak_midi.AlignEven
+		if req.Alignment == fonspeak_midi.AlignLyrics && fonspeak_midi.HasLyrics(result.Notes) {
+			result.Alignment = fonspeak_midi.AlignLyrics
+			alignedNotes = fonspeak_midi.RepeatMelodyToCoverLyrics(result.Notes, len(req.Syllables))
+			alignedSyllables = fonspeak_midi.AlignSyllablesToLyrics(req.Syllables, alignedNotes)
+			result.Repeated = len(alignedNotes) != len(result.Notes)
+			return nil
+		}
+
+		pitchedCount := fonspeak_midi.CountPitchedNotes(result.Notes)
+		if len(req.Syllables) > pitchedCount {
+			alignedNotes = fonspeak_midi.RepeatMelodyToCoverSyllables(result.Notes, len(req.Syllables))
+			alignedSyllables = req.Syllables
+			result.Repeated = true
+		} else {
+			alignedNotes = result.Notes
+			alignedSyllables = fonspeak_midi.AlignSyllablesToMelody(req.Syllables, pitchedCount)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Allocate phoneme durations and build the synthesis plan
+	err = runStage(ctx, req.Hooks, result, StageTiming, func() error {
+		timingOpts := timing.DefaultTimingOptions()
+		timingOpts.Strategy = strategy
+
+		notesWithSyllables := timing.PrepareNotesWithSyllables(alignedNotes, alignedSyllables)
+		notesWithSyllables = timing.AllocateDurations(notesWithSyllables, timingOpts)
+
+		result.Plan = synth.NewPlan(notesWithSyllables, result.OctaveDrop, voice).WithDynamics(req.Dynamics)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = runStage(ctx, req.Hooks, result, StageSynthesize, func() error {
+		plan := result.Plan
+		if req.Hooks.OnProgress != nil {
+			plan.OnProgress = func(done, total int) {
+				req.Hooks.OnProgress(StageSynthesize, done, total)
+			}
+		}
+
+		audio, err := req.Synthesizer.Synthesize(ctx, plan)
+		if err != nil {
+			return err
+		}
+		result.Audio = audio
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// validate checks the request for missing or out of range fields
+func (req RenderRequest) validate() error {
+	if req.MIDI == nil {
+		return ErrNoMIDI
+	}
+	if len(req.Syllables) == 0 {
+		return ErrNoSyllables
+	}
+	if req.MaxHz < 0 {
+		return ErrInvalidMaxHz
+	}
+	if req.TempoScale < 0 {
+		return ErrInvalidTempo
+	}
+	if req.Channel < 0 || req.Channel > 16 {
+		return ErrInvalidChannel
+	}
+	if req.Synthesizer == nil {
+		return ErrNoSynthesizer
+	}
+	if _, err := timing.ParseTimingStrategy(string(req.TimingStrategy)); err != nil {
+		return err
+	}
+	if _, err := fonspeak_midi.ParseAlignmentMode(string(req.Alignment)); err != nil {
+		return err
+	}
+	if _, err := synth.ParseDynamicsCurve(string(req.Dynamics)); err != nil {
+		return err
+	}
+	if err := req.ChordReduction.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// runStage runs fn as the given stage, firing hooks, recording its duration
+// and wrapping any failure in *Error. Nothing runs once ctx is done.
+func runStage(ctx context.Context, hooks Hooks, result *RenderResult, stage Stage, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return &Error{Stage: stage, Err: err}
+	}
+
+	if hooks.OnStageStart != nil {
+		hooks.OnStageStart(stage)
+	}
+
+	start := time.Now()
+	if err := fn(); err != nil {
+		return &Error{Stage: stage, Err: err}
+	}
+	elapsed := time.Since(start)
+	result.Timings[stage] = elapsed
+
+	if hooks.OnStageDone != nil {
+		hooks.OnStageDone(stage, elapsed)
+	}
+	return nil
+}
@@ -0,0 +1,407 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+	"github.com/sammyshear/adon-olam/internal/synth"
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// scaleMIDI returns a single-track SMF with the given keys as consecutive
+// quarter notes (0.5s each at the default 120 BPM)
+func scaleMIDI(t *testing.T, keys ...uint8) *bytes.Reader {
+	t.Helper()
+
+	var track smf.Track
+	for _, key := range keys {
+		track.Add(0, midi.NoteOn(0, key, 100))
+		track.Add(960, midi.NoteOff(0, key))
+	}
+	track.Close(0)
+	return writeSMF(t, track)
+}
+
+// chordMIDI returns a single-track SMF with the given keys sounding together
+// for a quarter note
+func chordMIDI(t *testing.T, keys ...uint8) *bytes.Reader {
+	t.Helper()
+
+	var track smf.Track
+	for _, key := range keys {
+		track.Add(0, midi.NoteOn(0, key, 100))
+	}
+	for i, key := range keys {
+		var delta uint32
+		if i == 0 {
+			delta = 960
+		}
+		track.Add(delta, midi.NoteOff(0, key))
+	}
+	track.Close(0)
+	return writeSMF(t, track)
+}
+
+func writeSMF(t *testing.T, track smf.Track) *bytes.Reader {
+	t.Helper()
+
+	s := smf.New()
+	if err := s.Add(track); err != nil {
+		t.Fatalf("failed to add track: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatalf("failed to write SMF: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestRender_SineBackend(t *testing.T) {
+	var started []Stage
+	req := RenderRequest{
+		MIDI:        scaleMIDI(t, 60, 62, 64, 65),
+		Syllables:   []string{"a", "don", "o", "l@m", "aS", "er"},
+		Synthesizer: synth.NewSine(),
+		Hooks: Hooks{
+			OnStageStart: func(stage Stage) { started = append(started, stage) },
+		},
+	}
+
+	result, err := Render(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+
+	wantStages := []Stage{StageValidate, StageParse, StageAlign, StageTiming, StageSynthesize}
+	if len(started) != len(wantStages) {
+		t.Fatalf("stages started = %v, want %v", started, wantStages)
+	}
+	for i, stage := range wantStages {
+		if started[i] != stage {
+			t.Errorf("stage %d = %s, want %s", i, started[i], stage)
+		}
+		if _, ok := result.Timings[stage]; !ok {
+			t.Errorf("no timing recorded for stage %s", stage)
+		}
+	}
+
+	// Six syllables over four notes repeats the melody
+	if !result.Repeated || len(result.Plan.Events) != 6 {
+		t.Errorf("Repeated = %v with %d events, want repeated melody of 6 events", result.Repeated, len(result.Plan.Events))
+	}
+	if result.Plan.Voice != DefaultVoice {
+		t.Errorf("voice = %q, want default %q", result.Plan.Voice, DefaultVoice)
+	}
+	if math.Abs(result.Audio.Duration()-3.0) > 0.01 {
+		t.Errorf("audio duration = %.3f, want 3.0", result.Audio.Duration())
+	}
+}
+
+func TestRender_OctaveDrop(t *testing.T) {
+	result, err := Render(context.Background(), RenderRequest{
+		MIDI:        scaleMIDI(t, 84), // C6, ~1046 Hz
+		Syllables:   []string{"a"},
+		MaxHz:       500,
+		Synthesizer: synth.NewSine(),
+	})
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+
+	if result.OctaveDrop != 2 {
+		t.Errorf("OctaveDrop = %d, want 2", result.OctaveDrop)
+	}
+}
+
+func TestRender_TempoScale(t *testing.T) {
+	result, err := Render(context.Background(), RenderRequest{
+		MIDI:        scaleMIDI(t, 60, 62),
+		Syllables:   []string{"a", "don"},
+		TempoScale:  2,
+		Synthesizer: synth.NewSine(),
+	})
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+
+	if math.Abs(result.Audio.Duration()-0.5) > 0.01 {
+		t.Errorf("audio duration = %.3f, want 0.5 at double speed", result.Audio.Duration())
+	}
+}
+
+func TestRender_AutoTrack(t *testing.T) {
+	result, err := Render(context.Background(), RenderRequest{
+		MIDI:        scaleMIDI(t, 60, 62, 64),
+		TrackNo:     fonspeak_midi.AutoTrack,
+		Syllables:   []string{"a", "don", "o"},
+		Synthesizer: synth.NewSine(),
+	})
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+
+	if result.TrackNo != 0 || result.TrackChoice == nil || result.TrackChoice.Track != 0 {
+		t.Errorf("TrackNo = %d, TrackChoice = %v, want detected track 0", result.TrackNo, result.TrackChoice)
+	}
+
+	// A channel limits the detection to that channel
+	result, err = Render(context.Background(), RenderRequest{
+		MIDI:        scaleMIDI(t, 60, 62, 64),
+		TrackNo:     fonspeak_midi.AutoTrack,
+		Channel:     1,
+		Syllables:   []string{"a", "don", "o"},
+		Synthesizer: synth.NewSine(),
+	})
+	if err != nil {
+		t.Fatalf("Render() on channel 1 error: %v", err)
+	}
+	if result.Channel != 1 || result.TrackChoice == nil || result.TrackChoice.Channel != 1 {
+		t.Errorf("Channel = %d, TrackChoice = %v, want channel 1 kept", result.Channel, result.TrackChoice)
+	}
+}
+
+func TestRender_ChordReduction(t *testing.T) {
+	result, err := Render(context.Background(), RenderRequest{
+		MIDI:           chordMIDI(t, 48, 55, 64),
+		Syllables:      []string{"a"},
+		ChordReduction: fonspeak_midi.ChordReduction{Policy: fonspeak_midi.ChordHighest},
+		Synthesizer:    synth.NewSine(),
+	})
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+
+	if got := result.Notes[0].MIDINote; got != 64 {
+		t.Errorf("melody note = %d, want the highest note 64", got)
+	}
+}
+
+func TestRender_LyricsAlignment(t *testing.T) {
+	var track smf.Track
+	for i, lyric := range []string{"A-", "_", "don"} {
+		track.Add(0, smf.MetaLyric(lyric))
+		track.Add(0, midi.NoteOn(0, uint8(60+i), 100))
+		track.Add(960, midi.NoteOff(0, uint8(60+i)))
+	}
+	track.Close(0)
+
+	result, err := Render(context.Background(), RenderRequest{
+		MIDI:        writeSMF(t, track),
+		Syllables:   []string{"a", "don", "o", "lam"},
+		Alignment:   fonspeak_midi.AlignLyrics,
+		Synthesizer: synth.NewSine(),
+	})
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	if result.Alignment != fonspeak_midi.AlignLyrics {
+		t.Errorf("Alignment = %q, want lyrics", result.Alignment)
+	}
+	// Two lyric syllables per pass, so four syllables take two whole passes
+	if !result.Repeated || len(result.Plan.Events) != 6 {
+		t.Errorf("Repeated = %v with %d events, want two passes of 3 notes", result.Repeated, len(result.Plan.Events))
+	}
+
+	// Without lyric events the syllables are spread evenly
+	result, err = Render(context.Background(), RenderRequest{
+		MIDI:        scaleMIDI(t, 60, 62, 64),
+		Syllables:   []string{"a"},
+		Alignment:   fonspeak_midi.AlignLyrics,
+		Synthesizer: synth.NewSine(),
+	})
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	if result.Alignment != fonspeak_midi.AlignEven {
+		t.Errorf("Alignment = %q, want the even fallback", result.Alignment)
+	}
+}
+
+func TestRender_Dynamics(t *testing.T) {
+	var track smf.Track
+	track.Add(0, midi.NoteOn(0, 60, 127))
+	track.Add(960, midi.NoteOff(0, 60))
+	track.Add(0, midi.ControlChange(0, 11, 64))
+	track.Add(0, midi.NoteOn(0, 62, 127))
+	track.Add(960, midi.NoteOff(0, 62))
+	track.Close(0)
+
+	result, err := Render(context.Background(), RenderRequest{
+		MIDI:        writeSMF(t, track),
+		Syllables:   []string{"a", "don"},
+		Dynamics:    synth.DynamicsGM,
+		Synthesizer: synth.NewSine(),
+	})
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+	first, second := result.Plan.Events[0].Gain, result.Plan.Events[1].Gain
+	if math.Abs(second/first-(64.0/127)*(64.0/127)) > 1e-9 {
+		t.Errorf("gains = %g, %g, want the second lowered by its expression", first, second)
+	}
+}
+
+func TestRender_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       func() RenderRequest
+		wantStage Stage
+		wantErr   error
+	}{
+		{
+			name: "no syllables",
+			req: func() RenderRequest {
+				return RenderRequest{MIDI: scaleMIDI(t, 60), Synthesizer: synth.NewSine()}
+			},
+			wantStage: StageValidate,
+			wantErr:   ErrNoSyllables,
+		},
+		{
+			name: "no synthesizer",
+			req: func() RenderRequest {
+				return RenderRequest{MIDI: scaleMIDI(t, 60), Syllables: []string{"a"}}
+			},
+			wantStage: StageValidate,
+			wantErr:   ErrNoSynthesizer,
+		},
+		{
+			name: "invalid timing strategy",
+			req: func() RenderRequest {
+				return RenderRequest{MIDI: scaleMIDI(t, 60), Syllables: []string{"a"}, Synthesizer: synth.NewSine(), TimingStrategy: "fastest"}
+			},
+			wantStage: StageValidate,
+		},
+		{
+			name: "invalid channel",
+			req: func() RenderRequest {
+				return RenderRequest{MIDI: scaleMIDI(t, 60), Syllables: []string{"a"}, Synthesizer: synth.NewSine(), Channel: 17}
+			},
+			wantStage: StageValidate,
+			wantErr:   ErrInvalidChannel,
+		},
+		{
+			name: "empty channel",
+			req: func() RenderRequest {
+				return RenderRequest{MIDI: scaleMIDI(t, 60), Syllables: []string{"a"}, Synthesizer: synth.NewSine(), Channel: 2}
+			},
+			wantStage: StageParse,
+		},
+		{
+			name: "invalid chord policy",
+			req: func() RenderRequest {
+				return RenderRequest{MIDI: scaleMIDI(t, 60), Syllables: []string{"a"}, Synthesizer: synth.NewSine(), ChordReduction: fonspeak_midi.ChordReduction{Policy: "median"}}
+			},
+			wantStage: StageValidate,
+		},
+		{
+			name: "invalid alignment",
+			req: func() RenderRequest {
+				return RenderRequest{MIDI: scaleMIDI(t, 60), Syllables: []string{"a"}, Synthesizer: synth.NewSine(), Alignment: "karaoke"}
+			},
+			wantStage: StageValidate,
+		},
+		{
+			name: "invalid dynamics curve",
+			req: func() RenderRequest {
+				return RenderRequest{MIDI: scaleMIDI(t, 60), Syllables: []string{"a"}, Synthesizer: synth.NewSine(), Dynamics: "loud"}
+			},
+			wantStage: StageValidate,
+		},
+		{
+			name: "negative tempo scale",
+			req: func() RenderRequest {
+				return RenderRequest{MIDI: scaleMIDI(t, 60), Syllables: []string{"a"}, Synthesizer: synth.NewSine(), TempoScale: -1}
+			},
+			wantStage: StageValidate,
+			wantErr:   ErrInvalidTempo,
+		},
+		{
+			name: "missing track",
+			req: func() RenderRequest {
+				return RenderRequest{MIDI: scaleMIDI(t, 60), TrackNo: 3, Syllables: []string{"a"}, Synthesizer: synth.NewSine()}
+			},
+			wantStage: StageParse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Render(context.Background(), tt.req())
+
+			var renderErr *Error
+			if !errors.As(err, &renderErr) {
+				t.Fatalf("Render() error = %v, want *Error", err)
+			}
+			if renderErr.Stage != tt.wantStage {
+				t.Errorf("failed in stage %s, want %s", renderErr.Stage, tt.wantStage)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRender_StageDoneHook(t *testing.T) {
+	done := map[Stage]time.Duration{}
+	_, err := Render(context.Background(), RenderRequest{
+		MIDI:        scaleMIDI(t, 60, 62),
+		Syllables:   []string{"a", "don"},
+		Synthesizer: synth.NewSine(),
+		Hooks: Hooks{
+			OnStageDone: func(stage Stage, elapsed time.Duration) { done[stage] = elapsed },
+		},
+	})
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+
+	if _, ok := done[StageSynthesize]; !ok {
+		t.Error("OnStageDone was not called for the synthesize stage")
+	}
+}
+
+func TestRender_ProgressHook(t *testing.T) {
+	var last [2]int
+	_, err := Render(context.Background(), RenderRequest{
+		MIDI:        scaleMIDI(t, 60, 62, 64),
+		Syllables:   []string{"a", "don", "o"},
+		Synthesizer: synth.NewSine(),
+		Hooks: Hooks{
+			OnProgress: func(stage Stage, done, total int) {
+				if stage != StageSynthesize {
+					t.Errorf("progress reported for stage %s, want %s", stage, StageSynthesize)
+				}
+				last = [2]int{done, total}
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Render() error: %v", err)
+	}
+
+	if last != [2]int{3, 3} {
+		t.Errorf("final progress = %d/%d, want 3/3", last[0], last[1])
+	}
+}
+
+func TestRender_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Render(ctx, RenderRequest{
+		MIDI:        scaleMIDI(t, 60),
+		Syllables:   []string{"a"},
+		Synthesizer: synth.NewSine(),
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Render() error = %v, want context.Canceled", err)
+	}
+}
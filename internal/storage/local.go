@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MediaPrefix is the URL path LocalStore objects are served under
+const MediaPrefix = "/media/"
+
+// ErrInvalidKey is returned for keys that would escape the store's directory
+var ErrInvalidKey = errors.New("invalid object key")
+
+// LocalStore keeps objects as files in a directory, for running on a single
+// box or in tests without outside services. Handler serves the files.
+type LocalStore struct {
+	dir string
+}
+
+// NewLocal returns a store writing to dir, creating it if needed
+func NewLocal(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create media directory: %w", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// path returns the file an object is stored in
+func (s *LocalStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean != "/"+key || strings.HasSuffix(key, "/") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+// Put implements ObjectStore. The object is written to a temporary file and
+// renamed into place so readers never see a partial file.
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// Exists implements ObjectStore
+func (s *LocalStore) Exists(ctx context.Context, key string) (bool, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// URL implements ObjectStore with a path under MediaPrefix that never expires
+func (s *LocalStore) URL(ctx context.Context, key string) (Link, error) {
+	if _, err := s.path(key); err != nil {
+		return Link{}, err
+	}
+	return Link{URL: MediaPrefix + (&url.URL{Path: key}).EscapedPath()}, nil
+}
+
+// Handler serves the stored objects, to be mounted at MediaPrefix.
+// Directories are not listed, so objects can only be fetched by key.
+func (s *LocalStore) Handler() http.Handler {
+	return http.StripPrefix(MediaPrefix, http.FileServer(filesOnly{http.Dir(s.dir)}))
+}
+
+// filesOnly is a file system whose directories cannot be opened, so a file
+// server over it answers 404 instead of listing them
+type filesOnly struct {
+	fs http.FileSystem
+}
+
+// Open implements http.FileSystem
+func (f filesOnly) Open(name string) (http.File, error) {
+	file, err := f.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, fs.ErrNotExist
+	}
+	return file, nil
+}
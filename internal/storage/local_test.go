@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore_PutAndServe(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := s.Put(ctx, "jobs/adon olam.wav", strings.NewReader("RIFF"), 4, "audio/wav"); err != nil {
+		t.Fatalf("Put() error: %v", err)
+	}
+
+	if ok, err := s.Exists(ctx, "jobs/adon olam.wav"); !ok || err != nil {
+		t.Errorf("Exists() = %v, %v, want true", ok, err)
+	}
+	if ok, err := s.Exists(ctx, "jobs/missing.wav"); ok || err != nil {
+		t.Errorf("Exists(missing) = %v, %v, want false", ok, err)
+	}
+
+	link, err := s.URL(ctx, "jobs/adon olam.wav")
+	if err != nil {
+		t.Fatalf("URL() error: %v", err)
+	}
+	uri := link.URL
+	if uri != "/media/jobs/adon%20olam.wav" || !link.Expires.IsZero() {
+		t.Errorf("URL() = %+v, want /media/jobs/adon%%20olam.wav with no expiry", link)
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest("GET", uri, nil))
+	body, _ := io.ReadAll(rec.Body)
+	if rec.Code != 200 || string(body) != "RIFF" {
+		t.Errorf("GET %s = %d %q, want 200 \"RIFF\"", uri, rec.Code, body)
+	}
+}
+
+func TestLocalStore_NoDirectoryListing(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(context.Background(), "renders/abc.wav", strings.NewReader("RIFF"), 4, "audio/wav"); err != nil {
+		t.Fatalf("Put() error: %v", err)
+	}
+
+	for _, uri := range []string{"/media/", "/media/renders/", "/media/renders"} {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest("GET", uri, nil))
+		body, _ := io.ReadAll(rec.Body)
+		if rec.Code != 404 || strings.Contains(string(body), "abc.wav") {
+			t.Errorf("GET %s = %d %q, want 404 without a listing", uri, rec.Code, body)
+		}
+	}
+}
+
+func TestLocalStore_InvalidKeys(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", "../escape.wav", "a/../../b.wav", "/abs.wav", "dir/"} {
+		err := s.Put(context.Background(), key, strings.NewReader("x"), 1, "audio/wav")
+		if !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("STORAGE_BACKEND", BackendLocal)
+	t.Setenv("MEDIA_DIR", t.TempDir())
+	if s, err := NewFromEnv(); err != nil {
+		t.Errorf("NewFromEnv() error: %v", err)
+	} else if _, ok := s.(*LocalStore); !ok {
+		t.Errorf("NewFromEnv() = %T, want *LocalStore", s)
+	}
+
+	t.Setenv("STORAGE_BACKEND", "floppy")
+	if _, err := NewFromEnv(); err == nil {
+		t.Error("NewFromEnv() with an unknown backend should fail")
+	}
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Presigned URL lifetimes. S3 refuses to presign for longer than a week.
+const (
+	DefaultExpiry = time.Hour
+	MaxExpiry     = 7 * 24 * time.Hour
+)
+
+// MinIOStore keeps objects in a MinIO or other S3 compatible bucket and
+// returns presigned download URLs
+type MinIOStore struct {
+	client *minio.Client
+	bucket string
+	expiry time.Duration
+
+	mu          sync.Mutex
+	bucketReady bool
+}
+
+// NewMinIO returns a store for bucket using an existing client, presigning
+// URLs valid for expiry. The bucket is created on first use if it does not exist.
+func NewMinIO(client *minio.Client, bucket string, expiry time.Duration) (*MinIOStore, error) {
+	if expiry <= 0 || expiry > MaxExpiry {
+		return nil, fmt.Errorf("URL expiry must be between 1s and %s, got %s", MaxExpiry, expiry)
+	}
+	return &MinIOStore{client: client, bucket: bucket, expiry: expiry}, nil
+}
+
+// NewMinIOFromEnv connects to MINIO_ENDPOINT (over TLS if MINIO_SECURE is
+// "true") with the MinIO credential variables and uses MINIO_DEFAULT_BUCKETS.
+// Links expire after RESULT_URL_EXPIRY (a Go duration, DefaultExpiry if unset).
+func NewMinIOFromEnv() (*MinIOStore, error) {
+	endpoint := os.Getenv("MINIO_ENDPOINT")
+	if endpoint == "" {
+		return nil, fmt.Errorf("MINIO_ENDPOINT must be set for the %s storage backend", BackendMinIO)
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewEnvMinio(),
+		Secure: os.Getenv("MINIO_SECURE") == "true",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("minio.New: %w", err)
+	}
+
+	expiry := DefaultExpiry
+	if v := os.Getenv("RESULT_URL_EXPIRY"); v != "" {
+		expiry, err = time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("RESULT_URL_EXPIRY must be a duration, got %q", v)
+		}
+	}
+
+	return NewMinIO(client, os.Getenv("MINIO_DEFAULT_BUCKETS"), expiry)
+}
+
+// ensureBucket creates the bucket if needed, remembering once it exists
+func (s *MinIOStore) ensureBucket(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.bucketReady {
+		return nil
+	}
+
+	found, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket %s: %w", s.bucket, err)
+	}
+	if !found {
+		if err := s.client.MakeBucket(ctx, s.bucket, minio.MakeBucketOptions{}); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", s.bucket, err)
+		}
+	}
+
+	s.bucketReady = true
+	return nil
+}
+
+// Put implements ObjectStore
+func (s *MinIOStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if err := s.ensureBucket(ctx); err != nil {
+		return err
+	}
+
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// Exists implements ObjectStore
+func (s *MinIOStore) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchBucket":
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to check %s: %w", key, err)
+}
+
+// URL implements ObjectStore with a presigned GET URL
+func (s *MinIOStore) URL(ctx context.Context, key string) (Link, error) {
+	expires := time.Now().Add(s.expiry)
+	uri, err := s.client.PresignedGetObject(ctx, s.bucket, key, s.expiry, url.Values{})
+	if err != nil {
+		return Link{}, fmt.Errorf("failed to presign %s: %w", key, err)
+	}
+	return Link{URL: uri.String(), Expires: expires}, nil
+}
@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewMinIO_Expiry(t *testing.T) {
+	for _, expiry := range []time.Duration{0, -time.Minute, MaxExpiry + time.Second} {
+		if _, err := NewMinIO(nil, "bucket", expiry); err == nil {
+			t.Errorf("NewMinIO() with expiry %s should fail", expiry)
+		}
+	}
+	if _, err := NewMinIO(nil, "bucket", DefaultExpiry); err != nil {
+		t.Errorf("NewMinIO() with the default expiry error: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Link is a URL an object can be downloaded from
+type Link struct {
+	URL     string
+	Expires time.Time // When the URL stops working, zero if it never does
+}
+
+// ObjectStore keeps rendered audio and hands out URLs clients can fetch it from
+type ObjectStore interface {
+	// Put stores size bytes read from r under key
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Exists reports whether an object is stored under key
+	Exists(ctx context.Context, key string) (bool, error)
+	// URL returns a link the object under key can be downloaded from
+	URL(ctx context.Context, key string) (Link, error)
+}
+
+// Backend names accepted by NewFromEnv
+const (
+	BackendMinIO = "minio"
+	BackendLocal = "local"
+)
+
+// DefaultMediaDir is where the local backend keeps objects if MEDIA_DIR is unset
+const DefaultMediaDir = "media"
+
+// NewFromEnv returns the object store selected by STORAGE_BACKEND. The
+// MinIO backend (the default) is configured from the MINIO_* variables and
+// the local backend stores objects in MEDIA_DIR, served under MediaPrefix.
+// RESULT_URL_EXPIRY sets how long MinIO download links stay valid.
+func NewFromEnv() (ObjectStore, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case BackendMinIO, "":
+		return NewMinIOFromEnv()
+	case BackendLocal:
+		dir := os.Getenv("MEDIA_DIR")
+		if dir == "" {
+			dir = DefaultMediaDir
+		}
+		return NewLocal(dir)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s (must be '%s' or '%s')", backend, BackendMinIO, BackendLocal)
+	}
+}
@@ -0,0 +1,103 @@
+package synth
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+	"github.com/sammyshear/adon-olam/internal/wav"
+)
+
+// DynamicsCurve maps a note's velocity, channel volume (CC7) and expression
+// (CC11) to the gain it is sung at
+type DynamicsCurve string
+
+const (
+	// DynamicsOff sings every note at full gain (the original behavior)
+	DynamicsOff DynamicsCurve = "off"
+	// DynamicsLinear scales the gain in proportion to each of velocity,
+	// volume and expression
+	DynamicsLinear DynamicsCurve = "linear"
+	// DynamicsGM follows the General MIDI recommendation of 40·log10(v/127)
+	// dB for each, i.e. the square of each value, so soft notes drop off faster
+	DynamicsGM DynamicsCurve = "gm"
+)
+
+// ParseDynamicsCurve converts a curve name into a DynamicsCurve
+// An empty name selects the default off curve
+func ParseDynamicsCurve(name string) (DynamicsCurve, error) {
+	switch DynamicsCurve(name) {
+	case DynamicsOff, "":
+		return DynamicsOff, nil
+	case DynamicsLinear:
+		return DynamicsLinear, nil
+	case DynamicsGM:
+		return DynamicsGM, nil
+	default:
+		return "", fmt.Errorf("invalid dynamics curve: %s (must be '%s', '%s' or '%s')", name, DynamicsOff, DynamicsLinear, DynamicsGM)
+	}
+}
+
+// Gain returns the gain, 0-1, of a note under the curve. Notes without a
+// velocity, such as rests and notes built by hand, are at full gain.
+func (c DynamicsCurve) Gain(note fonspeak_midi.Note) float64 {
+	if note.Velocity == 0 {
+		return 1
+	}
+	level := float64(note.Velocity) / 127 * float64(note.Volume) / 127 * float64(note.Expression) / 127
+	switch c {
+	case DynamicsLinear:
+		return level
+	case DynamicsGM:
+		return level * level
+	default:
+		return 1
+	}
+}
+
+// WithDynamics returns a copy of the plan with each event's gain set by the curve
+func (p Plan) WithDynamics(curve DynamicsCurve) Plan {
+	events := make([]Event, len(p.Events))
+	for i, event := range p.Events {
+		event.Gain = curve.Gain(event.Note)
+		events[i] = event
+	}
+	p.Events = events
+	return p
+}
+
+// applyGains scales consecutive spans of audio by gains, each span taking a
+// share of the audio in proportion to its weight. Gain changes are ramped
+// over fadeSeconds so steps between notes do not click.
+func applyGains(audio *wav.Audio, gains, weights []float64) {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	frames := len(audio.Samples) / audio.Channels
+	if total <= 0 || frames == 0 {
+		return
+	}
+	ramp := max(int(fadeSeconds*float64(audio.SampleRate)), 1)
+
+	start, elapsed := 0, 0.0
+	prev := gains[0]
+	for i, gain := range gains {
+		elapsed += weights[i]
+		end := frames
+		if i < len(gains)-1 {
+			end = int(math.Round(elapsed / total * float64(frames)))
+		}
+		for f := start; f < end; f++ {
+			g := gain
+			if d := f - start; d < ramp {
+				g = prev + (gain-prev)*float64(d)/float64(ramp)
+			}
+			for ch := 0; ch < audio.Channels; ch++ {
+				j := f*audio.Channels + ch
+				audio.Samples[j] = int16(math.Round(float64(audio.Samples[j]) * g))
+			}
+		}
+		start, prev = end, gain
+	}
+}
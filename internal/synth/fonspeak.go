@@ -0,0 +1,147 @@
+package synth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/sammyshear/adon-olam/internal/timing"
+	"github.com/sammyshear/adon-olam/internal/wav"
+	"github.com/sammyshear/fonspeak"
+)
+
+// FonspeakSynthesizer sings through fonspeak, which shells out to espeak-ng,
+// Praat and sox. Each phrase of consecutive sung notes is rendered in one
+// fonspeak call and phrases are joined with silence for the rests between them.
+// fonspeak has no volume control, so each note's gain is applied afterwards
+// across its share of the phrase.
+type FonspeakSynthesizer struct {
+	Concurrency int // Maximum syllables synthesized in parallel per phrase
+}
+
+// NewFonspeak returns a fonspeak synthesizer with the default concurrency
+func NewFonspeak() *FonspeakSynthesizer {
+	return &FonspeakSynthesizer{Concurrency: 15}
+}
+
+// bufWriteCloser wraps bufio.Writer to implement WriteCloser
+type bufWriteCloser struct {
+	*bufio.Writer
+}
+
+func (bwc *bufWriteCloser) Close() error {
+	return bwc.Flush()
+}
+
+// Synthesize implements Synthesizer
+func (s *FonspeakSynthesizer) Synthesize(ctx context.Context, plan Plan) (*wav.Audio, error) {
+	var audio *wav.Audio
+	var phrase []fonspeak.Params
+	var gains, durations []float64 // of the phrase's notes
+	pendingSilence := 0.0
+	sung := 0
+
+	flush := func() error {
+		if len(phrase) == 0 {
+			return nil
+		}
+
+		segment, err := s.synthesizePhrase(ctx, phrase)
+		if err != nil {
+			return err
+		}
+		for _, gain := range gains {
+			if gain != 1 {
+				applyGains(segment, gains, durations)
+				break
+			}
+		}
+		gains, durations = nil, nil
+
+		if audio == nil {
+			audio = wav.New(segment.SampleRate, segment.Channels)
+		}
+		audio.AppendSilence(pendingSilence)
+		pendingSilence = 0
+		sung += len(phrase)
+		phrase = nil
+
+		if err := audio.Append(segment); err != nil {
+			return err
+		}
+		plan.reportProgress(sung)
+		return nil
+	}
+
+	for _, event := range plan.Events {
+		// Rests (and notes left without a syllable) become silence
+		if event.IsSilent() {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			pendingSilence += event.Note.Duration
+			continue
+		}
+
+		phrase = append(phrase, fonspeak.Params{
+			Syllable:   event.Syllables[0].Text,
+			PitchShift: event.PitchHz,
+			Voice:      plan.Voice,
+			// fonspeak is rate driven, so derive WPM from the allocated phoneme durations
+			Wpm: timing.ComputeWPMFromPhonemes(event.Syllables),
+		})
+		gains = append(gains, event.Gain)
+		durations = append(durations, event.Note.Duration)
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	if audio == nil {
+		return nil, fmt.Errorf("no sung notes to synthesize")
+	}
+	audio.AppendSilence(pendingSilence)
+
+	return audio, nil
+}
+
+// synthesizePhrase renders one run of syllables with fonspeak and decodes the result.
+// fonspeak cannot be interrupted, so on cancellation the call is abandoned
+// and left to finish in the background while the error is returned at once.
+func (s *FonspeakSynthesizer) synthesizePhrase(ctx context.Context, phrase []fonspeak.Params) (*wav.Audio, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := &bufWriteCloser{Writer: bufio.NewWriter(&buf)}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fonspeak.FonspeakPhrase(fonspeak.PhraseParams{
+			Syllables: phrase,
+			WavFile:   w,
+		}, s.Concurrency)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case err := <-done:
+		if err != nil {
+			return nil, fmt.Errorf("fonspeak synthesis failed: %w", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to flush synthesized phrase: %w", err)
+	}
+
+	segment, err := wav.Decode(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode synthesized phrase: %w", err)
+	}
+
+	return segment, nil
+}
@@ -0,0 +1,165 @@
+package synth
+
+import (
+	"context"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/sammyshear/adon-olam/internal/timing"
+	"github.com/sammyshear/adon-olam/internal/wav"
+)
+
+// SineSynthesizer is a pure-Go backend that needs no external binaries.
+// Vowels are rendered as harmonic series shaped by two formant resonances,
+// voiced consonants as a quiet tone and the rest as a short noise burst.
+// It is meant for tests and development rather than listening.
+type SineSynthesizer struct {
+	SampleRate int     // Output sample rate in Hz
+	Amplitude  float64 // Peak amplitude of a vowel, 0-1
+}
+
+// NewSine returns a sine synthesizer with the same sample rate espeak-ng uses
+func NewSine() *SineSynthesizer {
+	return &SineSynthesizer{SampleRate: 22050, Amplitude: 0.5}
+}
+
+// formants holds approximate first and second formant frequencies for vowels
+var formants = map[rune][2]float64{
+	'a': {800, 1200},
+	'e': {500, 1900},
+	'i': {300, 2300},
+	'o': {500, 900},
+	'u': {320, 800},
+	'@': {500, 1500},
+}
+
+// voicedConsonants are rendered as a quiet tone at the note pitch rather than noise
+const voicedConsonants = "bdgjlmnrvwzZ"
+
+// fadeSeconds is the ramp applied at phoneme boundaries to avoid clicks
+const fadeSeconds = 0.005
+
+// Synthesize implements Synthesizer. Every event occupies exactly its note
+// duration so the output follows the plan's timeline.
+func (s *SineSynthesizer) Synthesize(ctx context.Context, plan Plan) (*wav.Audio, error) {
+	audio := wav.New(s.SampleRate, 1)
+	noise := uint32(1)
+	sung := 0
+
+	for _, event := range plan.Events {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		noteFrames := int(math.Round(event.Note.Duration * float64(s.SampleRate)))
+		if event.IsSilent() {
+			audio.Samples = append(audio.Samples, make([]int16, noteFrames)...)
+			continue
+		}
+
+		samples := make([]float64, 0, noteFrames)
+		for _, syl := range event.Syllables {
+			for _, ph := range syl.Phonemes {
+				frames := int(math.Round(ph.Duration * float64(s.SampleRate)))
+				samples = append(samples, s.renderPhoneme(ph, event.PitchHz, frames, &noise)...)
+			}
+		}
+
+		// Pad or trim to the note length so phoneme clamping never shifts later notes
+		if len(samples) > noteFrames {
+			samples = samples[:noteFrames]
+		}
+		for len(samples) < noteFrames {
+			samples = append(samples, 0)
+		}
+
+		for _, v := range samples {
+			v *= event.Gain
+			audio.Samples = append(audio.Samples, int16(math.Max(-1, math.Min(1, v))*math.MaxInt16))
+		}
+
+		sung += len(event.Syllables)
+		plan.reportProgress(sung)
+	}
+
+	return audio, nil
+}
+
+// renderPhoneme returns frames samples for one phoneme at the given pitch
+func (s *SineSynthesizer) renderPhoneme(ph timing.Phoneme, pitchHz float64, frames int, noise *uint32) []float64 {
+	out := make([]float64, frames)
+	rate := float64(s.SampleRate)
+	r := firstRune(ph.Text)
+
+	switch {
+	case ph.Kind == timing.Vowel:
+		// Upper-case X-SAMPA vowels are open variants, close enough for a test tone
+		f, ok := formants[unicode.ToLower(r)]
+		if !ok {
+			f = formants['@']
+		}
+		// Weight each harmonic below Nyquist by its distance to the formants
+		var weights []float64
+		total := 0.0
+		for k := 1; float64(k)*pitchHz < rate/2 && k <= 40; k++ {
+			h := float64(k) * pitchHz
+			w := resonance(h, f[0]) + 0.5*resonance(h, f[1])
+			weights = append(weights, w)
+			total += w
+		}
+		if total == 0 {
+			break
+		}
+		for i := range out {
+			t := float64(i) / rate
+			v := 0.0
+			for k, w := range weights {
+				v += w * math.Sin(2*math.Pi*float64(k+1)*pitchHz*t)
+			}
+			out[i] = s.Amplitude * v / total
+		}
+	case strings.ContainsRune(voicedConsonants, r):
+		for i := range out {
+			out[i] = 0.2 * s.Amplitude * math.Sin(2*math.Pi*pitchHz*float64(i)/rate)
+		}
+	default:
+		// Deterministic xorshift noise keeps renders reproducible
+		for i := range out {
+			*noise ^= *noise << 13
+			*noise ^= *noise >> 17
+			*noise ^= *noise << 5
+			out[i] = 0.15 * s.Amplitude * (float64(*noise)/math.MaxUint32*2 - 1)
+		}
+	}
+
+	applyFade(out, int(fadeSeconds*rate))
+	return out
+}
+
+// resonance approximates a formant filter's gain at frequency f
+func resonance(f, center float64) float64 {
+	const bandwidth = 120.0
+	d := (f - center) / bandwidth
+	return 1 / (1 + d*d)
+}
+
+// applyFade ramps the first and last n samples in and out
+func applyFade(samples []float64, n int) {
+	if n*2 > len(samples) {
+		n = len(samples) / 2
+	}
+	for i := 0; i < n; i++ {
+		g := float64(i) / float64(n)
+		samples[i] *= g
+		samples[len(samples)-1-i] *= g
+	}
+}
+
+// firstRune returns the first rune of s, or 0 if s is empty
+func firstRune(s string) rune {
+	for _, r := range s {
+		return r
+	}
+	return 0
+}
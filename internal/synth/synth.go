@@ -0,0 +1,96 @@
+package synth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+	"github.com/sammyshear/adon-olam/internal/timing"
+	"github.com/sammyshear/adon-olam/internal/wav"
+)
+
+// Event is a single note of a render plan together with the syllables sung on it
+type Event struct {
+	Note      fonspeak_midi.Note // Source note, including onset and rest kind
+	PitchHz   float64            // Target pitch after transposition, 0 for rests
+	Syllables []timing.Syllable  // Syllables with allocated phoneme durations
+	Gain      float64            // Output gain from the note's dynamics, 0-1
+}
+
+// IsSilent reports whether nothing is sung during the event
+func (e Event) IsSilent() bool {
+	return e.Note.IsRest() || len(e.Syllables) == 0
+}
+
+// ProgressFunc is called as a synthesizer renders a plan with the number of
+// syllables sung so far and the total number in the plan
+type ProgressFunc func(done, total int)
+
+// Plan is an aligned note/syllable/phoneme plan ready for synthesis
+type Plan struct {
+	Voice      string       // Voice to sing with (backend specific, e.g. espeak voice name)
+	Events     []Event      // Events in playback order
+	OnProgress ProgressFunc // Optional progress callback
+}
+
+// SyllableCount returns the number of syllables sung across all events
+func (p Plan) SyllableCount() int {
+	count := 0
+	for _, event := range p.Events {
+		if !event.IsSilent() {
+			count += len(event.Syllables)
+		}
+	}
+	return count
+}
+
+// reportProgress calls OnProgress if one is set
+func (p Plan) reportProgress(done int) {
+	if p.OnProgress != nil {
+		p.OnProgress(done, p.SyllableCount())
+	}
+}
+
+// Synthesizer renders a plan to PCM audio. Implementations should stop and
+// return the context's error once it is cancelled.
+type Synthesizer interface {
+	Synthesize(ctx context.Context, plan Plan) (*wav.Audio, error)
+}
+
+// Backend names accepted by New
+const (
+	BackendFonspeak = "fonspeak"
+	BackendSine     = "sine"
+)
+
+// New returns the synthesizer registered under the given backend name
+func New(backend string) (Synthesizer, error) {
+	switch backend {
+	case BackendFonspeak, "":
+		return NewFonspeak(), nil
+	case BackendSine:
+		return NewSine(), nil
+	default:
+		return nil, fmt.Errorf("unknown synthesizer backend: %s (must be '%s' or '%s')", backend, BackendFonspeak, BackendSine)
+	}
+}
+
+// NewPlan builds a plan from notes with allocated phoneme durations,
+// converting each pitched note to Hz with the global octave drop applied.
+// Every event is at full gain; WithDynamics follows the notes' dynamics.
+func NewPlan(notesWithSyllables []timing.NoteWithSyllables, octaveDrop int, voice string) Plan {
+	events := make([]Event, 0, len(notesWithSyllables))
+	for _, nws := range notesWithSyllables {
+		event := Event{
+			Note:      nws.Note,
+			Syllables: nws.Syllables,
+			Gain:      1,
+		}
+		if !nws.Note.IsRest() {
+			event.PitchHz = fonspeak_midi.MIDINoteToHz(nws.Note.MIDINote, -octaveDrop)
+		}
+		events = append(events, event)
+	}
+
+	return Plan{Voice: voice, Events: events}
+}
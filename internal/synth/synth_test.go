@@ -0,0 +1,201 @@
+package synth
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+	"github.com/sammyshear/adon-olam/internal/timing"
+	"github.com/sammyshear/adon-olam/internal/wav"
+)
+
+// testPlan builds a plan of three notes with a rest in the middle
+func testPlan() Plan {
+	notes := []fonspeak_midi.Note{
+		{MIDINote: 69, Start: 0, Duration: 0.5},
+		{Start: 0.5, Duration: 0.25, Kind: fonspeak_midi.Rest},
+		{MIDINote: 81, Start: 0.75, Duration: 0.5},
+	}
+	nws := timing.PrepareNotesWithSyllables(notes, []string{"a", "don"})
+	nws = timing.AllocateDurations(nws, timing.DefaultTimingOptions())
+
+	return NewPlan(nws, 1, "he")
+}
+
+func TestNewPlan(t *testing.T) {
+	plan := testPlan()
+
+	if len(plan.Events) != 3 {
+		t.Fatalf("got %d events, want 3", len(plan.Events))
+	}
+	if math.Abs(plan.Events[0].PitchHz-220) > 0.01 {
+		t.Errorf("first event pitch = %.2f Hz, want 220 Hz after one octave drop", plan.Events[0].PitchHz)
+	}
+	if !plan.Events[1].IsSilent() || plan.Events[1].PitchHz != 0 {
+		t.Errorf("rest event = %+v, want a silent event with no pitch", plan.Events[1])
+	}
+	if plan.Voice != "he" {
+		t.Errorf("voice = %q, want %q", plan.Voice, "he")
+	}
+}
+
+func TestSineSynthesizer_FollowsTimeline(t *testing.T) {
+	s := NewSine()
+
+	audio, err := s.Synthesize(context.Background(), testPlan())
+	if err != nil {
+		t.Fatalf("Synthesize() error: %v", err)
+	}
+
+	if math.Abs(audio.Duration()-1.25) > 0.001 {
+		t.Errorf("Duration() = %.3f, want 1.25", audio.Duration())
+	}
+
+	// The rest between 0.5s and 0.75s must be silent
+	start, end := int(0.5*float64(s.SampleRate)), int(0.75*float64(s.SampleRate))
+	for i := start; i < end; i++ {
+		if audio.Samples[i] != 0 {
+			t.Fatalf("sample %d during rest = %d, want 0", i, audio.Samples[i])
+		}
+	}
+
+	// The first note must not be silent
+	peak := int16(0)
+	for _, v := range audio.Samples[:start] {
+		if v > peak {
+			peak = v
+		}
+	}
+	if peak == 0 {
+		t.Error("first note rendered as silence")
+	}
+}
+
+func TestParseDynamicsCurve(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    DynamicsCurve
+		wantErr bool
+	}{
+		{"", DynamicsOff, false},
+		{"off", DynamicsOff, false},
+		{"linear", DynamicsLinear, false},
+		{"gm", DynamicsGM, false},
+		{"loud", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDynamicsCurve(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseDynamicsCurve(%q) = %q, %v, want %q (error %v)", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDynamicsCurve_Gain(t *testing.T) {
+	half := fonspeak_midi.Note{Velocity: 63, Volume: 127, Expression: 127}
+	tests := []struct {
+		curve DynamicsCurve
+		note  fonspeak_midi.Note
+		want  float64
+	}{
+		{DynamicsOff, half, 1},
+		{DynamicsLinear, half, 63.0 / 127},
+		{DynamicsGM, half, (63.0 / 127) * (63.0 / 127)},
+		{DynamicsLinear, fonspeak_midi.Note{Velocity: 127, Volume: 100, Expression: 127}, 100.0 / 127},
+		{DynamicsGM, fonspeak_midi.Note{Kind: fonspeak_midi.Rest}, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.curve.Gain(tt.note); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s gain of %+v = %g, want %g", tt.curve, tt.note, got, tt.want)
+		}
+	}
+}
+
+func TestSineSynthesizer_Dynamics(t *testing.T) {
+	plan := testPlan()
+	plan.Events[0].Note.Velocity, plan.Events[0].Note.Volume, plan.Events[0].Note.Expression = 127, 127, 127
+	plan.Events[2].Note.Velocity, plan.Events[2].Note.Volume, plan.Events[2].Note.Expression = 32, 127, 127
+	plan = plan.WithDynamics(DynamicsLinear)
+
+	audio, err := NewSine().Synthesize(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Synthesize() error: %v", err)
+	}
+	split := int(0.75 * float64(audio.SampleRate))
+	if loud, soft := peak(audio.Samples[:split]), peak(audio.Samples[split:]); soft*2 > loud {
+		t.Errorf("peaks = %d then %d, want the second note at about a quarter", loud, soft)
+	}
+}
+
+func TestApplyGains(t *testing.T) {
+	audio := wav.New(1000, 1)
+	for range 1000 {
+		audio.Samples = append(audio.Samples, 1000)
+	}
+	applyGains(audio, []float64{1, 0.5}, []float64{1, 3})
+
+	// The first quarter is untouched, the rest ramps down to half over 5ms
+	if got := audio.Samples[100]; got != 1000 {
+		t.Errorf("sample 100 = %d, want 1000", got)
+	}
+	if got := audio.Samples[252]; got <= 500 || got >= 1000 {
+		t.Errorf("sample 252 = %d, want part way down the ramp", got)
+	}
+	if got := audio.Samples[999]; got != 500 {
+		t.Errorf("sample 999 = %d, want 500", got)
+	}
+}
+
+// peak returns the largest sample magnitude
+func peak(samples []int16) int {
+	p := 0
+	for _, v := range samples {
+		p = max(p, int(v), -int(v))
+	}
+	return p
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{BackendFonspeak, BackendSine, ""} {
+		if _, err := New(name); err != nil {
+			t.Errorf("New(%q) error: %v", name, err)
+		}
+	}
+
+	if _, err := New("bogus"); err == nil {
+		t.Error("New(\"bogus\") should fail")
+	}
+}
+
+func TestSineSynthesizer_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewSine().Synthesize(ctx, testPlan()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Synthesize() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestSineSynthesizer_Progress(t *testing.T) {
+	plan := testPlan()
+	var reports [][2]int
+	plan.OnProgress = func(done, total int) {
+		reports = append(reports, [2]int{done, total})
+	}
+
+	if _, err := NewSine().Synthesize(context.Background(), plan); err != nil {
+		t.Fatalf("Synthesize() error: %v", err)
+	}
+
+	want := [][2]int{{1, 2}, {2, 2}}
+	if len(reports) != len(want) {
+		t.Fatalf("progress reports = %v, want %v", reports, want)
+	}
+	for i := range want {
+		if reports[i] != want[i] {
+			t.Errorf("report %d = %v, want %v", i, reports[i], want[i])
+		}
+	}
+}
@@ -216,6 +216,7 @@ func ComputeWPMFromPhonemes(syllables []Syllable) int {
 
 // PrepareNotesWithSyllables converts raw notes and syllable strings into NoteWithSyllables
 // This handles the alignment of syllables to notes with a simple 1:1 mapping.
+// Rests are kept in place with no syllables and do not consume a syllable.
 func PrepareNotesWithSyllables(notes []fonspeak_midi.Note, syllableTexts []string) []NoteWithSyllables {
 	if len(notes) == 0 || len(syllableTexts) == 0 {
 		return []NoteWithSyllables{}
@@ -223,16 +224,18 @@ func PrepareNotesWithSyllables(notes []fonspeak_midi.Note, syllableTexts []strin
 	
 	result := make([]NoteWithSyllables, len(notes))
 	
+	sylIdx := 0
 	for i, note := range notes {
-		// Map one syllable to one note (simple 1:1 alignment)
-		if i < len(syllableTexts) {
-			syl := ParseSyllable(syllableTexts[i])
+		// Map one syllable to one pitched note (simple 1:1 alignment)
+		if !note.IsRest() && sylIdx < len(syllableTexts) {
+			syl := ParseSyllable(syllableTexts[sylIdx])
+			sylIdx++
 			result[i] = NoteWithSyllables{
 				Note:      note,
 				Syllables: []Syllable{syl},
 			}
 		} else {
-			// No more syllables, use empty
+			// Rest or no more syllables, use empty
 			result[i] = NoteWithSyllables{
 				Note:      note,
 				Syllables: []Syllable{},
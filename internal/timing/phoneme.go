@@ -3,13 +3,13 @@ package timing
 import (
 	"strings"
 	"unicode"
+
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
 )
 
 // isVowel checks if a character is a vowel (including X-SAMPA vowel symbols)
 func isVowel(r rune) bool {
-	// Common vowels in X-SAMPA and IPA
-	vowels := "aeiouæɑɔəɛɪʊʌAEIOUyY@69"
-	return strings.ContainsRune(vowels, r)
+	return fonspeak_midi.IsVowelSymbol(r)
 }
 
 // ClassifyPhonemeKind determines if a phoneme text represents a vowel or consonant
@@ -21,20 +21,25 @@ func ClassifyPhonemeKind(phonemeText string) PhonemeKind {
 	// Check if the phoneme starts with a vowel character
 	// This is a heuristic that works for most X-SAMPA representations
 	for _, r := range phonemeText {
-		if unicode.IsLetter(r) || r == '@' {
-			if isVowel(r) {
-				return Vowel
-			}
-			// If we encounter a non-vowel letter first, it's likely a consonant
+		if isVowel(r) {
+			return Vowel
+		}
+		// If we encounter a non-vowel letter first, it's likely a consonant
+		if unicode.IsLetter(r) {
 			return Consonant
 		}
 	}
+	// X-SAMPA symbols like "?\" (pharyngeal fricative) are consonants
+	if strings.HasSuffix(phonemeText, "\\") {
+		return Consonant
+	}
 	
 	return Unknown
 }
 
 // ParseSyllableToPhonemes breaks a syllable into individual phonemes
-// This is a simplified implementation that treats each character as a phoneme
+// This is a simplified implementation that treats each character as a phoneme,
+// apart from common digraphs and symbols extended by a backslash, like "X\"
 // For production, you might want more sophisticated parsing
 func ParseSyllableToPhonemes(syllableText string) []Phoneme {
 	if syllableText == "" {
@@ -52,8 +57,12 @@ func ParseSyllableToPhonemes(syllableText string) []Phoneme {
 		// Check for common multi-character X-SAMPA combinations
 		var phonemeText string
 		
-		// Try two-character combinations first
-		if i+1 < len(syllableText) {
+		// A backslash extends the symbol before it, as in "X\" or "?\"
+		if i+1 < len(syllableText) && syllableText[i+1] == '\\' {
+			phonemeText = syllableText[i : i+2]
+			i += 2
+		} else if i+1 < len(syllableText) {
+			// Try two-character combinations
 			twoChar := syllableText[i:i+2]
 			// Common X-SAMPA digraphs: aI, eI, OI, aU, @U, etc.
 			if isMultiCharPhoneme(twoChar) {
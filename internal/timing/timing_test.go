@@ -2,6 +2,7 @@ package timing
 
 import (
 	"math"
+	"reflect"
 	"testing"
 	
 	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
@@ -75,6 +76,22 @@ func TestParseSyllable(t *testing.T) {
 	}
 }
 
+func TestParseSyllable_BackslashSymbols(t *testing.T) {
+	tests := []struct {
+		syllable string
+		want     []Phoneme
+	}{
+		{`X\oX`, []Phoneme{{Text: `X\`, Kind: Consonant}, {Text: "o", Kind: Vowel}, {Text: "X", Kind: Consonant}}},
+		{`?\a`, []Phoneme{{Text: `?\`, Kind: Consonant}, {Text: "a", Kind: Vowel}}},
+	}
+	for _, tt := range tests {
+		syl := ParseSyllable(tt.syllable)
+		if !reflect.DeepEqual(syl.Phonemes, tt.want) {
+			t.Errorf("ParseSyllable(%q).Phonemes = %+v, want %+v", tt.syllable, syl.Phonemes, tt.want)
+		}
+	}
+}
+
 func TestAllocateLastPhoneme(t *testing.T) {
 	// Create a simple note with syllables
 	note := fonspeak_midi.Note{
@@ -466,3 +483,51 @@ func TestPrepareNotesWithSyllables_MoreNotesThanSyllables(t *testing.T) {
 		t.Error("Third note should have no syllables")
 	}
 }
+
+func TestPrepareNotesWithSyllables_RestsSkipped(t *testing.T) {
+	notes := []fonspeak_midi.Note{
+		{MIDINote: 60, Duration: 0.5},
+		{Duration: 0.25, Kind: fonspeak_midi.Rest},
+		{MIDINote: 62, Duration: 0.6},
+	}
+	
+	result := PrepareNotesWithSyllables(notes, []string{"a", "don"})
+	
+	if len(result) != 3 {
+		t.Fatalf("Expected 3 note-syllable pairs, got %d", len(result))
+	}
+	
+	if len(result[1].Syllables) != 0 {
+		t.Error("Rest should have no syllables")
+	}
+	
+	if len(result[2].Syllables) == 0 || result[2].Syllables[0].Text != "don" {
+		t.Error("Note after the rest should have syllable 'don'")
+	}
+}
+
+func TestParseTimingStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    TimingStrategy
+		wantErr bool
+	}{
+		{"per-syllable", "per-syllable", PerSyllable, false},
+		{"last-phoneme", "last-phoneme", LastPhoneme, false},
+		{"empty defaults to per-syllable", "", PerSyllable, false},
+		{"invalid", "fastest", "", true},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTimingStrategy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTimingStrategy(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTimingStrategy(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
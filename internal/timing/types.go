@@ -1,6 +1,10 @@
 package timing
 
-import "github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+import (
+	"fmt"
+
+	"github.com/sammyshear/adon-olam/internal/fonspeak_midi"
+)
 
 // TimingStrategy defines how phoneme durations are allocated
 type TimingStrategy string
@@ -12,6 +16,19 @@ const (
 	LastPhoneme TimingStrategy = "last-phoneme"
 )
 
+// ParseTimingStrategy converts a strategy name into a TimingStrategy
+// An empty name selects the default per-syllable strategy
+func ParseTimingStrategy(name string) (TimingStrategy, error) {
+	switch TimingStrategy(name) {
+	case PerSyllable, "":
+		return PerSyllable, nil
+	case LastPhoneme:
+		return LastPhoneme, nil
+	default:
+		return "", fmt.Errorf("invalid timing strategy: %s (must be '%s' or '%s')", name, PerSyllable, LastPhoneme)
+	}
+}
+
 // PhonemeKind classifies phoneme types
 type PhonemeKind int
 
@@ -0,0 +1,158 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	formatPCM        = 1
+	formatExtensible = 0xFFFE
+)
+
+// Audio holds 16-bit PCM audio with interleaved channel samples
+type Audio struct {
+	SampleRate int     // Samples per second per channel
+	Channels   int     // Number of interleaved channels
+	Samples    []int16 // Interleaved samples
+}
+
+// New returns an empty Audio buffer with the given format
+func New(sampleRate, channels int) *Audio {
+	return &Audio{SampleRate: sampleRate, Channels: channels}
+}
+
+// Duration returns the length of the audio in seconds
+func (a *Audio) Duration() float64 {
+	if a.SampleRate == 0 || a.Channels == 0 {
+		return 0
+	}
+	return float64(len(a.Samples)/a.Channels) / float64(a.SampleRate)
+}
+
+// AppendSilence appends the given number of seconds of silence
+func (a *Audio) AppendSilence(seconds float64) {
+	if seconds <= 0 {
+		return
+	}
+	frames := int(math.Round(seconds * float64(a.SampleRate)))
+	a.Samples = append(a.Samples, make([]int16, frames*a.Channels)...)
+}
+
+// Append appends the samples of b, which must share a's format
+func (a *Audio) Append(b *Audio) error {
+	if b.SampleRate != a.SampleRate || b.Channels != a.Channels {
+		return fmt.Errorf("cannot append %d Hz/%d ch audio to %d Hz/%d ch audio",
+			b.SampleRate, b.Channels, a.SampleRate, a.Channels)
+	}
+	a.Samples = append(a.Samples, b.Samples...)
+	return nil
+}
+
+// Decode reads a 16-bit PCM RIFF/WAVE stream
+func Decode(r io.Reader) (*Audio, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("failed to read RIFF header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("not a RIFF/WAVE stream")
+	}
+
+	var audio *Audio
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, fmt.Errorf("no data chunk found: %w", err)
+		}
+		id := string(hdr[0:4])
+		size := binary.LittleEndian.Uint32(hdr[4:8])
+
+		switch id {
+		case "fmt ":
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("failed to read fmt chunk: %w", err)
+			}
+			if len(body) < 16 {
+				return nil, fmt.Errorf("fmt chunk too short")
+			}
+			format := binary.LittleEndian.Uint16(body[0:2])
+			channels := binary.LittleEndian.Uint16(body[2:4])
+			sampleRate := binary.LittleEndian.Uint32(body[4:8])
+			bits := binary.LittleEndian.Uint16(body[14:16])
+			if format != formatPCM && format != formatExtensible {
+				return nil, fmt.Errorf("unsupported WAV format %d", format)
+			}
+			if bits != 16 {
+				return nil, fmt.Errorf("unsupported bit depth %d", bits)
+			}
+			audio = New(int(sampleRate), int(channels))
+		case "data":
+			if audio == nil {
+				return nil, fmt.Errorf("data chunk before fmt chunk")
+			}
+			// Some writers leave the size as a placeholder when streaming,
+			// so read up to EOF rather than trusting it blindly
+			data, err := io.ReadAll(io.LimitReader(r, int64(size)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read data chunk: %w", err)
+			}
+			audio.Samples = make([]int16, len(data)/2)
+			for i := range audio.Samples {
+				audio.Samples[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+			}
+			return audio, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size+size%2)); err != nil {
+				return nil, fmt.Errorf("failed to skip %q chunk: %w", id, err)
+			}
+			continue
+		}
+
+		// Chunks are word aligned
+		if size%2 == 1 {
+			if _, err := io.CopyN(io.Discard, r, 1); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Encode writes the audio as a 16-bit PCM RIFF/WAVE stream
+func (a *Audio) Encode(w io.Writer) error {
+	dataSize := uint32(len(a.Samples) * 2)
+	blockAlign := uint16(a.Channels * 2)
+
+	var hdr bytes.Buffer
+	hdr.WriteString("RIFF")
+	binary.Write(&hdr, binary.LittleEndian, 36+dataSize)
+	hdr.WriteString("WAVE")
+	hdr.WriteString("fmt ")
+	binary.Write(&hdr, binary.LittleEndian, uint32(16))
+	binary.Write(&hdr, binary.LittleEndian, uint16(formatPCM))
+	binary.Write(&hdr, binary.LittleEndian, uint16(a.Channels))
+	binary.Write(&hdr, binary.LittleEndian, uint32(a.SampleRate))
+	binary.Write(&hdr, binary.LittleEndian, uint32(a.SampleRate)*uint32(blockAlign))
+	binary.Write(&hdr, binary.LittleEndian, blockAlign)
+	binary.Write(&hdr, binary.LittleEndian, uint16(16))
+	hdr.WriteString("data")
+	binary.Write(&hdr, binary.LittleEndian, dataSize)
+
+	if _, err := w.Write(hdr.Bytes()); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, a.Samples)
+}
+
+// Bytes returns the audio encoded as a WAV file
+func (a *Audio) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := a.Encode(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
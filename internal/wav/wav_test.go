@@ -0,0 +1,61 @@
+package wav
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	a := New(22050, 1)
+	a.Samples = []int16{0, 1000, -1000, 32767, -32768}
+
+	b, err := a.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes() error: %v", err)
+	}
+
+	got, err := Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("Decode() error: %v", err)
+	}
+
+	if got.SampleRate != a.SampleRate || got.Channels != a.Channels {
+		t.Errorf("format = %d Hz/%d ch, want %d Hz/%d ch", got.SampleRate, got.Channels, a.SampleRate, a.Channels)
+	}
+	if len(got.Samples) != len(a.Samples) {
+		t.Fatalf("decoded %d samples, want %d", len(got.Samples), len(a.Samples))
+	}
+	for i := range a.Samples {
+		if got.Samples[i] != a.Samples[i] {
+			t.Errorf("sample %d = %d, want %d", i, got.Samples[i], a.Samples[i])
+		}
+	}
+}
+
+func TestAppendSilence(t *testing.T) {
+	a := New(1000, 2)
+	a.AppendSilence(0.5)
+
+	if len(a.Samples) != 1000 {
+		t.Errorf("got %d samples, want 1000", len(a.Samples))
+	}
+	if math.Abs(a.Duration()-0.5) > 1e-9 {
+		t.Errorf("Duration() = %.3f, want 0.5", a.Duration())
+	}
+}
+
+func TestAppendFormatMismatch(t *testing.T) {
+	a := New(22050, 1)
+	b := New(44100, 1)
+
+	if err := a.Append(b); err == nil {
+		t.Error("expected error appending audio with a different sample rate")
+	}
+}
+
+func TestDecodeRejectsNonWAV(t *testing.T) {
+	if _, err := Decode(bytes.NewReader([]byte("not a wave file at all"))); err == nil {
+		t.Error("expected error decoding non-WAV input")
+	}
+}
@@ -8,6 +8,12 @@ package views
 import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
+import (
+	"strconv"
+
+	"github.com/sammyshear/adon-olam/internal/lyrics"
+)
+
 func Index() templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
@@ -41,7 +47,89 @@ func Index() templ.Component {
 				}()
 			}
 			ctx = templ.InitializeContext(ctx)
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<main class=\"grid h-screen place-items-center\"><form hx-encoding=\"multipart/form-data\" hx-post=\"/api/upload\" hx-swap=\"outerHTML\"><input type=\"file\" name=\"uploadFile\"> <label for=\"trackNo\">Track Number</label> <input type=\"number\" name=\"trackNo\"> <label for=\"timingStrategy\">Timing Strategy</label> <select name=\"timingStrategy\"><option value=\"per-syllable\" selected>Per-Syllable (Recommended)</option> <option value=\"last-phoneme\">Last-Phoneme (Legacy)</option></select> <button>Upload</button></form></main>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<main class=\"grid h-screen place-items-center\"><form hx-encoding=\"multipart/form-data\" hx-post=\"/api/upload\" hx-swap=\"outerHTML\"><input type=\"file\" name=\"uploadFile\" accept=\".mid,.midi,.musicxml,.xml,.mxl,.abc\" hx-post=\"/api/inspect\" hx-trigger=\"change\" hx-target=\"#trackNo\" hx-swap=\"outerHTML\"> <label for=\"text\">Text</label> <select name=\"text\">")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			for _, text := range lyrics.Catalog() {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<option value=\"")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var3 string
+				templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(text.ID)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `views/index.templ`, Line: 17, Col: 29}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "\"")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				if text.ID == lyrics.DefaultTextID {
+					templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, " selected")
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, ">")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var4 string
+				templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(text.Title)
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `views/index.templ`, Line: 17, Col: 90}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</option>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "</select> <label for=\"lyrics\">Lyrics (instead of the text)</label> <textarea name=\"lyrics\" rows=\"4\" placeholder=\"Pointed Hebrew, IPA or X-SAMPA syllables separated by spaces\"></textarea> <label for=\"lyricsFile\">Lyrics File</label> <input type=\"file\" name=\"lyricsFile\" accept=\".txt,text/plain\"> <output id=\"lyricsPreview\" hx-post=\"/api/lyrics/preview\" hx-trigger=\"load, input delay:500ms from:closest form, change from:closest form\" hx-swap=\"innerHTML\"></output> <label for=\"trackNo\">Track</label> <select name=\"trackNo\" id=\"trackNo\"><option value=\"auto\" selected>Auto-detect</option></select> <label for=\"channel\">Channel</label> <select name=\"channel\" id=\"channel\"><option value=\"all\" selected>All channels</option> ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			for ch := 1; ch <= 16; ch++ {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "<option value=\"")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var5 string
+				templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(strconv.Itoa(ch))
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `views/index.templ`, Line: 33, Col: 38}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "\">Channel ")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				var templ_7745c5c3_Var6 string
+				templ_7745c5c3_Var6, templ_7745c5c3_Err = templ.JoinStringErrs(strconv.Itoa(ch))
+				if templ_7745c5c3_Err != nil {
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `views/index.templ`, Line: 33, Col: 67}
+				}
+				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var6))
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 10, "</option>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 11, "</select> <label for=\"timingStrategy\">Timing Strategy</label> <select name=\"timingStrategy\"><option value=\"per-syllable\" selected>Per-Syllable (Recommended)</option> <option value=\"last-phoneme\">Last-Phoneme (Legacy)</option></select> <label for=\"alignment\">Syllable Alignment</label> <select name=\"alignment\"><option value=\"even\" selected>Spread Evenly Over Notes</option> <option value=\"lyrics\">Follow MIDI Lyric Events</option></select> <label for=\"dynamics\">Dynamics</label> <select name=\"dynamics\"><option value=\"off\" selected>Even Loudness</option> <option value=\"linear\">Follow Velocity and Expression (Linear)</option> <option value=\"gm\">Follow Velocity and Expression (General MIDI)</option></select> <label for=\"chordPolicy\">Chords</label> <select name=\"chordPolicy\"><option value=\"lowest\" selected>Lowest Note</option> <option value=\"highest\">Highest Note (Skyline)</option> <option value=\"loudest\">Loudest Note</option> <option value=\"closest\">Closest to Previous Note</option> <option value=\"voice\">Voice Number</option></select> <label for=\"chordVoice\">Voice Number (from the top)</label> <input type=\"number\" name=\"chordVoice\" value=\"1\" min=\"1\"> <label for=\"chordThreshold\">Chord Threshold (ms)</label> <input type=\"number\" name=\"chordThreshold\" value=\"10\" min=\"1\" step=\"any\"> <label for=\"pronunciation\">Pronunciation</label> <select name=\"pronunciation\"><option value=\"\" selected>Default for Text</option> <option value=\"modern\">Modern Israeli</option> <option value=\"ashkenazi\">Ashkenazi</option> <option value=\"ashkenazi-lithuanian\">Ashkenazi (Lithuanian)</option> <option value=\"ashkenazi-polish\">Ashkenazi (Polish/Galician)</option> <option value=\"ashkenazi-german\">Ashkenazi (German)</option> <option value=\"sephardi\">Sephardi</option> <option value=\"yemenite\">Yemenite</option></select> <label for=\"voice\">Voice</label> <input type=\"text\" name=\"voice\" placeholder=\"Default for pronunciation\"> <label for=\"maxHz\">Maximum Frequency (Hz)</label> <input type=\"number\" name=\"maxHz\" value=\"500\" min=\"1\" step=\"any\"> <label for=\"tempoScale\">Tempo Scale</label> <input type=\"number\" name=\"tempoScale\" value=\"1\" min=\"0.1\" max=\"4\" step=\"0.05\"> <button>Upload</button></form></main>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}